@@ -2,25 +2,35 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"net"
 	"net/http"
 	_ "net/http/pprof" // Import pprof for diagnostic info
+	"os/signal"
+	"slices"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	// Added if needed later, but focusing on pprof now
 	"google.golang.org/grpc"
-	"google.golang.org/protobuf/proto"
 
 	"game-chat-service/internal/config"
 	"game-chat-service/internal/hub"
 	"game-chat-service/internal/logger"
+	"game-chat-service/internal/metrics"
 	"game-chat-service/internal/repository"
 	"game-chat-service/internal/service"
 	"game-chat-service/internal/transport"
+	"game-pkg/health"
 	"game-pkg/mq"
+	"game-pkg/registry"
+	"game-pkg/tracing"
 
 	"game-protocols/chat"
+
+	"github.com/google/uuid"
 )
 
 type grpcServer struct {
@@ -31,40 +41,51 @@ type grpcServer struct {
 func main() {
 	// Initialize logger first
 	logger.Init()
-	// Enable debug logging for troubleshooting
-	logger.SetLevel(logger.DEBUG)
-	logger.EnableTag(logger.TagService)
-	logger.EnableTag(logger.TagMQ)
-	// Disable noisy logs
-	logger.DisableTag(logger.TagDB)
-	logger.DisableTag(logger.TagTransport)
 
 	// 1. Load Config
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Config error: %v", err)
+		logger.Fatal(logger.TagSystem, "Config error: %v", err)
+	}
+	if config.CheckOnly() {
+		logger.Info(logger.TagSystem, "Config OK")
+		return
+	}
+
+	// 日志级别及标签：configs/chat-logger.yaml + LOGGER_* 环境变量，文件修改后热更新
+	if err := logger.Load("chat-logger"); err != nil {
+		logger.Fatal(logger.TagSystem, "Logger config error: %v", err)
 	}
 
-	// 🆕 Enable pprof in non-prod environment
+	// 🆕 Metrics (/metrics) and pprof (non-prod only) share the debug port
+	debugPort := cfg.Server.MetricsPort
+	debugMux := http.NewServeMux()
+	debugMux.Handle("/metrics", metrics.Handler())
 	if cfg.Server.Env != "prod" {
-		go func() {
-			pprofPort := 6061 // Use different port for chat service pprof
-			log.Printf("📊 Starting pprof server on :%d", pprofPort)
-			if err := http.ListenAndServe(fmt.Sprintf(":%d", pprofPort), nil); err != nil {
-				log.Printf("⚠️ pprof server failed: %v", err)
-			}
-		}()
+		debugMux.Handle("/debug/pprof/", http.DefaultServeMux)
+	}
+	go func() {
+		logger.Info(logger.TagSystem, "Starting metrics/pprof server on :%d", debugPort)
+		if err := http.ListenAndServe(fmt.Sprintf(":%d", debugPort), debugMux); err != nil {
+			logger.Warn(logger.TagSystem, "metrics/pprof server failed: %v", err)
+		}
+	}()
+
+	// 链路追踪：exporter=none 时为空实现
+	shutdownTracing, err := tracing.Init("game-chat-service", cfg.Tracing)
+	if err != nil {
+		logger.Fatal(logger.TagSystem, "Invalid tracing config: %v", err)
 	}
 
 	// 2. Init DB & Redis
 	db, err := repository.NewDatabase(cfg.Database.DSN)
 	if err != nil {
-		log.Printf("DB Connect error: %v", err)
+		logger.Error(logger.TagSystem, "DB Connect error: %v", err)
 	}
 
 	rdb, err := repository.NewRedisClient(cfg.Redis.Addr, cfg.Redis.Password)
 	if err != nil {
-		log.Printf("Redis Connect error: %v", err)
+		logger.Error(logger.TagSystem, "Redis Connect error: %v", err)
 	}
 
 	// 3. Init Core
@@ -77,8 +98,11 @@ func main() {
 	}
 
 	switch cfg.MQ.Type {
+	case "none":
+		// 不使用 MQ：只接受 Gateway 直连请求，广播经直连连接推送
+		logger.Info(logger.TagSystem, "MQ disabled, serving gateways over direct transport only")
 	case "robustmq":
-		log.Println("🚀 Using RobustMQ (MQTT)")
+		logger.Info(logger.TagSystem, "Using RobustMQ (MQTT)")
 		redisMQ = mq.NewRobustMQ(&mq.RobustMQConfig{
 			Broker:   cfg.MQ.RobustMQ.Broker,
 			ClientID: cfg.MQ.RobustMQ.ClientID,
@@ -88,77 +112,285 @@ func main() {
 	case "redis":
 		fallthrough
 	default:
-		log.Println("🚀 Using Redis MQ")
+		logger.Info(logger.TagSystem, "Using Redis MQ")
 		redisMQ = mq.NewRedisMQ(rdb.Client)
 	}
 
+	// 游戏注册表：配置文件中的游戏，运行时随 Gateway 注册表（管理 API / 配置热更新）经 MQ 同步增删
+	games := registry.New(cfg.Games...)
+	var configGames atomic.Pointer[[]string] // 配置文件中的游戏，配置修改后热更新；不随其他实例注销
+	configGames.Store(&cfg.Games)
+	games.SetProtected(func(id string) bool { return slices.Contains(*configGames.Load(), id) })
+
+	// 消息存储：按 GameID 路由到各游戏的库表，启动时迁移配置中游戏的表
+	store := messageStore(cfg, db)
+	store.SetRegistry(games)
+	if cfg.Database.Migrate {
+		if err := store.Migrate(context.Background(), cfg.Games); err != nil {
+			logger.Warn(logger.TagSystem, "DB migration failed (retried on first write): %v", err)
+		}
+	}
+
 	// Initialize ChatService
-	svc := service.NewChatService(h, db)
-	svc.SetProducer(redisMQ)
+	svc := service.NewChatService(h, store)
 
-	// 🆕 6. Start Redis Consumer (for Gateway incoming requests)
-	requestChan, err := redisMQ.Subscribe("game:request:mmo") // Topic convention
-	if err != nil {
-		log.Fatalf("Failed to subscribe to requests: %v", err)
+	// 4. Start WebSocket Server (for Gateway direct transport)
+	wsSrv := transport.NewWSServer(cfg.Server.Port, svc)
+	if cfg.Server.GatewayToken != "" {
+		wsSrv.SetGatewayToken(cfg.Server.GatewayToken)
+	} else {
+		logger.Warn(logger.TagSystem, "Gateway direct transport disabled: server.gateway_token not set")
 	}
 
 	go func() {
-		log.Println("🎧 Started listening for Redis requests on game:request:mmo")
-		for msg := range requestChan {
-			// 并发处理每个请求
-			go func(m *mq.Message) {
-				var req chat.ChatRequest
-				if err := proto.Unmarshal(m.Payload, &req); err != nil {
-					log.Printf("Failed to unmarshal request: %v", err)
-					return
-				}
+		if err := wsSrv.Start(); err != nil {
+			logger.Fatal(logger.TagSystem, "WS Server failed: %v", err)
+		}
+	}()
 
-				// 处理请求
-				resp, err := svc.HandleRequest(context.Background(), &req)
-				if err != nil {
-					log.Printf("HandleRequest error: %v", err)
-					// TODO: Send error response?
-					return
-				}
+	var rpcSrv *mq.RPCServer
+	if redisMQ == nil {
+		svc.SetProducer(metrics.InstrumentProducer(wsSrv, mq.TopicBroadcast))
+	} else {
+		producer := metrics.InstrumentProducer(redisMQ, mq.TopicBroadcast, mq.TopicGameRegistry, cfg.MQ.DeadLetterTopic)
+		svc.SetProducer(producer)
+
+		// 🆕 6. Start Redis Consumer (for Gateway incoming requests)
+		subOpts, err := cfg.MQ.Subscribe.Options()
+		if err != nil {
+			logger.Fatal(logger.TagSystem, "Invalid mq.subscribe config: %v", err)
+		}
+		if cfg.MQ.QueueGroup != "" {
+			subOpts = append(subOpts, mq.WithQueueGroup(cfg.MQ.QueueGroup))
+		}
+		mqHandler := transport.NewMQHandler(svc, producer)
+		rpcSrv = mq.NewRPCServer(producer, redisMQ,
+			mq.WithDeadLetterTopic(cfg.MQ.DeadLetterTopic),
+			mq.WithRetryPolicy(retryPolicy(cfg)),
+			mq.WithFailureHandler(mqHandler.HandleFailure),
+		)
+		if err := mqHandler.Register(rpcSrv, subOpts...); err != nil {
+			logger.Fatal(logger.TagSystem, "Failed to subscribe to requests: %v", err)
+		}
+		for _, id := range games.List() {
+			if err := mqHandler.AddGame(rpcSrv, id, subOpts...); err != nil {
+				logger.Fatal(logger.TagSystem, "Failed to subscribe to requests: %v", err)
+			}
+		}
+		logger.Info(logger.TagSystem, "Started listening for requests of games %v (group: %q)", games.List(), cfg.MQ.QueueGroup)
+
+		// 注册表变化时订阅 / 取消订阅游戏的请求 Topic
+		games.OnChange(func(ev registry.Event) {
+			var err error
+			switch ev.Op {
+			case registry.OpAdd:
+				err = mqHandler.AddGame(rpcSrv, ev.GameID, subOpts...)
+			case registry.OpRemove:
+				err = mqHandler.RemoveGame(rpcSrv, ev.GameID)
+			}
+			if err != nil {
+				logger.Warn(logger.TagMQ, "Update game request subscription failed | Game: %s, Op: %s, Error: %v", ev.GameID, ev.Op, err)
+			}
+		})
+		if err := games.Sync(producer, redisMQ, "chat-"+uuid.New().String(), false); err != nil {
+			logger.Fatal(logger.TagSystem, "Failed to sync game registry: %v", err)
+		}
+
+		// 订阅侧背压统计定期报告
+		if reporter, ok := redisMQ.(mq.StatsReporter); ok {
+			go reportMQStats(reporter, 30*time.Second)
+		}
 
-				// 发送 ACK 响应 (发给发送者)
-				if resp != nil {
-					// 路由信息
-					resp.TargetUserId = req.Base.UserId
-
-					respBytes, err := proto.Marshal(resp)
-					if err == nil {
-						// 这里的 "broadcast" 其实是 "gateway_downstream" 的意思
-						// 所有的 Gateway 都会收到并路由
-						if err := redisMQ.Publish("broadcast", respBytes); err != nil {
-							log.Printf("Failed to publish ACK: %v", err)
-						}
-					}
+		// 连接状态变化日志（断线期间不会收到 Gateway 请求）
+		if watcher, ok := redisMQ.(mq.StateWatcher); ok {
+			watcher.OnStateChange(func(ev mq.StateEvent) {
+				if ev.Err != nil {
+					logger.Warn(logger.TagMQ, "MQ state changed | State: %s, Topic: %s, Error: %v", ev.State, ev.Topic, ev.Err)
+				} else {
+					logger.Info(logger.TagMQ, "MQ state changed | State: %s, Topic: %s", ev.State, ev.Topic)
 				}
-			}(msg)
+			})
 		}
-	}()
+	}
 
-	// 4. Start WebSocket Server (for Gateway incoming requests)
-	wsSrv := transport.NewWSServer(cfg.Server.Port, svc)
+	// 配置文件变化时热更新游戏列表及 MQ 重试策略；校验失败则保留旧配置
+	current := cfg
+	config.Watch(func(next *config.Config) {
+		if rpcSrv != nil {
+			rpcSrv.SetRetryPolicy(retryPolicy(next))
+		}
+		for _, id := range next.Games {
+			if !slices.Contains(current.Games, id) {
+				games.Add(id)
+			}
+		}
+		for _, id := range current.Games {
+			if !slices.Contains(next.Games, id) {
+				games.Remove(id)
+			}
+		}
+		configGames.Store(&next.Games)
+		logger.Info(logger.TagSystem, "Config reloaded | Retry: %d attempt(s), backoff %s - %s",
+			next.MQ.Retry.MaxAttempts, next.MQ.Retry.InitialBackoff, next.MQ.Retry.MaxBackoff)
+		if changed := config.RestartRequired(current, next); len(changed) > 0 {
+			logger.Warn(logger.TagSystem, "Config changes require restart to take effect: %v", changed)
+		}
+		current = next
+	})
 
-	go func() {
-		if err := wsSrv.Start(); err != nil {
-			log.Fatalf("WS Server failed: %v", err)
+	// 健康检查：/healthz 存活，/readyz 就绪（与 Gateway 直连共用 WS 端口）
+	var draining atomic.Bool
+	ready := health.NewRegistry()
+	ready.SetDraining(draining.Load)
+	ready.Add("postgres", health.Ping(store.Ping))
+	ready.Add("redis", health.Ping(func(ctx context.Context) error {
+		if rdb == nil {
+			return errors.New("not connected")
 		}
-	}()
+		return rdb.Client.Ping(ctx).Err()
+	}))
+	ready.Add("save_queue", saveQueueCheck(svc))
+	if redisMQ != nil {
+		ready.Add("mq", health.MQ(redisMQ))
+	}
+	http.Handle("/healthz", ready.LivenessHandler())
+	http.Handle("/readyz", ready.ReadinessHandler())
 
 	// 5. Start gRPC Server
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Server.GrpcPort))
 	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
+		logger.Fatal(logger.TagSystem, "failed to listen: %v", err)
 	}
 
-	s := grpc.NewServer()
+	s := grpc.NewServer(grpc.UnaryInterceptor(metrics.UnaryServerInterceptor()))
 	chat.RegisterChatServiceServer(s, &grpcServer{svc: svc})
 
-	log.Printf("Chat Service listening - WS on :%d, gRPC on :%d", cfg.Server.Port, cfg.Server.GrpcPort)
+	// 6. Graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		logger.Info(logger.TagSystem, "Shutdown signal received, draining requests...")
+		draining.Store(true)
+
+		shutdownTimeout := cfg.Server.ShutdownTimeout
+		if shutdownTimeout <= 0 {
+			shutdownTimeout = 15 * time.Second
+		}
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		// 停止 MQ 消费并等待处理中的 HandleRequest 返回（期间仍需 Producer 发送 ACK/广播）
+		if rpcSrv != nil {
+			if err := rpcSrv.Shutdown(shutdownCtx); err != nil {
+				logger.Warn(logger.TagSystem, "MQ request drain incomplete: %v", err)
+			}
+		}
+
+		// 将 saveChan 中的剩余消息写入 DB（之后 WS 入口的新消息会被拒绝）
+		if err := svc.Shutdown(shutdownCtx); err != nil {
+			logger.Warn(logger.TagSystem, "Save buffer flush incomplete: %v", err)
+		}
+
+		// gRPC 不再接受新请求，等待处理中的调用完成，超时则强制关闭
+		grpcStopped := make(chan struct{})
+		go func() {
+			s.GracefulStop()
+			close(grpcStopped)
+		}()
+		select {
+		case <-grpcStopped:
+		case <-shutdownCtx.Done():
+			logger.Warn(logger.TagSystem, "gRPC graceful stop timed out, forcing stop")
+			s.Stop()
+		}
+
+		if redisMQ != nil {
+			if err := redisMQ.Close(); err != nil {
+				logger.Warn(logger.TagSystem, "MQ close error: %v", err)
+			}
+		}
+		if rdb != nil {
+			rdb.Close()
+		}
+		if db != nil {
+			db.Close()
+		}
+		store.Close()
+
+		// 导出缓冲中剩余的 Span
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			logger.Warn(logger.TagSystem, "Tracing shutdown error: %v", err)
+		}
+	}()
+
+	logger.Info(logger.TagSystem, "Chat Service listening - WS on :%d, gRPC on :%d", cfg.Server.Port, cfg.Server.GrpcPort)
 	if err := s.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
+		logger.Fatal(logger.TagSystem, "failed to serve: %v", err)
+	}
+
+	// 等待下线流程完成
+	<-shutdownDone
+	logger.Info(logger.TagSystem, "Chat Service stopped")
+}
+
+// messageStore 根据配置构造消息存储，database.games 中配置了 dsn 的游戏连接各自的库
+// 独立库连接失败时该游戏的消息写入报错，不会写入默认库
+func messageStore(cfg *config.Config, db *repository.Database) *repository.MessageStore {
+	dc := cfg.Database
+	storage, _ := repository.ParseStorage(dc.Storage) // 已在配置校验中检查
+	store := repository.NewMessageStore(db, storage, dc.Migrate)
+	for _, g := range dc.Games {
+		if g.Storage != "" {
+			s, _ := repository.ParseStorage(g.Storage)
+			store.SetGame(g.ID, s)
+		}
+		if g.DSN != "" {
+			gameDB, err := repository.NewDatabase(g.DSN)
+			if err != nil {
+				logger.Error(logger.TagSystem, "DB Connect error (game %s): %v", g.ID, err)
+			}
+			store.SetGameDatabase(g.ID, gameDB)
+		}
+	}
+	return store
+}
+
+// retryPolicy 根据配置构造请求处理失败重试策略
+func retryPolicy(cfg *config.Config) mq.RetryPolicy {
+	return mq.RetryPolicy{
+		MaxAttempts:    cfg.MQ.Retry.MaxAttempts,
+		InitialBackoff: cfg.MQ.Retry.InitialBackoff,
+		MaxBackoff:     cfg.MQ.Retry.MaxBackoff,
+	}
+}
+
+// saveQueueHighWatermark saveChan 使用率达到该比例视为饱和，/readyz 返回未就绪
+const saveQueueHighWatermark = 0.9
+
+// saveQueueCheck 检查持久化队列是否饱和（DB 写入跟不上时新消息将被拒绝）
+func saveQueueCheck(svc *service.ChatService) health.Check {
+	return func(context.Context) (any, error) {
+		depth, capacity := svc.SaveQueue()
+		usage := float64(depth) / float64(capacity)
+		details := map[string]any{"depth": depth, "capacity": capacity, "usage": usage}
+		if usage >= saveQueueHighWatermark {
+			return details, fmt.Errorf("save queue saturated: %d/%d", depth, capacity)
+		}
+		return details, nil
+	}
+}
+
+// reportMQStats 定期输出 MQ 订阅侧投递统计
+func reportMQStats(reporter mq.StatsReporter, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		st := reporter.Stats()
+		logger.Info(logger.TagMQ, "Subscribe stats | Delivered: %d, Delayed: %d, Dropped: %d, Spilled: %d, Reconnects: %d",
+			st.Delivered, st.Delayed, st.Dropped, st.Spilled, st.Reconnects)
 	}
 }
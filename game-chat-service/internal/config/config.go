@@ -1,9 +1,18 @@
 package config
 
 import (
+	"errors"
 	"flag"
-	"log"
+	"fmt"
+	"os"
+	"strings"
+	"time"
 
+	"game-chat-service/internal/logger"
+	"game-pkg/mq"
+	"game-pkg/tracing"
+
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 )
 
@@ -12,36 +21,142 @@ type Config struct {
 		Port     int    `mapstructure:"port"`
 		GrpcPort int    `mapstructure:"grpc_port"`
 		Env      string `mapstructure:"env"`
+		// MetricsPort /metrics 及 pprof（非 prod）的 HTTP 端口，默认 6061
+		MetricsPort int `mapstructure:"metrics_port"`
+		// ShutdownTimeout 收到 SIGTERM 后等待处理中请求与持久化队列写完的最长时间
+		ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+		// GatewayToken Gateway 直连（chat_backend.transport=direct）须携带的共享密钥，为空则拒绝所有直连
+		GatewayToken     string `mapstructure:"gateway_token"`
+		GatewayTokenFile string `mapstructure:"gateway_token_file"` // 从文件读取，优先于 gateway_token
 	} `mapstructure:"server"`
 
+	// Games 订阅请求 Topic（game:request:{id}）的游戏；运行时随 Gateway 的游戏注册表（经 MQ 同步）增删
+	Games []string `mapstructure:"games"`
+
 	Database struct {
-		DSN string `mapstructure:"dsn"`
+		DSN     string `mapstructure:"dsn"`
+		DSNFile string `mapstructure:"dsn_file"` // 从文件读取 DSN（含密码，如 K8s Secret 挂载），优先于 dsn
+		// Storage 消息存放方式：shared（共用 messages 表，按 game_id 区分）/ table（每个游戏独立的 messages_{id} 表）
+		Storage string `mapstructure:"storage"`
+		// Migrate 启动时及首次写入新表前执行 schema 迁移（建表、索引）；默认关闭，由 DBA 手工维护
+		Migrate bool `mapstructure:"migrate"`
+		// Games 按游戏覆盖存储方式，或写入独立库
+		Games []GameDatabaseConfig `mapstructure:"games"`
 	} `mapstructure:"database"`
 
-	Redis struct {
-		Addr     string `mapstructure:"addr"`
-		Password string `mapstructure:"password"`
-	} `mapstructure:"redis"`
+	// Tracing 链路追踪（OpenTelemetry），上下文经 ChatRequest.meta 及 Envelope 跨服务传递
+	Tracing tracing.Config `mapstructure:"tracing"`
+
+	Redis RedisConfig `mapstructure:"redis"`
 
 	MQ struct {
-		Type     string `mapstructure:"type"`
-		RobustMQ struct {
-			Broker   string `mapstructure:"broker"`
-			ClientID string `mapstructure:"client_id"`
-			Username string `mapstructure:"username"`
-			Password string `mapstructure:"password"`
+		Type string `mapstructure:"type"` // robustmq / redis / none
+		// QueueGroup 多个 Chat Service 实例使用同一消费组共享请求（MQTT 共享订阅）
+		QueueGroup string `mapstructure:"queue_group"`
+		RobustMQ   struct {
+			Broker       string `mapstructure:"broker"`
+			ClientID     string `mapstructure:"client_id"`
+			Username     string `mapstructure:"username"`
+			Password     string `mapstructure:"password"`
+			PasswordFile string `mapstructure:"password_file"` // 从文件读取密码，优先于 password
 		} `mapstructure:"robustmq"`
-		Redis struct {
-			Addr     string `mapstructure:"addr"`
-			Password string `mapstructure:"password"`
-		} `mapstructure:"redis"`
+		Redis RedisConfig `mapstructure:"redis"`
+		// Subscribe 订阅缓冲区与背压策略
+		Subscribe mq.SubscribeConfig `mapstructure:"subscribe"`
+		// DeadLetterTopic 处理失败的请求发布到该 Topic，留空则只记录日志
+		DeadLetterTopic string `mapstructure:"dead_letter_topic"`
+		// Retry 可重试错误（如 DB 缓冲区满）的重试策略
+		Retry RetryConfig `mapstructure:"retry"`
 	} `mapstructure:"mq"`
 }
 
+// GameDatabaseConfig 单个游戏的消息存储配置
+type GameDatabaseConfig struct {
+	ID      string `mapstructure:"id"`
+	Storage string `mapstructure:"storage"`  // 为空使用 database.storage
+	DSN     string `mapstructure:"dsn"`      // 非空时写入该游戏的独立库
+	DSNFile string `mapstructure:"dsn_file"` // 从文件读取 DSN，优先于 dsn
+}
+
+// RedisConfig Redis 连接配置
+type RedisConfig struct {
+	Addr         string `mapstructure:"addr"`
+	Password     string `mapstructure:"password"`
+	PasswordFile string `mapstructure:"password_file"` // 从文件读取密码，优先于 password
+}
+
+// RetryConfig 请求处理失败重试配置
+type RetryConfig struct {
+	MaxAttempts    int           `mapstructure:"max_attempts"`    // 总尝试次数（含首次）
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"` // 首次重试等待时间
+	MaxBackoff     time.Duration `mapstructure:"max_backoff"`     // 退避上限
+}
+
+// EnvPrefix 环境变量覆盖前缀：配置项路径转为大写、"." 替换为 "_"
+// 如 CHAT_SERVER_PORT、CHAT_DATABASE_DSN_FILE、CHAT_MQ_ROBUSTMQ_BROKER
+const EnvPrefix = "CHAT"
+
+var checkOnly bool
+
+// CheckOnly 是否以 -check-config 启动：Load 校验通过后应直接退出
+func CheckOnly() bool {
+	return checkOnly
+}
+
+// setDefaults 未在文件及环境变量中配置的项使用的默认值（同时使这些项可被环境变量覆盖）
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("server.port", 9002)
+	v.SetDefault("server.grpc_port", 50051)
+	v.SetDefault("server.env", "dev")
+	v.SetDefault("server.metrics_port", 6061)
+	v.SetDefault("server.shutdown_timeout", "15s")
+	v.SetDefault("server.gateway_token", "")
+	v.SetDefault("server.gateway_token_file", "")
+
+	v.SetDefault("games", []string{"mmo"})
+
+	v.SetDefault("database.dsn", "")
+	v.SetDefault("database.dsn_file", "")
+	v.SetDefault("database.storage", "shared")
+	v.SetDefault("database.migrate", false)
+
+	v.SetDefault("tracing.exporter", "none")
+	v.SetDefault("tracing.file", "traces-chat.json")
+	v.SetDefault("tracing.endpoint", "localhost:4318")
+	v.SetDefault("tracing.insecure", true)
+	v.SetDefault("tracing.sample_ratio", 1.0)
+
+	v.SetDefault("redis.addr", "localhost:6379")
+	v.SetDefault("redis.password", "")
+	v.SetDefault("redis.password_file", "")
+
+	v.SetDefault("mq.type", "robustmq")
+	v.SetDefault("mq.queue_group", "chat-service")
+	v.SetDefault("mq.robustmq.broker", "tcp://localhost:1883")
+	v.SetDefault("mq.robustmq.client_id", "chat-service-1")
+	v.SetDefault("mq.robustmq.username", "")
+	v.SetDefault("mq.robustmq.password", "")
+	v.SetDefault("mq.robustmq.password_file", "")
+	v.SetDefault("mq.redis.addr", "localhost:6379")
+	v.SetDefault("mq.redis.password", "")
+	v.SetDefault("mq.redis.password_file", "")
+	v.SetDefault("mq.subscribe.buffer_size", 4096)
+	v.SetDefault("mq.subscribe.overflow", "block")
+	v.SetDefault("mq.subscribe.block_timeout", "1s")
+	v.SetDefault("mq.subscribe.spill_dir", "")
+	v.SetDefault("mq.dead_letter_topic", "chat:deadletter")
+	v.SetDefault("mq.retry.max_attempts", 3)
+	v.SetDefault("mq.retry.initial_backoff", "50ms")
+	v.SetDefault("mq.retry.max_backoff", "1s")
+}
+
+// Load 读取配置：默认值 < 配置文件 < CHAT_* 环境变量 < *_file 密钥文件，并校验
+// -config 指定的文件不存在时报错；未指定且默认路径下没有配置文件时只使用默认值及环境变量
 func Load() (*Config, error) {
 	// 支持 -config 命令行参数
 	var configPath string
 	flag.StringVar(&configPath, "config", "", "配置文件路径")
+	flag.BoolVar(&checkOnly, "check-config", false, "校验配置文件后退出")
 	flag.Parse()
 
 	if configPath != "" {
@@ -55,16 +170,80 @@ func Load() (*Config, error) {
 		viper.AddConfigPath(".")
 	}
 
+	setDefaults(viper.GetViper())
+	viper.SetEnvPrefix(EnvPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv()
 
 	if err := viper.ReadInConfig(); err != nil {
-		log.Printf("Warning: Config file not found, using defaults or env vars: %v", err)
+		var notFound viper.ConfigFileNotFoundError
+		if configPath != "" || !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("read config: %w", err)
+		}
+		logger.Warn(logger.TagSystem, "Config file not found, using defaults and %s_* env vars", EnvPrefix)
+	} else {
+		logger.Info(logger.TagSystem, "Loaded config from %s", viper.ConfigFileUsed())
 	}
 
+	return decode()
+}
+
+// decode 解析当前 viper 配置，读取密钥文件并校验；文件中出现未知配置项（多为拼写错误）时报错
+func decode() (*Config, error) {
 	var cfg Config
-	if err := viper.Unmarshal(&cfg); err != nil {
+	if err := viper.UnmarshalExact(&cfg); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	if err := cfg.loadSecrets(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
-
 	return &cfg, nil
 }
+
+// loadSecrets {key}_file 非空时读取文件内容（去除首尾空白）覆盖 {key}
+func (c *Config) loadSecrets() error {
+	type secret struct {
+		key   string
+		file  string
+		value *string
+	}
+	secrets := []secret{
+		{"server.gateway_token", c.Server.GatewayTokenFile, &c.Server.GatewayToken},
+		{"database.dsn", c.Database.DSNFile, &c.Database.DSN},
+		{"redis.password", c.Redis.PasswordFile, &c.Redis.Password},
+		{"mq.robustmq.password", c.MQ.RobustMQ.PasswordFile, &c.MQ.RobustMQ.Password},
+		{"mq.redis.password", c.MQ.Redis.PasswordFile, &c.MQ.Redis.Password},
+	}
+	for i := range c.Database.Games {
+		g := &c.Database.Games[i]
+		secrets = append(secrets, secret{fmt.Sprintf("database.games[%d].dsn", i), g.DSNFile, &g.DSN})
+	}
+	for _, s := range secrets {
+		if s.file == "" {
+			continue
+		}
+		data, err := os.ReadFile(s.file)
+		if err != nil {
+			return fmt.Errorf("%s_file: %w", s.key, err)
+		}
+		*s.value = strings.TrimSpace(string(data))
+	}
+	return nil
+}
+
+// Watch 监听配置文件变化，重新解析并校验通过后回调 fn（用于热更新）；校验失败保留旧配置
+// 只有部分配置项支持热更新，其余变化需重启生效（见 RestartRequired）
+func Watch(fn func(*Config)) {
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		cfg, err := decode()
+		if err != nil {
+			logger.Warn(logger.TagSystem, "Reload config %s rejected, keeping current config: %v", e.Name, err)
+			return
+		}
+		fn(cfg)
+	})
+	viper.WatchConfig()
+}
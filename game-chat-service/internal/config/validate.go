@@ -0,0 +1,204 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"game-chat-service/internal/repository"
+	"game-pkg/mq"
+	"game-pkg/registry"
+)
+
+// ValidationError 配置校验失败，列出所有问题（配置项路径: 原因）
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d invalid setting(s):\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// problems 收集校验问题
+type problems []string
+
+func (p *problems) addf(key, format string, args ...any) {
+	*p = append(*p, key+": "+fmt.Sprintf(format, args...))
+}
+
+func (p *problems) port(key string, port int) {
+	if port < 1 || port > 65535 {
+		p.addf(key, "must be between 1 and 65535, got %d", port)
+	}
+}
+
+func (p *problems) nonNegative(key string, n int64) {
+	if n < 0 {
+		p.addf(key, "must not be negative, got %d", n)
+	}
+}
+
+func (p *problems) hostPort(key, addr string) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		p.addf(key, "must be host:port, got %q", addr)
+	}
+}
+
+// Validate 校验所有配置项，返回的 *ValidationError 列出全部问题
+func (c *Config) Validate() error {
+	var p problems
+
+	sc := c.Server
+	p.port("server.port", sc.Port)
+	p.port("server.grpc_port", sc.GrpcPort)
+	p.port("server.metrics_port", sc.MetricsPort)
+	if sc.Port == sc.GrpcPort || sc.Port == sc.MetricsPort || sc.GrpcPort == sc.MetricsPort {
+		p.addf("server", "port, grpc_port and metrics_port must differ, got %d / %d / %d", sc.Port, sc.GrpcPort, sc.MetricsPort)
+	}
+	p.nonNegative("server.shutdown_timeout", int64(sc.ShutdownTimeout))
+	if c.MQ.Type == "none" && sc.GatewayToken == "" {
+		p.addf("server.gateway_token", "is required when mq.type is none (or server.gateway_token_file / CHAT_SERVER_GATEWAY_TOKEN)")
+	}
+
+	seen := make(map[string]bool, len(c.Games))
+	for i, id := range c.Games {
+		key := fmt.Sprintf("games[%d]", i)
+		if err := registry.ValidID(id); err != nil {
+			p.addf(key, "%v", err)
+		} else if seen[id] {
+			p.addf(key, "duplicate game %q", id)
+		}
+		seen[id] = true
+	}
+
+	c.validateDatabase(&p)
+	p.hostPort("redis.addr", c.Redis.Addr)
+
+	tc := c.Tracing
+	switch tc.Exporter {
+	case "", "none", "stdout", "otlp":
+	case "file":
+		if tc.File == "" {
+			p.addf("tracing.file", "is required for the file exporter")
+		}
+	default:
+		p.addf("tracing.exporter", "unknown exporter %q (none / file / stdout / otlp)", tc.Exporter)
+	}
+	if tc.SampleRatio < 0 || tc.SampleRatio > 1 {
+		p.addf("tracing.sample_ratio", "must be between 0 and 1, got %v", tc.SampleRatio)
+	}
+
+	c.validateMQ(&p)
+
+	if len(p) > 0 {
+		return &ValidationError{Problems: p}
+	}
+	return nil
+}
+
+func (c *Config) validateDatabase(p *problems) {
+	dc := c.Database
+	if dc.DSN == "" {
+		p.addf("database.dsn", "is required (or database.dsn_file / CHAT_DATABASE_DSN)")
+	}
+	storage, err := repository.ParseStorage(dc.Storage)
+	if err != nil {
+		p.addf("database.storage", "%v", err)
+	}
+
+	// table 方式下 GameID 拼接为表名
+	tableName := func(key string, s repository.Storage, id string) {
+		if name := s.Table(id); len(name) > repository.MaxTableNameLen {
+			p.addf(key, "table name %q too long for %s storage (max %d)", name, s, repository.MaxTableNameLen)
+		}
+	}
+	for i, id := range c.Games {
+		tableName(fmt.Sprintf("games[%d]", i), storage, id)
+	}
+
+	seen := make(map[string]bool, len(dc.Games))
+	for i, g := range dc.Games {
+		key := fmt.Sprintf("database.games[%d]", i)
+		switch {
+		case g.ID == "":
+			p.addf(key+".id", "is required")
+		case registry.ValidID(g.ID) != nil:
+			p.addf(key+".id", "%v", registry.ValidID(g.ID))
+		case seen[g.ID]:
+			p.addf(key+".id", "duplicate game %q", g.ID)
+		default:
+			key = fmt.Sprintf("database.games[%s]", g.ID)
+		}
+		seen[g.ID] = true
+
+		s := storage
+		if g.Storage != "" {
+			if s, err = repository.ParseStorage(g.Storage); err != nil {
+				p.addf(key+".storage", "%v", err)
+			}
+		}
+		tableName(key+".id", s, g.ID)
+		if g.DSN == "" && g.Storage == "" {
+			p.addf(key, "must set storage or dsn (or dsn_file)")
+		}
+	}
+}
+
+func (c *Config) validateMQ(p *problems) {
+	switch c.MQ.Type {
+	case "none":
+		return
+	case "robustmq":
+		rc := c.MQ.RobustMQ
+		if u, err := url.Parse(rc.Broker); err != nil || u.Scheme == "" || u.Host == "" {
+			p.addf("mq.robustmq.broker", "must be a URL such as tcp://host:1883, got %q", rc.Broker)
+		}
+		if rc.ClientID == "" {
+			p.addf("mq.robustmq.client_id", "is required (unique per instance)")
+		}
+	case "redis":
+		// Redis MQ 复用 redis 连接
+	default:
+		p.addf("mq.type", "unknown type %q (robustmq / redis / none)", c.MQ.Type)
+		return
+	}
+
+	sc := c.MQ.Subscribe
+	p.nonNegative("mq.subscribe.buffer_size", int64(sc.BufferSize))
+	if _, err := mq.ParseOverflowPolicy(sc.Overflow); err != nil {
+		p.addf("mq.subscribe.overflow", "%v", err)
+	}
+	p.nonNegative("mq.subscribe.block_timeout", int64(sc.BlockTimeout))
+
+	rc := c.MQ.Retry
+	if rc.MaxAttempts < 1 {
+		p.addf("mq.retry.max_attempts", "must be at least 1 (1 disables retries), got %d", rc.MaxAttempts)
+	}
+	p.nonNegative("mq.retry.initial_backoff", int64(rc.InitialBackoff))
+	p.nonNegative("mq.retry.max_backoff", int64(rc.MaxBackoff))
+	if rc.MaxBackoff > 0 && rc.MaxBackoff < rc.InitialBackoff {
+		p.addf("mq.retry.max_backoff", "must not be less than initial_backoff (%s), got %s", rc.InitialBackoff, rc.MaxBackoff)
+	}
+}
+
+// RestartRequired 返回 old 与 next 之间不支持热更新的变化（配置项路径），需重启后生效
+// 支持热更新的项：games、mq.retry；日志级别及标签见 chat-logger.yaml
+func RestartRequired(old, next *Config) []string {
+	var changed []string
+	diff := func(key string, a, b any) {
+		if !reflect.DeepEqual(a, b) {
+			changed = append(changed, key)
+		}
+	}
+	diff("server", old.Server, next.Server)
+	diff("database", old.Database, next.Database)
+	diff("tracing", old.Tracing, next.Tracing)
+	diff("redis", old.Redis, next.Redis)
+
+	oldMQ, nextMQ := old.MQ, next.MQ
+	oldMQ.Retry, nextMQ.Retry = RetryConfig{}, RetryConfig{}
+	diff("mq", oldMQ, nextMQ)
+	return changed
+}
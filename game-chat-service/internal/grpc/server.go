@@ -17,19 +17,7 @@ func NewServer(svc *service.ChatService) *Server {
 }
 
 func (s *Server) ValidateAuthToken(ctx context.Context, req *chat.AuthTokenRequest) (*chat.UserIdentity, error) {
-    // Stage 2: Mock implementation
-    // In real world, this calls GLS or checks Redis/Token Service
-    
-    // Mock: Token "123" -> User 1001, Game "mmo"
-    if req.Token == "123" {
-        return &chat.UserIdentity{
-            UserId: 1001,
-            GameId: "mmo",
-            Valid:  true,
-        }, nil
-    }
-    
-    return &chat.UserIdentity{Valid: false}, nil
+    return s.svc.ValidateAuthToken(ctx, req.Token)
 }
 
 func (s *Server) SendSystemBroadcast(ctx context.Context, req *chat.SystemBroadcastRequest) (*chat.Empty, error) {
@@ -1,25 +1,28 @@
+// Package logger Chat Service 的日志标签及全局 Logger，实现见 game-pkg/logging
 package logger
 
 import (
-	"log"
-	"os"
+	"context"
+	"log/slog"
 	"sync"
+
+	"game-pkg/logging"
 )
 
-// LogLevel 定义日志级别
-type LogLevel int
+type (
+	LogLevel = logging.LogLevel
+	LogTag   = logging.LogTag
+)
 
 const (
-	DEBUG LogLevel = iota
-	INFO
-	WARN
-	ERROR
+	DEBUG = logging.DEBUG
+	INFO  = logging.INFO
+	WARN  = logging.WARN
+	ERROR = logging.ERROR
 )
 
-// LogTag 定义日志标签
-type LogTag string
-
 const (
+	TagSystem    LogTag = "SYSTEM"    // 进程启动、配置及停止等运行状态
 	TagService   LogTag = "SERVICE"   // 服务逻辑相关
 	TagDB        LogTag = "DB"        // 数据库相关
 	TagMQ        LogTag = "MQ"        // 消息队列相关
@@ -28,96 +31,59 @@ const (
 	TagPerf      LogTag = "PERF"      // 性能统计相关
 )
 
-// Logger 可配置的日志记录器
-type Logger struct {
-	mu          sync.RWMutex
-	enabledTags map[LogTag]bool
-	minLevel    LogLevel
-	logger      *log.Logger
-}
+// KnownTags 所有预定义标签
+var KnownTags = []LogTag{TagSystem, TagService, TagDB, TagMQ, TagTransport, TagHub, TagPerf}
+
+// defaultTags 默认启用的标签
+var defaultTags = []LogTag{TagSystem, TagService, TagMQ}
 
 var (
-	defaultLogger *Logger
+	defaultLogger *logging.Logger
 	once          sync.Once
 )
 
 // Init 初始化全局日志记录器
 func Init() {
 	once.Do(func() {
-		defaultLogger = &Logger{
-			enabledTags: make(map[LogTag]bool),
-			minLevel:    INFO,
-			logger:      log.New(os.Stdout, "", log.LstdFlags),
-		}
-
-		// 默认启用的标签
-		defaultLogger.EnableTag(TagService)
-		defaultLogger.EnableTag(TagMQ)
+		defaultLogger = logging.New(KnownTags, defaultTags...)
 	})
 }
 
-// EnableTag 启用指定标签的日志
-func (l *Logger) EnableTag(tag LogTag) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	l.enabledTags[tag] = true
-}
-
-// DisableTag 禁用指定标签的日志
-func (l *Logger) DisableTag(tag LogTag) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	l.enabledTags[tag] = false
-}
-
-// IsTagEnabled 检查标签是否启用
-func (l *Logger) IsTagEnabled(tag LogTag) bool {
-	l.mu.RLock()
-	defer l.mu.RUnlock()
-	return l.enabledTags[tag]
-}
-
-// SetLevel 设置最小日志级别
-func (l *Logger) SetLevel(level LogLevel) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	l.minLevel = level
-}
-
-// Debug 输出调试日志
-func (l *Logger) Debug(tag LogTag, format string, v ...interface{}) {
-	if l.IsTagEnabled(tag) && l.minLevel <= DEBUG {
-		l.logger.Printf("[DEBUG][%s] "+format, append([]interface{}{tag}, v...)...)
-	}
-}
-
-// Info 输出信息日志
-func (l *Logger) Info(tag LogTag, format string, v ...interface{}) {
-	if l.IsTagEnabled(tag) && l.minLevel <= INFO {
-		l.logger.Printf("[INFO][%s] "+format, append([]interface{}{tag}, v...)...)
-	}
-}
+// Load 加载 {name}.yaml 日志配置并在文件变化时热更新，见 logging.Logger.Load
+func Load(name string) error { return defaultLogger.Load(name) }
 
-// Warn 输出警告日志
-func (l *Logger) Warn(tag LogTag, format string, v ...interface{}) {
-	if l.IsTagEnabled(tag) && l.minLevel <= WARN {
-		l.logger.Printf("[WARN][%s] "+format, append([]interface{}{tag}, v...)...)
-	}
-}
-
-// Error 输出错误日志
-func (l *Logger) Error(tag LogTag, format string, v ...interface{}) {
-	if l.IsTagEnabled(tag) && l.minLevel <= ERROR {
-		l.logger.Printf("[ERROR][%s] "+format, append([]interface{}{tag}, v...)...)
-	}
-}
+// ParseLevel 解析日志级别: debug / info / warn / error（不区分大小写）
+func ParseLevel(s string) (LogLevel, error) { return logging.ParseLevel(s) }
 
 // 全局便捷函数
 func EnableTag(tag LogTag)    { defaultLogger.EnableTag(tag) }
 func DisableTag(tag LogTag)   { defaultLogger.DisableTag(tag) }
 func SetLevel(level LogLevel) { defaultLogger.SetLevel(level) }
+func Level() LogLevel         { return defaultLogger.Level() }
+func Tags() map[LogTag]bool   { return defaultLogger.Tags() }
+
+func Enabled(tag LogTag, level LogLevel) bool { return defaultLogger.Enabled(tag, level) }
 
 func Debug(tag LogTag, format string, v ...interface{}) { defaultLogger.Debug(tag, format, v...) }
 func Info(tag LogTag, format string, v ...interface{})  { defaultLogger.Info(tag, format, v...) }
 func Warn(tag LogTag, format string, v ...interface{})  { defaultLogger.Warn(tag, format, v...) }
 func Error(tag LogTag, format string, v ...interface{}) { defaultLogger.Error(tag, format, v...) }
+func Fatal(tag LogTag, format string, v ...interface{}) { defaultLogger.Fatal(tag, format, v...) }
+
+// 结构化日志便捷函数，见 logging.Logger.Log
+func DebugCtx(ctx context.Context, tag LogTag, msg string, args ...any) {
+	defaultLogger.Log(ctx, DEBUG, tag, msg, args...)
+}
+func InfoCtx(ctx context.Context, tag LogTag, msg string, args ...any) {
+	defaultLogger.Log(ctx, INFO, tag, msg, args...)
+}
+func WarnCtx(ctx context.Context, tag LogTag, msg string, args ...any) {
+	defaultLogger.Log(ctx, WARN, tag, msg, args...)
+}
+func ErrorCtx(ctx context.Context, tag LogTag, msg string, args ...any) {
+	defaultLogger.Log(ctx, ERROR, tag, msg, args...)
+}
+
+// 结构化日志公共字段，见 game-pkg/logging
+func UserID(id int32) slog.Attr  { return logging.UserID(id) }
+func GameID(id string) slog.Attr { return logging.GameID(id) }
@@ -0,0 +1,226 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"game-pkg/mq"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const namespace = "chat"
+
+var latencyBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5}
+
+var (
+	registry = prometheus.NewRegistry()
+
+	messagesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_total",
+		Help:      "Chat requests handled",
+	}, []string{"game_id", "message_type"})
+
+	messageErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "message_errors_total",
+		Help:      "Chat requests that failed (e.g. save buffer full)",
+	}, []string{"game_id", "message_type"})
+
+	messageLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "message_latency_seconds",
+		Help:      "HandleRequest latency",
+		Buckets:   latencyBuckets,
+	}, []string{"game_id", "message_type"})
+
+	saveDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "save_dropped_total",
+		Help:      "Messages rejected because the save buffer was full",
+	})
+
+	dbInsertLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "insert_duration_seconds",
+		Help:      "Latency of one batched message insert",
+		Buckets:   latencyBuckets,
+	})
+
+	dbBatchSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "insert_batch_size",
+		Help:      "Messages written per batched insert",
+		Buckets:   []float64{1, 2, 5, 10, 20, 50, 100},
+	})
+
+	dbInsertErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "insert_errors_total",
+		Help:      "Batched inserts that failed",
+	})
+
+	dbMessagesLost = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "messages_lost_total",
+		Help:      "Messages not persisted (failed rows after per-row retry, or whole groups that could not be routed or migrated)",
+	})
+
+	mqPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "mq",
+		Name:      "published_total",
+		Help:      "Messages published to MQ (or pushed over direct gateway connections)",
+	}, []string{"topic", "result"})
+
+	mqPublishLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "mq",
+		Name:      "publish_duration_seconds",
+		Help:      "MQ publish latency",
+		Buckets:   latencyBuckets,
+	}, []string{"topic"})
+
+	grpcRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "requests_total",
+		Help:      "gRPC calls handled, by method and status code",
+	}, []string{"method", "code"})
+
+	grpcLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "request_duration_seconds",
+		Help:      "gRPC call latency",
+		Buckets:   latencyBuckets,
+	}, []string{"method"})
+
+	// saveQueue 持久化队列深度 / 容量来源（由 ChatService 提供）
+	saveQueue atomic.Pointer[func() (depth, capacity int)]
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		messagesTotal, messageErrors, messageLatency,
+		saveDropped, dbInsertLatency, dbBatchSize, dbInsertErrors, dbMessagesLost,
+		mqPublished, mqPublishLatency,
+		grpcRequests, grpcLatency,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "save_queue_depth", Help: "Messages waiting in the save buffer",
+		}, func() float64 {
+			depth, _ := saveQueueStats()
+			return float64(depth)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "save_queue_capacity", Help: "Save buffer capacity",
+		}, func() float64 {
+			_, capacity := saveQueueStats()
+			return float64(capacity)
+		}),
+	)
+}
+
+// Handler 返回 /metrics 的 HTTP Handler
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest 记录一次 HandleRequest 的结果及耗时
+func ObserveRequest(gameID, msgType string, d time.Duration, err error) {
+	if gameID == "" {
+		gameID = "unknown"
+	}
+	messagesTotal.WithLabelValues(gameID, msgType).Inc()
+	messageLatency.WithLabelValues(gameID, msgType).Observe(d.Seconds())
+	if err != nil {
+		messageErrors.WithLabelValues(gameID, msgType).Inc()
+	}
+}
+
+// SetSaveQueueSource 设置持久化队列深度 / 容量来源（采集时调用）
+func SetSaveQueueSource(fn func() (depth, capacity int)) {
+	saveQueue.Store(&fn)
+}
+
+func saveQueueStats() (int, int) {
+	if fn := saveQueue.Load(); fn != nil {
+		return (*fn)()
+	}
+	return 0, 0
+}
+
+// IncrementSaveDropped 持久化队列已满，消息被拒绝
+func IncrementSaveDropped() {
+	saveDropped.Inc()
+}
+
+// ObserveDBInsert 记录一次批量写入的条数、耗时及结果，failed 为未写入（丢失）的条数
+func ObserveDBInsert(batch, failed int, d time.Duration, err error) {
+	dbBatchSize.Observe(float64(batch))
+	dbInsertLatency.Observe(d.Seconds())
+	if err != nil {
+		dbInsertErrors.Inc()
+	}
+	dbMessagesLost.Add(float64(failed))
+}
+
+// replyTopicLabel RPC 回复的 Topic 由请求方指定（含实例 ID，且可为任意值），统计时归为同一标签
+const replyTopicLabel = "rpc:reply"
+
+// InstrumentProducer 包装 mq.Producer，统计发布数、失败数及耗时
+// topics 为服务自身发布的固定 Topic，按原样作为标签；其余 Topic（RPC 回复）归为 rpc:reply，避免标签无限增长
+func InstrumentProducer(p mq.Producer, topics ...string) mq.Producer {
+	known := make(map[string]bool, len(topics))
+	for _, t := range topics {
+		if t != "" {
+			known[t] = true
+		}
+	}
+	return instrumentedProducer{Producer: p, known: known}
+}
+
+type instrumentedProducer struct {
+	mq.Producer
+	known map[string]bool
+}
+
+func (p instrumentedProducer) Publish(topic string, payload []byte) error {
+	start := time.Now()
+	err := p.Producer.Publish(topic, payload)
+	label := replyTopicLabel
+	if p.known[topic] {
+		label = topic
+	}
+	mqPublishLatency.WithLabelValues(label).Observe(time.Since(start).Seconds())
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	mqPublished.WithLabelValues(label, result).Inc()
+	return err
+}
+
+// UnaryServerInterceptor 统计 gRPC 调用数（按状态码）及耗时
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		grpcLatency.WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+		grpcRequests.WithLabelValues(info.FullMethod, status.Code(err).String()).Inc()
+		return resp, err
+	}
+}
@@ -3,7 +3,8 @@ package repository
 import (
     "context"
     "fmt"
-    "log"
+
+    "game-chat-service/internal/logger"
 
     "github.com/jackc/pgx/v4/pgxpool"
 )
@@ -28,7 +29,7 @@ func NewDatabase(dsn string) (*Database, error) {
         return nil, fmt.Errorf("unable to ping database: %w", err)
     }
 
-    log.Println("Connected to PostgreSQL")
+    logger.Info(logger.TagDB, "Connected to PostgreSQL")
     return &Database{Pool: pool}, nil
 }
 
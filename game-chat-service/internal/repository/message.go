@@ -2,25 +2,26 @@ package repository
 
 import (
     "context"
+    "errors"
     "fmt"
+    "strings"
     
     "game-protocols/chat"
+
+    "github.com/jackc/pgconn"
+    "github.com/jackc/pgx/v4"
 )
 
-func (db *Database) SaveMessage(ctx context.Context, req *chat.ChatRequest) (int64, error) {
-    // 简单实现：将 protobuf 序列化后存储（实际应该拆分字段）
-    // 或者只存储元数据
-    
-    // 假设表结构：
-    // CREATE TABLE messages (id BIGSERIAL PRIMARY KEY, game_id VARCHAR, sender_id INT, receiver_id INT, content TEXT, created_at TIMESTAMP);
-    
-    // 这里我们先模拟一下
-    var id int64
-    query := `
-        INSERT INTO messages (game_id, sender_id, receiver_id, channel_id, content, created_at)
+// insertMessage 写入一条消息，%s 为已转义的表名（表结构见 migrate.go）
+const insertMessage = `
+        INSERT INTO %s (game_id, sender_id, receiver_id, channel_id, content, created_at)
         VALUES ($1, $2, $3, $4, $5, NOW())
-        RETURNING id
     `
+
+// SaveMessage 写入一条消息到 table，返回消息 ID
+func (db *Database) SaveMessage(ctx context.Context, table string, req *chat.ChatRequest) (int64, error) {
+    var id int64
+    query := fmt.Sprintf(insertMessage, ident(table)) + "RETURNING id"
     // assuming Base is always present
     gameID := req.Base.GameId
     senderID := req.Base.UserId
@@ -35,3 +36,63 @@ func (db *Database) SaveMessage(ctx context.Context, req *chat.ChatRequest) (int
 
     return id, nil
 }
+
+// SaveMessages 批量写入消息到 table，返回写入失败的条数
+// 整批单次往返（隐式事务，任一条失败则整批回滚）；因数据错误失败时逐条重试，一条坏数据只丢失该条，
+// 连接断开、DB 故障等其他错误整批失败（逐条重试只会加重 DB 负担）
+func (db *Database) SaveMessages(ctx context.Context, table string, reqs []*chat.ChatRequest) (int, error) {
+    query := fmt.Sprintf(insertMessage, ident(table))
+    batch := &pgx.Batch{}
+    for _, req := range reqs {
+        batch.Queue(query, req.Base.GameId, req.Base.UserId, req.ReceiverId, req.ChannelId, req.Content)
+    }
+
+    err := db.sendBatch(ctx, batch, len(reqs))
+    if err == nil {
+        return 0, nil
+    }
+    if len(reqs) == 1 || !dataError(err) {
+        return len(reqs), fmt.Errorf("failed to insert %d message(s): %w", len(reqs), err)
+    }
+
+    failed := 0
+    var firstErr error
+    for i, req := range reqs {
+        if _, err := db.Pool.Exec(ctx, query, req.Base.GameId, req.Base.UserId, req.ReceiverId, req.ChannelId, req.Content); err != nil {
+            if !dataError(err) {
+                // 重试期间 DB 故障或超时：剩余的消息不再逐条尝试
+                failed += len(reqs) - i
+                firstErr = err
+                break
+            }
+            failed++
+            if firstErr == nil {
+                firstErr = err
+            }
+        }
+    }
+    if failed > 0 {
+        return failed, fmt.Errorf("failed to insert %d/%d message(s) after batch error (%v): %w", failed, len(reqs), err, firstErr)
+    }
+    return 0, nil
+}
+
+// dataError 是否为单条数据导致的错误（SQLSTATE 22 数据异常 / 23 违反约束），换一条数据可能成功
+func dataError(err error) bool {
+    var pgErr *pgconn.PgError
+    if !errors.As(err, &pgErr) {
+        return false
+    }
+    return strings.HasPrefix(pgErr.Code, "22") || strings.HasPrefix(pgErr.Code, "23")
+}
+
+func (db *Database) sendBatch(ctx context.Context, batch *pgx.Batch, n int) error {
+    br := db.Pool.SendBatch(ctx, batch)
+    defer br.Close()
+    for i := 0; i < n; i++ {
+        if _, err := br.Exec(); err != nil {
+            return err
+        }
+    }
+    return nil
+}
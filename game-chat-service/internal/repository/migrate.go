@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"game-chat-service/internal/logger"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// migration 消息表的一次 schema 变更，stmts 根据表名生成 SQL
+// 共享表、独立表及独立库中的表使用同一组迁移（结构相同，便于在存储方式之间迁移数据），
+// 已应用的版本按表记录在 schema_migrations 中；只能追加新版本，不能修改已发布的版本
+type migration struct {
+	version int
+	desc    string
+	stmts   func(table string) []string
+
+	// concurrent 语句不能在事务中执行（CREATE INDEX CONCURRENTLY，建索引期间不阻塞写入），逐条执行后再记录版本；
+	// 语句须可重复执行（IF NOT EXISTS），中途失败时下次迁移先删除 indexes 中残留的无效索引再重试
+	concurrent bool
+	indexes    func(table string) []string
+}
+
+// messageIndexes v2 创建的索引：名称后缀及列（查询总是带 game_id，索引以 game_id 开头：私聊收件箱、频道历史、发送记录）
+var messageIndexes = []struct{ suffix, columns string }{
+	{"_receiver_idx", "game_id, receiver_id, created_at"},
+	{"_channel_idx", "game_id, channel_id, created_at"},
+	{"_sender_idx", "game_id, sender_id, created_at"},
+}
+
+func messageIndexNames(table string) []string {
+	names := make([]string, 0, len(messageIndexes))
+	for _, idx := range messageIndexes {
+		names = append(names, table+idx.suffix)
+	}
+	return names
+}
+
+var messageMigrations = []migration{
+	{1, "create table", func(table string) []string {
+		return []string{`
+        CREATE TABLE IF NOT EXISTS ` + ident(table) + ` (
+            id          BIGSERIAL PRIMARY KEY,
+            game_id     VARCHAR(64) NOT NULL,
+            sender_id   INT NOT NULL,
+            receiver_id INT NOT NULL DEFAULT 0,
+            channel_id  INT NOT NULL DEFAULT 0,
+            content     TEXT NOT NULL,
+            created_at  TIMESTAMP NOT NULL DEFAULT NOW()
+        )`}
+	}, false, nil},
+	{2, "game_id leading indexes", func(table string) []string {
+		stmts := make([]string, 0, len(messageIndexes))
+		for _, idx := range messageIndexes {
+			stmts = append(stmts, `CREATE INDEX CONCURRENTLY IF NOT EXISTS `+ident(table+idx.suffix)+` ON `+ident(table)+` (`+idx.columns+`)`)
+		}
+		return stmts
+	}, true, messageIndexNames},
+}
+
+// MaxTableNameLen 消息表名长度上限：PostgreSQL 标识符超过 63 字节会被截断（派生的索引名可能冲突）
+const MaxTableNameLen = 63 - len("_receiver_idx")
+
+// ident 转义标识符（GameID 可含 "-" 及大写字母）
+func ident(name string) string {
+	return pgx.Identifier{name}.Sanitize()
+}
+
+// Migrate 将消息表 table 迁移到最新版本（不存在则创建）
+// 在同一连接上持有按表的 session 级 advisory lock 执行：多个实例同时迁移同一张表时依次执行，不同表互不阻塞；
+// 不使用事务级锁：CREATE INDEX CONCURRENTLY 会等待持有快照的事务结束，在持锁事务中执行将一直等待
+func (db *Database) Migrate(ctx context.Context, table string) error {
+	if len(table) > MaxTableNameLen {
+		return fmt.Errorf("table name %q too long (max %d)", table, MaxTableNameLen)
+	}
+
+	conn, err := db.Pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate %s: %w", table, err)
+	}
+	defer conn.Release()
+
+	if err := createSchemaMigrations(ctx, conn); err != nil {
+		return fmt.Errorf("migrate %s: create schema_migrations: %w", table, err)
+	}
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock(hashtext('schema_migrations'), hashtext($1))`, table); err != nil {
+		return fmt.Errorf("migrate %s: lock: %w", table, err)
+	}
+	defer func() {
+		// ctx 可能已取消，解锁使用独立的 context；解锁失败时关闭连接（session 结束即释放锁）
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock(hashtext('schema_migrations'), hashtext($1))`, table); err != nil {
+			conn.Conn().Close(context.Background())
+		}
+	}()
+
+	var current int
+	if err := conn.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations WHERE table_name = $1`, table).Scan(&current); err != nil {
+		return fmt.Errorf("migrate %s: read version: %w", table, err)
+	}
+
+	for _, m := range messageMigrations {
+		if m.version <= current {
+			continue
+		}
+		apply := migrateInTx
+		if m.concurrent {
+			apply = migrateConcurrently
+		}
+		if err := apply(ctx, conn, table, m); err != nil {
+			return err
+		}
+		logger.Info(logger.TagDB, "Migrated table %s to v%d (%s)", table, m.version, m.desc)
+	}
+	return nil
+}
+
+// createSchemaMigrations 创建版本记录表，在全局事务级锁下执行（并发的 CREATE TABLE IF NOT EXISTS 可能冲突）
+func createSchemaMigrations(ctx context.Context, conn *pgxpool.Conn) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('schema_migrations'))`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            table_name TEXT NOT NULL,
+            version    INT NOT NULL,
+            applied_at TIMESTAMP NOT NULL DEFAULT NOW(),
+            PRIMARY KEY (table_name, version)
+        )`); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// migrateInTx 在事务中执行迁移并记录版本
+func migrateInTx(ctx context.Context, conn *pgxpool.Conn, table string, m migration) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate %s to v%d: %w", table, m.version, err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, stmt := range m.stmts(table) {
+		if _, err := tx.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("migrate %s to v%d (%s): %w", table, m.version, m.desc, err)
+		}
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (table_name, version) VALUES ($1, $2)`, table, m.version); err != nil {
+		return fmt.Errorf("migrate %s to v%d: record version: %w", table, m.version, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("migrate %s to v%d: commit: %w", table, m.version, err)
+	}
+	return nil
+}
+
+// migrateConcurrently 在事务外逐条执行迁移，全部成功后记录版本
+// 先删除上次中途失败遗留的无效索引（CONCURRENTLY 失败时索引保留为 INVALID，IF NOT EXISTS 会跳过它）；
+// 只处理本迁移创建的索引：其他会话（如 DBA）正在 CONCURRENTLY 创建的索引在完成前同样是 INVALID
+func migrateConcurrently(ctx context.Context, conn *pgxpool.Conn, table string, m migration) error {
+	var names []string
+	if m.indexes != nil {
+		names = m.indexes(table)
+	}
+	rows, err := conn.Query(ctx, `
+        SELECT c.relname FROM pg_index i JOIN pg_class c ON c.oid = i.indexrelid
+        WHERE i.indrelid = $1::regclass AND NOT i.indisvalid AND c.relname = ANY($2)`, ident(table), names)
+	if err != nil {
+		return fmt.Errorf("migrate %s to v%d: list invalid indexes: %w", table, m.version, err)
+	}
+	var invalid []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("migrate %s to v%d: list invalid indexes: %w", table, m.version, err)
+		}
+		invalid = append(invalid, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("migrate %s to v%d: list invalid indexes: %w", table, m.version, err)
+	}
+	for _, name := range invalid {
+		if _, err := conn.Exec(ctx, `DROP INDEX CONCURRENTLY IF EXISTS `+ident(name)); err != nil {
+			return fmt.Errorf("migrate %s to v%d: drop invalid index %s: %w", table, m.version, name, err)
+		}
+		logger.Warn(logger.TagDB, "Dropped invalid index %s left by an interrupted migration", name)
+	}
+
+	for _, stmt := range m.stmts(table) {
+		if _, err := conn.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("migrate %s to v%d (%s): %w", table, m.version, m.desc, err)
+		}
+	}
+	if _, err := conn.Exec(ctx, `INSERT INTO schema_migrations (table_name, version) VALUES ($1, $2)`, table, m.version); err != nil {
+		return fmt.Errorf("migrate %s to v%d: record version: %w", table, m.version, err)
+	}
+	return nil
+}
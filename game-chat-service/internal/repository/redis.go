@@ -3,9 +3,10 @@ package repository
 import (
     "context"
     "fmt"
-    "log"
     "time"
 
+    "game-chat-service/internal/logger"
+
     "github.com/go-redis/redis/v8"
 )
 
@@ -25,7 +26,7 @@ func NewRedisClient(addr, password string) (*RedisClient, error) {
         return nil, fmt.Errorf("failed to connect to redis: %w", err)
     }
 
-    log.Println("Connected to Redis")
+    logger.Info(logger.TagDB, "Connected to Redis")
     return &RedisClient{Client: rdb}, nil
 }
 
@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"game-pkg/registry"
+	"game-protocols/chat"
+)
+
+// Storage 消息在库中的存放方式
+type Storage int
+
+const (
+	StorageShared Storage = iota // 所有游戏共用 messages 表，以 game_id 列区分（索引以 game_id 开头）
+	StorageTable                 // 每个游戏独立的 messages_{GameID} 表
+)
+
+// SharedTable StorageShared 使用的表名
+const SharedTable = "messages"
+
+// ParseStorage 解析配置中的存储方式（shared / table）
+func ParseStorage(s string) (Storage, error) {
+	switch s {
+	case "", "shared":
+		return StorageShared, nil
+	case "table":
+		return StorageTable, nil
+	default:
+		return StorageShared, fmt.Errorf("unknown storage: %q", s)
+	}
+}
+
+func (s Storage) String() string {
+	switch s {
+	case StorageShared:
+		return "shared"
+	case StorageTable:
+		return "table"
+	default:
+		return fmt.Sprintf("Storage(%d)", int(s))
+	}
+}
+
+// Table 返回游戏消息所在的表名
+func (s Storage) Table(gameID string) string {
+	if s == StorageTable {
+		return SharedTable + "_" + gameID
+	}
+	return SharedTable
+}
+
+// target 消息写入的库和表
+type target struct {
+	db    *Database
+	table string
+}
+
+// gameStorage 单个游戏的存储覆盖配置
+type gameStorage struct {
+	storage Storage
+
+	dedicated bool      // 使用独立库
+	db        *Database // 独立库，连接失败时为 nil（不会回落到默认库）
+}
+
+// MessageStore 按 req.Base.GameId 将消息路由到对应的库和表：
+// 默认写入默认库，存储方式为 storage；SetGame / SetGameDatabase 可为单个游戏指定存储方式及独立库
+type MessageStore struct {
+	db      *Database // 默认库，连接失败时为 nil
+	storage Storage
+	games   map[string]gameStorage
+
+	// registry 非空时 StorageTable 只为已注册的游戏建表，避免任意 GameID 在库中建表
+	registry *registry.Registry
+
+	// migrate 为 true 时首次写入某个表前执行 schema 迁移
+	migrate      bool
+	migrated     sync.Map // target -> struct{}
+	migrateLocks sync.Map // target -> *sync.Mutex，按表串行化迁移，不同表的迁移（如建索引）互不阻塞
+}
+
+// NewMessageStore 创建消息存储，db 为默认库（可为 nil，写入时报错）
+func NewMessageStore(db *Database, storage Storage, migrate bool) *MessageStore {
+	return &MessageStore{
+		db:      db,
+		storage: storage,
+		games:   make(map[string]gameStorage),
+		migrate: migrate,
+	}
+}
+
+// SetGame 为游戏指定存储方式；需在启动前调用
+func (s *MessageStore) SetGame(gameID string, storage Storage) {
+	gs := s.games[gameID]
+	gs.storage = storage
+	s.games[gameID] = gs
+}
+
+// SetGameDatabase 游戏消息写入独立库 db（连接失败时传 nil，写入报错而不是写入默认库）；需在启动前调用
+func (s *MessageStore) SetGameDatabase(gameID string, db *Database) {
+	gs, ok := s.games[gameID]
+	if !ok {
+		gs.storage = s.storage
+	}
+	gs.dedicated, gs.db = true, db
+	s.games[gameID] = gs
+}
+
+// SetRegistry 设置游戏注册表，StorageTable 拒绝未注册的游戏；需在启动前调用
+func (s *MessageStore) SetRegistry(games *registry.Registry) {
+	s.registry = games
+}
+
+// target 返回游戏消息写入的库和表
+func (s *MessageStore) target(gameID string) (target, error) {
+	db, storage := s.db, s.storage
+	if gs, ok := s.games[gameID]; ok {
+		storage = gs.storage
+		if gs.dedicated {
+			db = gs.db
+		}
+	}
+	if storage == StorageTable {
+		if err := registry.ValidID(gameID); err != nil {
+			return target{}, err
+		}
+		if s.registry != nil && !s.registry.Has(gameID) {
+			return target{}, fmt.Errorf("unknown game_id: %s", gameID)
+		}
+	}
+	if db == nil {
+		return target{}, fmt.Errorf("database for game %q not connected", gameID)
+	}
+	return target{db: db, table: storage.Table(gameID)}, nil
+}
+
+// ensure 首次写入表前执行迁移，成功后不再重复
+func (s *MessageStore) ensure(ctx context.Context, t target) error {
+	if !s.migrate {
+		return nil
+	}
+	if _, ok := s.migrated.Load(t); ok {
+		return nil
+	}
+	mu, _ := s.migrateLocks.LoadOrStore(t, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	defer mu.(*sync.Mutex).Unlock()
+	if _, ok := s.migrated.Load(t); ok {
+		return nil
+	}
+	if err := t.db.Migrate(ctx, t.table); err != nil {
+		return err
+	}
+	s.migrated.Store(t, struct{}{})
+	return nil
+}
+
+// Migrate 迁移 gameIDs（通常为配置中的游戏）对应的表，返回所有失败；未开启迁移时不执行
+// 启动后新增的游戏在首次写入时迁移
+func (s *MessageStore) Migrate(ctx context.Context, gameIDs []string) error {
+	var errs []error
+	for _, id := range gameIDs {
+		t, err := s.target(id)
+		if err == nil {
+			err = s.ensure(ctx, t)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("game %s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SaveMessage 写入一条消息，返回消息 ID
+func (s *MessageStore) SaveMessage(ctx context.Context, req *chat.ChatRequest) (int64, error) {
+	t, err := s.target(req.Base.GameId)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.ensure(ctx, t); err != nil {
+		return 0, err
+	}
+	return t.db.SaveMessage(ctx, t.table, req)
+}
+
+// SaveMessages 批量写入消息：按目标库表分组，每组单次往返；各组独立成败，返回写入失败的条数及所有失败组的错误
+func (s *MessageStore) SaveMessages(ctx context.Context, reqs []*chat.ChatRequest) (int, error) {
+	groups := make(map[target][]*chat.ChatRequest)
+	var order []target
+	var errs []error
+	failed := 0
+	for _, req := range reqs {
+		t, err := s.target(req.Base.GameId)
+		if err != nil {
+			failed++
+			errs = append(errs, err)
+			continue
+		}
+		if _, ok := groups[t]; !ok {
+			order = append(order, t)
+		}
+		groups[t] = append(groups[t], req)
+	}
+
+	for _, t := range order {
+		n := len(groups[t]) // 迁移失败时整组未写入
+		err := s.ensure(ctx, t)
+		if err == nil {
+			n, err = t.db.SaveMessages(ctx, t.table, groups[t])
+		}
+		if err != nil {
+			failed += n
+			errs = append(errs, fmt.Errorf("%s: %d/%d message(s): %w", t.table, n, len(groups[t]), err))
+		}
+	}
+	return failed, errors.Join(errs...)
+}
+
+// Ping 检查默认库及各游戏独立库的连接
+func (s *MessageStore) Ping(ctx context.Context) error {
+	if s.db == nil {
+		return errors.New("not connected")
+	}
+	if err := s.db.Pool.Ping(ctx); err != nil {
+		return err
+	}
+	for id, gs := range s.games {
+		if !gs.dedicated {
+			continue
+		}
+		if gs.db == nil {
+			return fmt.Errorf("game %s: not connected", id)
+		}
+		if err := gs.db.Pool.Ping(ctx); err != nil {
+			return fmt.Errorf("game %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// Close 关闭各游戏的独立库（默认库由创建者关闭）
+func (s *MessageStore) Close() {
+	for _, gs := range s.games {
+		if gs.db != nil {
+			gs.db.Close()
+		}
+	}
+}
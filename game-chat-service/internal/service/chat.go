@@ -2,35 +2,62 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"game-chat-service/internal/hub"
 	"game-chat-service/internal/logger"
-	"game-chat-service/internal/mq" // New import
+	"game-chat-service/internal/metrics"
 	"game-chat-service/internal/repository"
+	"game-pkg/envelope"
+	"game-pkg/mq"
+	"game-pkg/tracing"
 	"game-protocols/chat"
+	"game-protocols/common"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/protobuf/proto"
 )
 
+var tracer = tracing.Tracer("game-chat-service/service")
+
+// saveBatchSize 每个 DB worker 单次批量写入的最大消息数
+const saveBatchSize = 100
+
+// saveTimeout 单批写入（含失败后的逐条重试）的超时，DB 故障时 worker 不会无限阻塞
+const saveTimeout = 5 * time.Second
+
 type ChatService struct {
 	hub      *hub.Hub
-	db       *repository.Database
-	producer mq.Producer // Changed from GatewaySender
+	store    *repository.MessageStore // 按 GameID 路由到各游戏的库表
+	producer mq.Producer              // Changed from GatewaySender
 	saveChan chan *chat.ChatRequest
+
+	// saveMu 保护 saveChan 的关闭：入队持读锁，Shutdown 持写锁
+	saveMu   sync.RWMutex
+	closed   bool
+	workerWG sync.WaitGroup
 }
 
-func NewChatService(h *hub.Hub, db *repository.Database) *ChatService {
+// ErrShuttingDown 服务下线中，不再接受新消息
+var ErrShuttingDown = errors.New("chat service shutting down")
+
+func NewChatService(h *hub.Hub, store *repository.MessageStore) *ChatService {
 	s := &ChatService{
 		hub:      h,
-		db:       db,
+		store:    store,
 		saveChan: make(chan *chat.ChatRequest, 20000), // Large buffer to absorb bursts
 	}
+	metrics.SetSaveQueueSource(s.SaveQueue)
 
 	// Start DB workers
 	// 50 workers to handle DB writes concurrently
 	for i := 0; i < 50; i++ {
+		s.workerWG.Add(1)
 		go s.dbWorker()
 	}
 
@@ -38,13 +65,121 @@ func NewChatService(h *hub.Hub, db *repository.Database) *ChatService {
 }
 
 // dbWorker consumes requests from channel and persists them
+// 队列中已积压的消息合并为一批写入（最多 saveBatchSize 条），减少 DB 往返
 func (s *ChatService) dbWorker() {
+	defer s.workerWG.Done()
+	batch := make([]*chat.ChatRequest, 0, saveBatchSize)
 	for req := range s.saveChan {
-		_, err := s.db.SaveMessage(context.Background(), req)
+		batch = append(batch[:0], req)
+	drain:
+		for len(batch) < saveBatchSize {
+			select {
+			case next, ok := <-s.saveChan:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+
+		start := time.Now()
+		span := startSaveSpan(batch)
+		ctx, cancel := context.WithTimeout(context.Background(), saveTimeout)
+		failed, err := s.store.SaveMessages(ctx, batch)
+		cancel()
+		metrics.ObserveDBInsert(len(batch), failed, time.Since(start), err)
+		if span != nil {
+			endSpan(span, err)
+		}
 		if err != nil {
-			logger.Error(logger.TagDB, "Async save failed | User: %d, Error: %v", req.Base.UserId, err)
+			logger.Error(logger.TagDB, "Async save failed | Messages: %d/%d, FirstUser: %d, Error: %v", failed, len(batch), batch[0].Base.UserId, err)
+		}
+	}
+}
+
+// startSaveSpan 批量写入合并了多个请求，Span 通过 Link 关联各请求的 chat.handle Span；
+// 批次中没有被追踪的请求时返回 nil
+func startSaveSpan(batch []*chat.ChatRequest) trace.Span {
+	var links []trace.Link
+	for _, req := range batch {
+		ctx := tracing.ContextWithIDs(context.Background(), req.GetMeta().GetTraceId(), req.GetMeta().GetSpanId())
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			links = append(links, trace.Link{SpanContext: sc})
 		}
 	}
+	if len(links) == 0 {
+		return nil
+	}
+	_, span := tracer.Start(context.Background(), "chat.db.save", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithLinks(links...), trace.WithAttributes(attribute.Int("db.batch_size", len(batch))))
+	return span
+}
+
+// TraceParent 返回请求 chat.handle Span 的 traceparent（HandleRequest 只在该 Span 被采样时写入 meta），
+// 用于响应 Envelope，使 Gateway 的投递 Span 接入同一条链路；未追踪时返回空
+func TraceParent(req *chat.ChatRequest) string {
+	return tracing.TraceParentFromIDs(req.GetMeta().GetTraceId(), req.GetMeta().GetSpanId())
+}
+
+// endSpan 记录错误并结束 Span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// enqueueSave 将消息放入持久化队列，返回临时消息 ID
+func (s *ChatService) enqueueSave(req *chat.ChatRequest) (int64, error) {
+	s.saveMu.RLock()
+	defer s.saveMu.RUnlock()
+	if s.closed {
+		return 0, ErrShuttingDown
+	}
+
+	select {
+	case s.saveChan <- req:
+		return time.Now().UnixNano(), nil // Temporary int64 ID
+	default:
+		metrics.IncrementSaveDropped()
+		logger.ErrorCtx(context.Background(), logger.TagService, "Save buffer full, message dropped",
+			logger.UserID(req.Base.UserId), logger.GameID(req.Base.GameId), "queue_cap", cap(s.saveChan))
+		return 0, fmt.Errorf("server overload (db buffer full)")
+	}
+}
+
+// Shutdown 停止接收新消息，等待 DB workers 写完 saveChan 中的剩余消息或 ctx 截止
+func (s *ChatService) Shutdown(ctx context.Context) error {
+	s.saveMu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.saveChan)
+	}
+	s.saveMu.Unlock()
+
+	logger.Info(logger.TagService, "Flushing save buffer | Pending: %d", len(s.saveChan))
+
+	done := make(chan struct{})
+	go func() {
+		s.workerWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		logger.Info(logger.TagService, "Save buffer flushed")
+		return nil
+	case <-ctx.Done():
+		logger.Error(logger.TagService, "Save buffer flush timed out | Unsaved: %d", len(s.saveChan))
+		return ctx.Err()
+	}
+}
+
+// SaveQueue 返回持久化队列当前深度及容量
+func (s *ChatService) SaveQueue() (depth, capacity int) {
+	return len(s.saveChan), cap(s.saveChan)
 }
 
 // SetProducer sets the MQ Producer (e.g. Redis)
@@ -53,29 +188,51 @@ func (s *ChatService) SetProducer(p mq.Producer) {
 }
 
 // HandleRequest processes the incoming chat request from Gateway (or Client via Gateway)
-func (s *ChatService) HandleRequest(ctx context.Context, req *chat.ChatRequest) (*chat.ChatResponse, error) {
+func (s *ChatService) HandleRequest(ctx context.Context, req *chat.ChatRequest) (resp *chat.ChatResponse, err error) {
 	startTime := time.Now()
+
+	// Gateway 在 meta 中写入了转发 Span，以其为父 Span（是否采样由本服务决定）；
+	// 之后 meta 改写为本 Span（未采样时清空），供落库和响应关联
+	if meta := req.GetMeta(); meta != nil {
+		ctx = tracing.ContextWithIDs(ctx, meta.TraceId, meta.SpanId)
+		meta.TraceId, meta.SpanId = "", ""
+	}
+	ctx, span := tracer.Start(ctx, "chat.handle", trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("game.id", req.Base.GameId),
+			attribute.Int("user.id", int(req.Base.UserId)),
+			attribute.String("message.type", req.Type.String()),
+		))
+	if traceID, spanID, ok := tracing.IDs(ctx); ok {
+		if req.Meta == nil {
+			req.Meta = &common.MessageMeta{}
+		}
+		req.Meta.TraceId, req.Meta.SpanId = traceID, spanID
+	}
+	defer func() {
+		endSpan(span, err)
+		metrics.ObserveRequest(req.Base.GameId, req.Type.String(), time.Since(startTime), err)
+	}()
 	messageID := fmt.Sprintf("%d->%d:%s", req.Base.UserId, req.ReceiverId, req.Content[:min(20, len(req.Content))])
 
-	logger.Debug(logger.TagService, "Message received | From: %d, To: %d, Content: %s, MsgID: %s",
-		req.Base.UserId, req.ReceiverId, req.Content[:min(50, len(req.Content))], messageID)
+	if logger.Enabled(logger.TagService, logger.DEBUG) {
+		logger.DebugCtx(ctx, logger.TagService, "Message received",
+			logger.UserID(req.Base.UserId), logger.GameID(req.Base.GameId), "receiver_id", req.ReceiverId,
+			"type", req.Type.String(), "msg_id", messageID)
+	}
 
 	// 1. Persistence (Async)
 	// Push to channel, non-blocking if buffer has space
-	var msgID int64
-	select {
-	case s.saveChan <- req:
-		msgID = time.Now().UnixNano() // Temporary int64 ID
-	default:
-		logger.Error(logger.TagService, "Save buffer full | Dropping message from %d", req.Base.UserId)
-		return nil, fmt.Errorf("server overload (db buffer full)")
+	msgID, err := s.enqueueSave(req)
+	if err != nil {
+		return nil, err
 	}
 
 	// 2. Routing logic (via Hub)
 	s.hub.HandleMessage(ctx, req)
 
 	// Response for Sender (ACK)
-	resp := &chat.ChatResponse{
+	resp = &chat.ChatResponse{
 		Base:      req.Base,
 		Success:   true,
 		MessageId: msgID,
@@ -104,14 +261,21 @@ func (s *ChatService) HandleRequest(ctx context.Context, req *chat.ChatRequest)
 		logger.Debug(logger.TagMQ, "Preparing broadcast | From: %d, To: %d, MsgID: %s",
 			req.Base.UserId, req.ReceiverId, messageID)
 
-		// 直接序列化并发送（不包装 Envelope）
+		// 直接序列化并发送；被追踪时包装 Envelope 以携带 traceparent
 		broadcastBytes, err := proto.Marshal(broadcast)
 		if err != nil {
 			logger.Error(logger.TagMQ, "Failed to marshal broadcast | MsgID: %s, Error: %v", messageID, err)
 		} else {
 			sendStart := time.Now()
+			pubCtx, pubSpan := tracer.Start(ctx, "chat.broadcast", trace.WithSpanKind(trace.SpanKindProducer),
+				trace.WithAttributes(attribute.Int("target.user.id", int(req.ReceiverId))))
+			if tp := tracing.TraceParent(pubCtx); tp != "" {
+				broadcastBytes = (&envelope.Envelope{Route: envelope.RouteChat, TraceParent: tp, Payload: broadcastBytes}).Encode()
+			}
 			// 使用 Redis 发布
-			if err := s.producer.Publish("broadcast", broadcastBytes); err != nil {
+			err := s.producer.Publish(mq.TopicBroadcast, broadcastBytes)
+			endSpan(pubSpan, err)
+			if err != nil {
 				logger.Error(logger.TagMQ, "Failed to send broadcast | MsgID: %s, Error: %v", messageID, err)
 			} else {
 				logger.Debug(logger.TagMQ, "Broadcast sent via Redis | To: %d, Size: %d bytes, SendTime: %v, MsgID: %s",
@@ -120,12 +284,29 @@ func (s *ChatService) HandleRequest(ctx context.Context, req *chat.ChatRequest)
 		}
 	}
 
-	totalTime := time.Since(startTime)
-	logger.Debug(logger.TagPerf, "Message processing complete | MsgID: %s, TotalTime: %v", messageID, totalTime)
+	logger.DebugCtx(ctx, logger.TagPerf, "Message processing complete",
+		logger.UserID(req.Base.UserId), logger.GameID(req.Base.GameId), "msg_id", messageID, "duration", time.Since(startTime))
 
 	return resp, nil
 }
 
+// ValidateAuthToken 校验登录 Token
+func (s *ChatService) ValidateAuthToken(ctx context.Context, token string) (*chat.UserIdentity, error) {
+	// Stage 2: Mock implementation
+	// In real world, this calls GLS or checks Redis/Token Service
+
+	// Mock: Token "123" -> User 1001, Game "mmo"
+	if token == "123" {
+		return &chat.UserIdentity{
+			UserId: 1001,
+			GameId: "mmo",
+			Valid:  true,
+		}, nil
+	}
+
+	return &chat.UserIdentity{Valid: false}, nil
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -0,0 +1,140 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"game-chat-service/internal/logger"
+	"game-chat-service/internal/service"
+	"game-pkg/envelope"
+	"game-pkg/mq"
+	"game-protocols/chat"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// MQHandler 处理来自 MQ 的请求（Gateway 转发的 ChatRequest 及 RPC 调用）
+type MQHandler struct {
+	svc      *service.ChatService
+	producer mq.Producer
+}
+
+func NewMQHandler(svc *service.ChatService, producer mq.Producer) *MQHandler {
+	return &MQHandler{
+		svc:      svc,
+		producer: producer,
+	}
+}
+
+// Register 在 RPC 服务端注册 RPC Handler（聊天请求按游戏经 AddGame 注册）
+func (h *MQHandler) Register(srv *mq.RPCServer, opts ...mq.SubscribeOption) error {
+	if err := srv.Handle(mq.TopicAuthRPC, h.HandleAuth, opts...); err != nil {
+		return fmt.Errorf("subscribe %s: %w", mq.TopicAuthRPC, err)
+	}
+	return nil
+}
+
+// AddGame 订阅游戏的请求 Topic（game:request:{GameID}）
+func (h *MQHandler) AddGame(srv *mq.RPCServer, gameID string, opts ...mq.SubscribeOption) error {
+	topic := mq.RequestTopic(gameID)
+	if err := srv.Handle(topic, h.HandleChatRequest, opts...); err != nil {
+		return fmt.Errorf("subscribe %s: %w", topic, err)
+	}
+	return nil
+}
+
+// RemoveGame 取消订阅游戏的请求 Topic，已收到的请求仍会处理完
+func (h *MQHandler) RemoveGame(srv *mq.RPCServer, gameID string) error {
+	topic := mq.RequestTopic(gameID)
+	if err := srv.Unhandle(topic); err != nil {
+		return fmt.Errorf("unsubscribe %s: %w", topic, err)
+	}
+	return nil
+}
+
+// HandleChatRequest 处理 ChatRequest
+// RPC 调用直接返回 ChatResponse；fire-and-forget 请求的 ACK 通过 broadcast Topic 发回发送者
+func (h *MQHandler) HandleChatRequest(ctx context.Context, r *mq.Request) ([]byte, error) {
+	var req chat.ChatRequest
+	if err := proto.Unmarshal(r.Payload, &req); err != nil {
+		return nil, mq.Permanent(fmt.Errorf("unmarshal request: %w", err))
+	}
+	if req.Base == nil {
+		return nil, mq.Permanent(fmt.Errorf("missing base info"))
+	}
+
+	resp, err := h.svc.HandleRequest(ctx, &req)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, nil
+	}
+
+	// 路由信息
+	resp.TargetUserId = req.Base.UserId
+
+	respBytes, err := proto.Marshal(resp)
+	if err != nil {
+		return nil, fmt.Errorf("marshal response: %w", err)
+	}
+
+	if r.NeedsReply() {
+		return respBytes, nil
+	}
+
+	// 这里的 "broadcast" 其实是 "gateway_downstream" 的意思
+	// 所有的 Gateway 都会收到并路由；被追踪时包装 Envelope 以携带 traceparent
+	if tp := service.TraceParent(&req); tp != "" {
+		respBytes = (&envelope.Envelope{Route: envelope.RouteChat, TraceParent: tp, Payload: respBytes}).Encode()
+	}
+	if err := h.producer.Publish(mq.TopicBroadcast, respBytes); err != nil {
+		logger.Error(logger.TagMQ, "Failed to publish ACK | To: %d, Error: %v", resp.TargetUserId, err)
+	}
+	return nil, nil
+}
+
+// HandleFailure 请求最终处理失败时，向发送者回复失败 ChatResponse
+// RPC 调用方已通过错误回复得知失败，无需重复通知
+func (h *MQHandler) HandleFailure(ctx context.Context, r *mq.Request, cause error) {
+	if r.NeedsReply() || r.Topic == mq.TopicAuthRPC {
+		return
+	}
+
+	var req chat.ChatRequest
+	if err := proto.Unmarshal(r.Payload, &req); err != nil || req.Base == nil || req.Base.UserId == 0 {
+		// 无法确定发送者，只能依赖死信
+		return
+	}
+
+	resp := &chat.ChatResponse{
+		Base:         req.Base,
+		Success:      false,
+		ErrorMessage: cause.Error(),
+		Timestamp:    time.Now().Unix(),
+		TargetUserId: req.Base.UserId,
+	}
+	respBytes, err := proto.Marshal(resp)
+	if err != nil {
+		logger.Error(logger.TagMQ, "Failed to marshal failure response | To: %d, Error: %v", req.Base.UserId, err)
+		return
+	}
+	if err := h.producer.Publish(mq.TopicBroadcast, respBytes); err != nil {
+		logger.Error(logger.TagMQ, "Failed to publish failure response | To: %d, Error: %v", req.Base.UserId, err)
+	}
+}
+
+// HandleAuth 处理 Token 校验 RPC（AuthTokenRequest -> UserIdentity）
+func (h *MQHandler) HandleAuth(ctx context.Context, r *mq.Request) ([]byte, error) {
+	var req chat.AuthTokenRequest
+	if err := proto.Unmarshal(r.Payload, &req); err != nil {
+		return nil, mq.Permanent(fmt.Errorf("unmarshal auth request: %w", err))
+	}
+
+	identity, err := h.svc.ValidateAuthToken(ctx, req.Token)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(identity)
+}
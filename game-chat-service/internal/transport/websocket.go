@@ -2,9 +2,12 @@ package transport
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
-	"log"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"game-chat-service/internal/logger"
 
@@ -12,15 +15,29 @@ import (
 	"google.golang.org/protobuf/proto"
 
 	"game-chat-service/internal/service"
+	"game-pkg/envelope"
+	"game-pkg/mq"
 	"game-protocols/chat"
 )
 
+// WSServer Gateway 直连入口（Gateway chat_backend.transport=direct）
+// 请求与响应均为 envelope.Envelope，响应保留请求的 SessionID 和 Sequence；
+// 兼容旧格式：未封装的 ChatRequest 直接回复 ChatResponse
+// 连接须携带 Authorization: Bearer {gateway_token}（见 SetGatewayToken），未设置 Token 时拒绝所有连接
 type WSServer struct {
 	addr     string
 	svc      *service.ChatService
 	upgrader websocket.Upgrader
+	token    string
 
-	// Active key-value pairs? No on-connection context needed globally.
+	// gateways Gateway 实例 ID -> 该实例的连接，用于 mq.type=none 时推送广播
+	mu       sync.RWMutex
+	gateways map[string][]*gatewayConn
+}
+
+// gatewayConn 单条 Gateway 连接的写入队列
+type gatewayConn struct {
+	send chan []byte
 }
 
 func NewWSServer(port int, svc *service.ChatService) *WSServer {
@@ -31,38 +48,127 @@ func NewWSServer(port int, svc *service.ChatService) *WSServer {
 			ReadBufferSize:  8192, // 增加到 8KB
 			WriteBufferSize: 8192, // 增加到 8KB
 			CheckOrigin: func(r *http.Request) bool {
-				return true
+				return r.Header.Get("Origin") == "" // 只接受 Gateway，不接受浏览器
 			},
 		},
+		gateways: make(map[string][]*gatewayConn),
 	}
 }
 
+// SetGatewayToken 设置 Gateway 直连的共享密钥，需在 Start 前调用
+func (s *WSServer) SetGatewayToken(token string) {
+	s.token = token
+}
+
+// authorized 校验 Gateway 直连携带的共享密钥
+func (s *WSServer) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && s.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
 func (s *WSServer) Start() error {
 	http.HandleFunc("/", s.handleConnection) // Gateway connects to root
 	logger.Info(logger.TagTransport, "Chat Service (WS) listening on %s", s.addr)
 	return http.ListenAndServe(s.addr, nil)
 }
 
+// Publish 实现 mq.Producer，mq.type=none 时替代 MQ 作为下行通道：
+// 广播封装为 Envelope 推送到每个已连接的 Gateway（每个实例一条连接），由 Gateway 按 TargetUserId 投递
+func (s *WSServer) Publish(topic string, payload []byte) error {
+	if topic != mq.TopicBroadcast {
+		return fmt.Errorf("direct transport does not support topic %s", topic)
+	}
+	// 已封装（携带追踪上下文）的广播原样推送
+	data := payload
+	if _, err := envelope.Decode(payload); err != nil {
+		data = (&envelope.Envelope{Route: envelope.RouteChat, Payload: payload}).Encode()
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.gateways) == 0 {
+		return fmt.Errorf("no gateway connected")
+	}
+	dropped := 0
+	for _, conns := range s.gateways {
+		if !conns[0].push(data) {
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		return fmt.Errorf("broadcast dropped for %d/%d gateways (write channel full)", dropped, len(s.gateways))
+	}
+	return nil
+}
+
+func (c *gatewayConn) push(data []byte) bool {
+	select {
+	case c.send <- data:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *WSServer) addGateway(id string, c *gatewayConn) {
+	s.mu.Lock()
+	s.gateways[id] = append(s.gateways[id], c)
+	s.mu.Unlock()
+}
+
+func (s *WSServer) removeGateway(id string, c *gatewayConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conns := s.gateways[id]
+	for i, x := range conns {
+		if x == c {
+			conns = append(conns[:i:i], conns[i+1:]...)
+			break
+		}
+	}
+	if len(conns) == 0 {
+		delete(s.gateways, id)
+	} else {
+		s.gateways[id] = conns
+	}
+}
+
 func (s *WSServer) handleConnection(w http.ResponseWriter, r *http.Request) {
+	// 未通过校验的连接不会加入 gateways，收不到任何广播
+	if !s.authorized(r) {
+		logger.Warn(logger.TagTransport, "Unauthorized gateway connection | Remote: %s", r.RemoteAddr)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Println("Upgrade error:", err)
+		logger.Warn(logger.TagTransport, "WebSocket upgrade failed | Remote: %s, Error: %v", r.RemoteAddr, err)
 		return
 	}
 
 	// 创建写入队列（增大缓冲区以支持高并发）
-	writeChan := make(chan []byte, 512) // 增加到 512
+	gw := &gatewayConn{send: make(chan []byte, 512)} // 增加到 512
+	gatewayID := r.Header.Get(envelope.GatewayIDHeader)
+	if gatewayID == "" {
+		gatewayID = r.RemoteAddr
+	}
+	s.addGateway(gatewayID, gw)
+	logger.Debug(logger.TagTransport, "Gateway connected | Gateway: %s, RemoteAddr: %s", gatewayID, r.RemoteAddr)
 
+	// 启动写入 pump，专门负责写入操作
+	done := make(chan struct{})
 	defer func() {
-		close(writeChan)
+		s.removeGateway(gatewayID, gw)
+		close(gw.send)
+		// 等待写入完成
+		<-done
 		conn.Close()
 	}()
 
-	// 启动写入 pump，专门负责写入操作
-	done := make(chan struct{})
 	go func() {
 		defer close(done)
-		for data := range writeChan {
+		for data := range gw.send {
 			if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
 				logger.Error(logger.TagTransport, "Write error: %v", err)
 				return
@@ -80,51 +186,74 @@ func (s *WSServer) handleConnection(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 
-		// 🎯 新架构：直接解析 ChatRequest (纯 Protobuf)
-		// Gateway 已经提取了 Payload 并转发给我们
+		// 未封装的旧格式首字节为 Protobuf Tag，不会与 Envelope 版本号冲突
+		env, err := envelope.Decode(message)
+		if err != nil {
+			env = nil
+		} else {
+			message = env.Payload
+		}
+
 		var req chat.ChatRequest
-		if err := proto.Unmarshal(message, &req); err != nil {
-			logger.Error(logger.TagTransport, "Failed to unmarshal ChatRequest: %v", err)
+		if err := proto.Unmarshal(message, &req); err != nil || req.Base == nil {
+			logger.Error(logger.TagTransport, "Invalid ChatRequest: %v", err)
 			continue
 		}
 
-		logger.Debug(logger.TagTransport, "Received ChatRequest from User %d to User %d: %s",
-			req.Base.UserId, req.ReceiverId, req.Content)
+		if logger.Enabled(logger.TagTransport, logger.DEBUG) {
+			logger.DebugCtx(context.Background(), logger.TagTransport, "Received ChatRequest",
+				logger.UserID(req.Base.UserId), logger.GameID(req.Base.GameId), "receiver_id", req.ReceiverId, "gateway_id", gatewayID)
+		}
 
 		// Handle Request
 		resp, err := s.svc.HandleRequest(context.Background(), &req)
 		if err != nil {
 			logger.Error(logger.TagTransport, "Handle error: %v", err)
+			if env == nil {
+				continue
+			}
+			// 直连请求的发送者在等待该 Sequence 的响应，回复失败结果
+			resp = &chat.ChatResponse{
+				Base:         req.Base,
+				Success:      false,
+				ErrorMessage: err.Error(),
+				Timestamp:    time.Now().Unix(),
+			}
+		}
+		if resp == nil {
 			continue
 		}
+		resp.TargetUserId = req.Base.UserId
 
 		// Send Response (纯 Protobuf ChatResponse)
-		if resp != nil {
-			respBytes, err := proto.Marshal(resp)
-			if err != nil {
-				logger.Error(logger.TagTransport, "Marshal response error: %v", err)
-				continue
+		if env != nil {
+			resp.TargetSessionId = env.SessionID
+		}
+		respBytes, err := proto.Marshal(resp)
+		if err != nil {
+			logger.Error(logger.TagTransport, "Marshal response error: %v", err)
+			continue
+		}
+		if env != nil {
+			reply := &envelope.Envelope{
+				Route:       env.Route,
+				Sequence:    env.Sequence,
+				UserID:      req.Base.UserId,
+				SessionID:   env.SessionID,
+				GameID:      env.GameID,
+				TraceParent: service.TraceParent(&req),
+				Payload:     respBytes,
 			}
+			respBytes = reply.Encode()
+		}
 
-			// 通过写入队列发送（不直接写入）
-			select {
-			case writeChan <- respBytes:
-				logger.Debug(logger.TagTransport, "Queued ChatResponse to Gateway (%d bytes)", len(respBytes))
-			default:
-				// 详细的丢弃日志
-				bufferUsage := len(writeChan)
-				bufferCap := cap(writeChan)
-				usagePercent := bufferUsage * 100 / bufferCap
-
-				logger.Error(logger.TagTransport, "RESPONSE DROPPED - Write channel full | "+
-					"BufferUsage: %d/%d (%d%%), ResponseSize: %d bytes, "+
-					"FromUser: %d, ToUser: %d",
-					bufferUsage, bufferCap, usagePercent, len(respBytes),
-					req.Base.UserId, resp.TargetUserId)
-			}
+		// 通过写入队列发送（不直接写入）
+		if gw.push(respBytes) {
+			logger.Debug(logger.TagTransport, "Queued ChatResponse to Gateway (%d bytes)", len(respBytes))
+		} else {
+			logger.ErrorCtx(context.Background(), logger.TagTransport, "Response dropped, gateway write channel full",
+				logger.UserID(resp.TargetUserId), logger.GameID(req.Base.GameId), "gateway_id", gatewayID,
+				"buffer_len", len(gw.send), "buffer_cap", cap(gw.send), "response_size", len(respBytes))
 		}
 	}
-
-	// 等待写入完成
-	<-done
 }
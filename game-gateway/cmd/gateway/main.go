@@ -1,106 +1,558 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"net"
 	"net/http"
 	_ "net/http/pprof" // Import pprof for diagnostic info
+	"os/signal"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"game-gateway/internal/admin"
+	"game-gateway/internal/admission"
+	"game-gateway/internal/backend"
 	"game-gateway/internal/config"
 	"game-gateway/internal/logger"
+	"game-gateway/internal/metrics"
+	"game-gateway/internal/ratelimit"
 	"game-gateway/internal/router"
 	"game-gateway/internal/server"
 	"game-gateway/internal/session"
+	"game-gateway/pkg/protocol"
+	"game-pkg/envelope"
+	"game-pkg/health"
 	"game-pkg/mq"
+	"game-pkg/registry"
+	"game-pkg/tracing"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
 )
 
 func main() {
 	// Initialize logger first
 	logger.Init()
-	// Enable debug logging for troubleshooting
-	logger.SetLevel(logger.DEBUG)
-	logger.EnableTag(logger.TagRouter)
-	logger.EnableTag(logger.TagMQ)
-	logger.EnableTag(logger.TagProtocol)
-	// Disable noisy logs
-	logger.DisableTag(logger.TagSession)
 
 	// 1. Load config
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		logger.Fatal(logger.TagSystem, "Failed to load config: %v", err)
+	}
+	if config.CheckOnly() {
+		logger.Info(logger.TagSystem, "Config OK")
+		return
+	}
+
+	// 日志级别及标签：configs/logger.yaml + LOGGER_* 环境变量，文件修改后热更新
+	if err := logger.Load("logger"); err != nil {
+		logger.Fatal(logger.TagSystem, "Failed to load logger config: %v", err)
 	}
 
-	// 🆕 Enable pprof in non-prod environment
+	// 🆕 Metrics (/metrics), detailed /readyz and pprof (non-prod only) share the internal debug port
+	debugAddr := net.JoinHostPort(cfg.Server.MetricsHost, strconv.Itoa(cfg.Server.MetricsPort))
+	debugMux := http.NewServeMux()
+	debugMux.Handle("/metrics", metrics.Handler())
 	if cfg.Server.Env != "prod" {
-		go func() {
-			pprofPort := 6060 // Default pprof port
-			log.Printf("📊 Starting pprof server on :%d", pprofPort)
-			if err := http.ListenAndServe(fmt.Sprintf(":%d", pprofPort), nil); err != nil {
-				log.Printf("⚠️ pprof server failed: %v", err)
-			}
-		}()
+		debugMux.Handle("/debug/pprof/", http.DefaultServeMux)
+	}
+	go func() {
+		logger.Info(logger.TagSystem, "Starting metrics/pprof server on %s", debugAddr)
+		if err := http.ListenAndServe(debugAddr, debugMux); err != nil {
+			logger.Warn(logger.TagSystem, "metrics/pprof server failed: %v", err)
+		}
+	}()
+
+	// 链路追踪：exporter=none 时为空实现
+	shutdownTracing, err := tracing.Init("game-gateway", cfg.Tracing)
+	if err != nil {
+		logger.Fatal(logger.TagSystem, "Invalid tracing config: %v", err)
 	}
 
 	// 2. Initialize Router first (to handle callbacks)
 	r := router.NewRouter()
 
+	// 游戏注册表：配置文件中的游戏，以及管理 API / 其他实例同步的增删；未注册的 GameID 被拒绝
+	games := registry.New(cfg.GameIDs()...)
+	r.SetRegistry(games)
+	var configGames atomic.Pointer[[]string] // 配置文件中的游戏，配置修改后热更新；管理 API 不可注销
+	configIDs := cfg.GameIDs()
+	configGames.Store(&configIDs)
+	isConfigured := func(id string) bool { return slices.Contains(*configGames.Load(), id) }
+	games.SetProtected(isConfigured)
+
+	// 上行中间件：链路追踪、全局路由统计，按游戏限制 Payload 大小
+	r.UseInbound(router.Tracing())
+	r.UseInbound(router.Metrics())
+	r.UseOutbound(router.OutboundTracing())
+	r.UseOutbound(router.OutboundMetrics())
+	var payloadLimits atomic.Pointer[map[string]int] // 配置修改后热更新
+	limits := maxPayloadBytes(cfg)
+	payloadLimits.Store(&limits)
+	payloadLimit := func(gameID string) int {
+		return (*payloadLimits.Load())[gameID]
+	}
+	r.SetPayloadLimit(payloadLimit)
+	r.UseInbound(router.MaxPayloadBy(payloadLimit))
+
 	// Register backends to Router
 
 	// 4. Initialize Session Manager
 	sm := session.NewManager()
+	if rc := cfg.Session.Resume; rc.Enabled {
+		sm.SetReplayConfig(session.ReplayConfig{Size: rc.ReplaySize, Window: rc.Window})
+	}
+	for _, g := range cfg.Games {
+		policy, err := session.ParseLoginPolicy(g.DuplicateLogin)
+		if err != nil {
+			logger.Fatal(logger.TagSystem, "Invalid games[%s].duplicate_login: %v", g.ID, err)
+		}
+		sm.SetLoginPolicy(g.ID, policy)
+	}
 	r.SetSessionManager(sm)
 
+	// /metrics 采集时按游戏统计在线会话及发送队列深度
+	metrics.GlobalMetrics.SetSessionSource(func() map[string]metrics.SessionStats {
+		stats := make(map[string]metrics.SessionStats)
+		for _, sess := range sm.All() {
+			st := stats[sess.GameID]
+			st.Sessions++
+			st.Queued += sess.Queue.Len()
+			stats[sess.GameID] = st
+		}
+		return stats
+	})
+
 	// 5. Initialize MQ
 	var mqInstance interface {
 		mq.Producer
 		mq.Consumer
 	}
+	var mqRedis *redis.Client // mq.type=redis 时的连接，用于就绪检查
 
 	switch cfg.MQ.Type {
 	case "robustmq":
-		log.Println("🚀 Using RobustMQ (MQTT)")
+		logger.Info(logger.TagSystem, "Using RobustMQ (MQTT)")
 		mqInstance = mq.NewRobustMQ(&mq.RobustMQConfig{
 			Broker:   cfg.MQ.RobustMQ.Broker,
 			ClientID: cfg.MQ.RobustMQ.ClientID,
 			Username: cfg.MQ.RobustMQ.Username,
 			Password: cfg.MQ.RobustMQ.Password,
 		})
+	case "none":
+		// 不使用 MQ：聊天请求需配置 chat_backend.transport=direct，RouteGame 转发不可用
+		logger.Info(logger.TagSystem, "MQ disabled")
 	case "redis":
 		fallthrough
 	default:
-		log.Println("🚀 Using Redis MQ")
-		rdb := redis.NewClient(&redis.Options{
+		logger.Info(logger.TagSystem, "Using Redis MQ")
+		mqRedis = redis.NewClient(&redis.Options{
 			Addr:     cfg.MQ.Redis.Addr,
 			Password: cfg.MQ.Redis.Password,
 		})
-		mqInstance = mq.NewRedisMQ(rdb)
+		mqInstance = mq.NewRedisMQ(mqRedis)
+	}
+
+	// Gateway 实例 ID：RPC / 游戏响应 Topic 及直连后端识别本实例
+	instanceID := uuid.New().String()
+
+	var rpcClient *mq.RPCClient
+	if mqInstance != nil {
+		// Inject MQ into Router to enable async request processing
+		producer := metrics.InstrumentProducer(mqInstance)
+		r.SetMQ(producer)
+
+		// 订阅侧背压统计接入 Metrics
+		if reporter, ok := mqInstance.(mq.StatsReporter); ok {
+			metrics.GlobalMetrics.SetMQStatsSource(reporter.Stats)
+		}
+
+		// 连接状态变化：记录日志并接入 Metrics / 健康检查
+		if watcher, ok := mqInstance.(mq.StateWatcher); ok {
+			metrics.GlobalMetrics.SetMQStateSource(watcher.State)
+			watcher.OnStateChange(func(ev mq.StateEvent) {
+				if ev.Err != nil {
+					logger.Warn(logger.TagMQ, "MQ state changed | State: %s, Topic: %s, Error: %v", ev.State, ev.Topic, ev.Err)
+				} else {
+					logger.Info(logger.TagMQ, "MQ state changed | State: %s, Topic: %s", ev.State, ev.Topic)
+				}
+			})
+		}
+
+		subOpts, err := cfg.MQ.Subscribe.Options()
+		if err != nil {
+			logger.Fatal(logger.TagSystem, "Invalid mq.subscribe config: %v", err)
+		}
+
+		// Subscribe to broadcasts
+		msgChan, err := mqInstance.Subscribe(mq.TopicBroadcast, subOpts...)
+		if err != nil {
+			logger.Fatal(logger.TagSystem, "Failed to subscribe to broadcast: %v", err)
+		}
+
+		// RPC / 游戏响应 Topic 每个 Gateway 实例唯一
+		rpcClient, err = mq.NewRPCClient(producer, mqInstance, "rpc:reply:gateway:"+instanceID, subOpts...)
+		if err != nil {
+			logger.Fatal(logger.TagSystem, "Failed to init MQ rpc client: %v", err)
+		}
+		r.SetRPC(rpcClient)
+
+		// RouteGame 转发：请求发布到 game:logic:{GameID}，响应回到本实例的 Topic，推送经 game:push:{GameID}
+		consume := func(topic, label string) error {
+			gameChan, err := mqInstance.Subscribe(topic, subOpts...)
+			if err != nil {
+				return err
+			}
+			go func() {
+				for msg := range gameChan {
+					metrics.GlobalMetrics.IncrementConsumed(label)
+					r.HandleEnvelope(msg.Payload)
+				}
+			}()
+			return nil
+		}
+		// 响应 Topic 含实例 ID，统计时归为同一标签
+		replyTopic := "game:reply:gateway:" + instanceID
+		r.SetGameReplyTopic(replyTopic)
+		if err := consume(replyTopic, "game:reply"); err != nil {
+			logger.Fatal(logger.TagSystem, "Failed to subscribe to %s: %v", replyTopic, err)
+		}
+		for _, id := range games.List() {
+			topic := mq.GamePushTopic(id)
+			if err := consume(topic, topic); err != nil {
+				logger.Fatal(logger.TagSystem, "Failed to subscribe to %s: %v", topic, err)
+			}
+		}
+
+		// 注册表变化时订阅 / 取消订阅游戏推送 Topic，并与其他实例同步
+		games.OnChange(func(ev registry.Event) {
+			topic := mq.GamePushTopic(ev.GameID)
+			var err error
+			switch ev.Op {
+			case registry.OpAdd:
+				err = consume(topic, topic)
+			case registry.OpRemove:
+				if u, ok := mqInstance.(mq.Unsubscriber); ok {
+					err = u.Unsubscribe(topic)
+				}
+			}
+			if err != nil {
+				logger.Warn(logger.TagMQ, "Update game push subscription failed | Game: %s, Op: %s, Error: %v", ev.GameID, ev.Op, err)
+			}
+		})
+		if err := games.Sync(producer, mqInstance, instanceID, true); err != nil {
+			logger.Fatal(logger.TagSystem, "Failed to sync game registry: %v", err)
+		}
+
+		// Start consumer loop
+		go func() {
+			logger.Info(logger.TagSystem, "Started listening for Redis broadcasts")
+			for msg := range msgChan {
+				metrics.GlobalMetrics.IncrementConsumed(mq.TopicBroadcast)
+				r.HandleBroadcast(msg.Payload)
+			}
+			logger.Error(logger.TagMQ, "Broadcast subscription closed, downstream delivery stopped")
+		}()
 	}
 
-	// Inject MQ into Router to enable async request processing
-	r.SetMQ(mqInstance)
+	// 6. Start Server
+	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
+	srv := server.NewServer(addr, r, sm)
+	debugMux.Handle("/readyz", srv.ReadinessHandler()) // 含依赖错误详情，只在内网端口提供
+	queueCfg, err := sendQueueConfig(cfg)
+	if err != nil {
+		logger.Fatal(logger.TagSystem, "Invalid session.send_queue config: %v", err)
+	}
+	srv.SetQueueConfig(queueCfg)
+	srv.SetRegistry(games)
+
+	// 直连 Chat Service：每个游戏一个连接池，响应与推送沿连接返回
+	var chatPools []*backend.Pool
+	for _, g := range cfg.Games {
+		switch g.ChatBackend.Transport {
+		case "", "mq":
+			if mqInstance == nil {
+				logger.Fatal(logger.TagSystem, "games[%s].chat_backend.transport must be direct when mq.type is none", g.ID)
+			}
+			continue
+		case "direct":
+		default:
+			logger.Fatal(logger.TagSystem, "Invalid games[%s].chat_backend.transport: %q", g.ID, g.ChatBackend.Transport)
+		}
 
-	// Subscribe to broadcasts
-	msgChan, err := mqInstance.Subscribe("broadcast")
+		addrs := g.ChatBackend.Addrs
+		if len(addrs) == 0 {
+			addrs = []string{fmt.Sprintf("%s:%d", g.ChatBackend.Host, g.ChatBackend.Port)}
+		}
+		pool, err := backend.NewPool("chat:"+g.ID, addrs, r.HandleEnvelope,
+			backend.WithSize(g.ChatBackend.PoolSize),
+			backend.WithHeader(envelope.GatewayIDHeader, instanceID),
+			backend.WithHeader("Authorization", "Bearer "+g.ChatBackend.Token))
+		if err != nil {
+			logger.Fatal(logger.TagSystem, "Failed to init chat backend for %s: %v", g.ID, err)
+		}
+		r.SetChatBackend(g.ID, pool)
+		srv.AddHealthCheck("chat:"+g.ID, func(context.Context) (any, error) {
+			details := map[string]any{"available": pool.Available(), "dropped": pool.Dropped()}
+			return details, pool.Check()
+		})
+		chatPools = append(chatPools, pool)
+		logger.Info(logger.TagSystem, "Chat backend for %s uses direct transport: %v", g.ID, addrs)
+	}
+	var limiter *ratelimit.Limiter
+	if cfg.RateLimit.Enabled {
+		limitCfg, err := rateLimitConfig(cfg)
+		if err != nil {
+			logger.Fatal(logger.TagSystem, "Invalid rate_limit config: %v", err)
+		}
+		limiter = ratelimit.NewLimiter(limitCfg)
+		limiter.StartJanitor(time.Minute)
+		srv.SetRateLimiter(limiter)
+	}
+	srv.SetClientIPHeader(cfg.RateLimit.IPHeader, cfg.RateLimit.IPHops)
+
+	admissionCfg, err := admissionConfig(cfg)
 	if err != nil {
-		log.Fatalf("Failed to subscribe to broadcast: %v", err)
+		logger.Fatal(logger.TagSystem, "Invalid admission config: %v", err)
 	}
+	admissionCtl := admission.NewController(admissionCfg)
+	srv.SetAdmission(admissionCtl)
+	r.SetOriginCheck(admissionCtl.OriginAllowed)
 
-	// Start consumer loop
+	// 配置文件变化时热更新准入控制、限流及游戏设置；校验失败则保留旧配置
+	current := cfg
+	config.Watch(func(next *config.Config) {
+		ac, err := admissionConfig(next)
+		if err != nil {
+			logger.Warn(logger.TagSystem, "Admission config reload rejected: %v", err)
+			return
+		}
+		lc, err := rateLimitConfig(next)
+		if err != nil {
+			logger.Warn(logger.TagSystem, "Rate limit config reload rejected: %v", err)
+			return
+		}
+
+		admissionCtl.Update(ac)
+		if limiter != nil {
+			limiter.Update(lc)
+		}
+		for _, g := range next.Games {
+			policy, _ := session.ParseLoginPolicy(g.DuplicateLogin) // 已校验
+			sm.SetLoginPolicy(g.ID, policy)
+		}
+		limits := maxPayloadBytes(next)
+		payloadLimits.Store(&limits)
+
+		// 配置文件中增删的游戏同步到注册表（经管理 API 注册的游戏不受影响）
+		prevIDs, nextIDs := current.GameIDs(), next.GameIDs()
+		configGames.Store(&nextIDs)
+		for _, g := range next.Games {
+			if slices.Contains(prevIDs, g.ID) {
+				continue
+			}
+			// 直连游戏的连接池只在启动时创建，重启前不注册（否则聊天请求会落到 MQ 或直接失败）
+			if g.ChatBackend.Transport == "direct" {
+				logger.Warn(logger.TagSystem, "Game %s uses direct chat transport, not registered until restart", g.ID)
+				continue
+			}
+			games.Add(g.ID)
+		}
+		for _, id := range prevIDs {
+			if !slices.Contains(nextIDs, id) {
+				games.Remove(id)
+			}
+		}
+		logger.Info(logger.TagSystem, "Config reloaded | MaxConnections: %d, MaxPerIP: %d, Allow: %d, Deny: %d, RateLimit: %v",
+			ac.MaxConnections, ac.MaxPerIP, len(ac.Allow), len(ac.Deny), limiter != nil)
+
+		if changed := config.RestartRequired(current, next); len(changed) > 0 {
+			logger.Warn(logger.TagSystem, "Config changes require restart to take effect: %v", changed)
+		}
+		current = next
+	})
+
+	// 运维管理 API
+	var adminSrv *http.Server
+	if cfg.Admin.Addr != "" {
+		if cfg.Admin.Token == "" {
+			logger.Warn(logger.TagSystem, "Admin API disabled: admin.token / admin.token_file / GATEWAY_ADMIN_TOKEN not set")
+		} else {
+			adminAPI := admin.NewServer(sm, games, cfg.Admin.Token)
+			adminAPI.SetConfigured(isConfigured)
+			adminSrv = &http.Server{Addr: cfg.Admin.Addr, Handler: adminAPI.Handler()}
+			go func() {
+				logger.Info(logger.TagSystem, "Starting admin API on %s", cfg.Admin.Addr)
+				if err := adminSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					logger.Warn(logger.TagSystem, "Admin API failed: %v", err)
+				}
+			}()
+		}
+	}
+
+	// 监听退出信号，优雅下线
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownDone := make(chan struct{})
 	go func() {
-		log.Println("🎧 Started listening for Redis broadcasts")
-		for msg := range msgChan {
-			r.HandleBroadcast(msg.Payload)
+		defer close(shutdownDone)
+		<-ctx.Done()
+		logger.Info(logger.TagSystem, "Shutdown signal received, draining connections...")
+
+		shutdownTimeout := cfg.Server.ShutdownTimeout
+		if shutdownTimeout <= 0 {
+			shutdownTimeout = 10 * time.Second
+		}
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := srv.Shutdown(shutdownCtx, cfg.Server.ReconnectDelay); err != nil {
+			logger.Warn(logger.TagSystem, "Server shutdown error: %v", err)
+		}
+		if adminSrv != nil {
+			adminSrv.Shutdown(shutdownCtx)
+		}
+
+		for _, pool := range chatPools {
+			pool.Close()
+		}
+
+		// 停止 MQ 消费（取消订阅）
+		if mqInstance != nil {
+			rpcClient.Close()
+			if err := mqInstance.Close(); err != nil {
+				logger.Warn(logger.TagSystem, "MQ close error: %v", err)
+			}
+		}
+
+		// 导出缓冲中剩余的 Span
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			logger.Warn(logger.TagSystem, "Tracing shutdown error: %v", err)
 		}
 	}()
+	// /readyz：MQ 连接及订阅状态、Redis 连通性
+	if mqInstance != nil {
+		srv.AddHealthCheck("mq", health.MQ(mqInstance))
+	}
+	if mqRedis != nil {
+		srv.AddHealthCheck("redis", health.Ping(func(ctx context.Context) error {
+			return mqRedis.Ping(ctx).Err()
+		}))
+	}
 
-	// 6. Start Server
-	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
-	srv := server.NewServer(addr, r, sm)
+	if err := srv.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Fatal(logger.TagSystem, "Server failed: %v", err)
+	}
+
+	// 等待下线流程完成
+	<-shutdownDone
+	logger.Info(logger.TagSystem, "Gateway stopped")
+}
+
+// sendQueueConfig 根据配置构造会话发送队列配置，未配置的项使用默认值
+func sendQueueConfig(cfg *config.Config) (session.QueueConfig, error) {
+	qc := session.DefaultQueueConfig()
+	sq := cfg.Session.SendQueue
+
+	classes := map[session.Priority]config.QueueClassConfig{
+		session.PriorityHigh:   sq.High,
+		session.PriorityNormal: sq.Normal,
+		session.PriorityLow:    sq.Low,
+	}
+	for prio, c := range classes {
+		if c.Size > 0 {
+			qc.Classes[prio].Size = c.Size
+		}
+		if c.Policy != "" {
+			policy, err := session.ParseSlowConsumerPolicy(c.Policy)
+			if err != nil {
+				return qc, fmt.Errorf("%s: %w", prio, err)
+			}
+			qc.Classes[prio].Policy = policy
+		}
+	}
+	if sq.DisconnectAfterDrops > 0 {
+		qc.DisconnectAfterDrops = sq.DisconnectAfterDrops
+	}
+	if sq.DisconnectWindow > 0 {
+		qc.DisconnectWindow = sq.DisconnectWindow
+	}
+	return qc, nil
+}
+
+// rateLimitConfig 根据配置构造限流参数
+func rateLimitConfig(cfg *config.Config) (ratelimit.Config, error) {
+	rl := cfg.RateLimit
+	lc := ratelimit.Config{
+		Session:       ratelimit.Limit{Rate: rl.Session.Rate, Burst: rl.Session.Burst},
+		User:          ratelimit.Limit{Rate: rl.User.Rate, Burst: rl.User.Burst},
+		IP:            ratelimit.Limit{Rate: rl.IP.Rate, Burst: rl.IP.Burst},
+		Routes:        make(map[protocol.RouteType]ratelimit.Limit),
+		BanViolations: rl.Ban.Violations,
+		BanWindow:     rl.Ban.Window,
+		BanDuration:   rl.Ban.Duration,
+	}
 
-	if err := srv.Start(); err != nil {
-		log.Fatal("Server failed:", err)
+	routes := map[string]protocol.RouteType{
+		"game":   protocol.RouteGame,
+		"chat":   protocol.RouteChat,
+		"system": protocol.RouteSystem,
+	}
+	for name, c := range rl.Routes {
+		route, ok := routes[name]
+		if !ok {
+			return lc, fmt.Errorf("unknown route %q", name)
+		}
+		lc.Routes[route] = ratelimit.Limit{Rate: c.Rate, Burst: c.Burst}
+	}
+	return lc, nil
+}
+
+// maxPayloadBytes 各游戏上行 Payload 大小上限，未配置的游戏不限制
+// key "" 为各游戏中最宽松的上限（任一游戏不限制时为 0），用于尚未绑定游戏的连接
+func maxPayloadBytes(cfg *config.Config) map[string]int {
+	limits := make(map[string]int, len(cfg.Games)+1)
+	loosest := 0
+	for i, g := range cfg.Games {
+		limits[g.ID] = g.MaxPayloadBytes
+		if i == 0 || (loosest > 0 && (g.MaxPayloadBytes <= 0 || g.MaxPayloadBytes > loosest)) {
+			loosest = g.MaxPayloadBytes
+		}
+	}
+	limits[""] = loosest
+	return limits
+}
+
+// admissionConfig 根据配置构造连接准入参数
+func admissionConfig(cfg *config.Config) (admission.Config, error) {
+	ac := cfg.Admission
+	allow, err := admission.ParseCIDRs(ac.Allow)
+	if err != nil {
+		return admission.Config{}, fmt.Errorf("allow: %w", err)
+	}
+	deny, err := admission.ParseCIDRs(ac.Deny)
+	if err != nil {
+		return admission.Config{}, fmt.Errorf("deny: %w", err)
+	}
+
+	origins := make(map[string][]string)
+	for _, g := range cfg.Games {
+		if len(g.AllowedOrigins) > 0 {
+			origins[g.ID] = g.AllowedOrigins
+		}
 	}
+	return admission.Config{
+		MaxConnections: ac.MaxConnections,
+		MaxPerIP:       ac.MaxPerIP,
+		Allow:          allow,
+		Deny:           deny,
+		Origins:        origins,
+		ConnectRate:    ratelimit.Limit{Rate: ac.ConnectRate.Rate, Burst: ac.ConnectRate.Burst},
+	}, nil
 }
@@ -0,0 +1,380 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"game-gateway/internal/logger"
+	"game-gateway/internal/session"
+	"game-gateway/pkg/protocol"
+	"game-pkg/registry"
+)
+
+// Server 网关运维管理 API，所有请求需携带 Authorization: Bearer {token}
+//
+//	GET    /admin/sessions?game=&user=&limit=  会话列表（按连接时间排序）
+//	GET    /admin/sessions/count?game=&user=   会话数（按游戏统计）
+//	GET    /admin/sessions/{id}                会话详情
+//	DELETE /admin/sessions/{id}?reason=        踢下线
+//	POST   /admin/users/{user}/push            向用户所有会话发送测试推送 {"message", "priority"}
+//	GET    /admin/games                        已注册的游戏
+//	PUT    /admin/games/{id}                   注册游戏（同步到所有 Gateway 及 Chat Service，不持久化到配置文件）
+//	DELETE /admin/games/{id}                   注销游戏（已连接会话的后续请求将被拒绝；配置文件中的游戏不可注销）
+//	GET    /admin/log                          当前日志级别及标签
+//	PUT    /admin/log                          修改日志级别及标签 {"level", "enable", "disable"}
+type Server struct {
+	sessions *session.Manager
+	games    *registry.Registry
+	token    string
+	mux      *http.ServeMux
+
+	// configured 游戏是否定义在配置文件中（重启或热更新后会重新注册，不能经管理 API 注销），为空不限制
+	configured func(id string) bool
+}
+
+// defaultListLimit 会话列表默认返回条数
+const defaultListLimit = 100
+
+func NewServer(sm *session.Manager, games *registry.Registry, token string) *Server {
+	s := &Server{
+		sessions: sm,
+		games:    games,
+		token:    token,
+		mux:      http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /admin/sessions", s.handleList)
+	s.mux.HandleFunc("GET /admin/sessions/count", s.handleCount)
+	s.mux.HandleFunc("GET /admin/sessions/{id}", s.handleGet)
+	s.mux.HandleFunc("DELETE /admin/sessions/{id}", s.handleKick)
+	s.mux.HandleFunc("POST /admin/users/{user}/push", s.handlePush)
+	s.mux.HandleFunc("GET /admin/games", s.handleListGames)
+	s.mux.HandleFunc("PUT /admin/games/{id}", s.handleAddGame)
+	s.mux.HandleFunc("DELETE /admin/games/{id}", s.handleRemoveGame)
+	s.mux.HandleFunc("GET /admin/log", s.handleGetLog)
+	s.mux.HandleFunc("PUT /admin/log", s.handleSetLog)
+	return s
+}
+
+// SetConfigured 设置配置文件游戏的判断（支持热更新），DELETE /admin/games/{id} 拒绝注销这些游戏；需在启动前调用
+func (s *Server) SetConfigured(fn func(id string) bool) {
+	s.configured = fn
+}
+
+// Handler 返回带鉴权的 HTTP Handler
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			logger.Warn(logger.TagAdmin, "Unauthorized admin request | Remote: %s, Path: %s", r.RemoteAddr, r.URL.Path)
+			writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+			return
+		}
+		s.mux.ServeHTTP(w, r)
+	})
+}
+
+// SessionInfo 会话信息
+type SessionInfo struct {
+	ID          string    `json:"id"`
+	GameID      string    `json:"game_id"`
+	UserID      int32     `json:"user_id"` // 0 表示尚未绑定用户
+	DeviceID    string    `json:"device_id,omitempty"`
+	RemoteAddr  string    `json:"remote_addr"`
+	RemoteIP    string    `json:"remote_ip"` // 经可信代理请求头解析后的客户端 IP
+	ConnectedAt time.Time `json:"connected_at"`
+	Uptime      string    `json:"uptime"`
+	RTTMs       float64   `json:"rtt_ms"` // 最近一次 Ping/Pong 往返时间，0 表示尚未测得
+	QueueLen    int       `json:"queue_len"`
+	QueueCap    int       `json:"queue_cap"`
+	Resumable   bool      `json:"resumable"` // 是否启用断线续传
+}
+
+func newSessionInfo(sess *session.Session) SessionInfo {
+	info := SessionInfo{
+		ID:          sess.ID,
+		GameID:      sess.GameID,
+		UserID:      sess.UserID,
+		DeviceID:    sess.DeviceID,
+		RemoteIP:    sess.RemoteIP,
+		ConnectedAt: sess.ConnectedAt,
+		Uptime:      time.Since(sess.ConnectedAt).Round(time.Second).String(),
+		RTTMs:       float64(sess.RTT().Microseconds()) / 1000,
+		QueueLen:    sess.Queue.Len(),
+		QueueCap:    sess.Queue.Cap(),
+		Resumable:   sess.ResumeToken != "",
+	}
+	if sess.Conn != nil {
+		info.RemoteAddr = sess.Conn.RemoteAddr().String()
+	}
+	return info
+}
+
+// filter 按 ?game= 及 ?user= 筛选会话
+func (s *Server) filter(r *http.Request) ([]*session.Session, error) {
+	query := r.URL.Query()
+	var sessions []*session.Session
+	if user := query.Get("user"); user != "" {
+		userID, err := parseUserID(user)
+		if err != nil {
+			return nil, err
+		}
+		sessions = s.sessions.GetByUserID(userID)
+	} else {
+		sessions = s.sessions.All()
+	}
+
+	game := query.Get("game")
+	if game == "" {
+		return sessions, nil
+	}
+	matched := sessions[:0]
+	for _, sess := range sessions {
+		if sess.GameID == game {
+			matched = append(matched, sess)
+		}
+	}
+	return matched, nil
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	sessions, err := s.filter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	limit := defaultListLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", v))
+			return
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].ConnectedAt.Before(sessions[j].ConnectedAt)
+	})
+	total := len(sessions)
+	if len(sessions) > limit {
+		sessions = sessions[:limit]
+	}
+	infos := make([]SessionInfo, 0, len(sessions))
+	for _, sess := range sessions {
+		infos = append(infos, newSessionInfo(sess))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"total": total, "sessions": infos})
+}
+
+func (s *Server) handleCount(w http.ResponseWriter, r *http.Request) {
+	sessions, err := s.filter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	type gameCount struct {
+		Sessions int `json:"sessions"`
+		Users    int `json:"users"` // 已绑定的不同用户数
+	}
+	byGame := make(map[string]*gameCount)
+	users := make(map[string]map[int32]struct{})
+	for _, sess := range sessions {
+		c := byGame[sess.GameID]
+		if c == nil {
+			c = &gameCount{}
+			byGame[sess.GameID] = c
+			users[sess.GameID] = make(map[int32]struct{})
+		}
+		c.Sessions++
+		if sess.UserID != 0 {
+			users[sess.GameID][sess.UserID] = struct{}{}
+		}
+	}
+	for game, c := range byGame {
+		c.Users = len(users[game])
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"total": len(sessions), "by_game": byGame})
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	sess := s.sessions.Get(r.PathValue("id"))
+	if sess == nil {
+		writeError(w, http.StatusNotFound, errors.New("session not found"))
+		return
+	}
+	writeJSON(w, http.StatusOK, newSessionInfo(sess))
+}
+
+func (s *Server) handleKick(w http.ResponseWriter, r *http.Request) {
+	sess := s.sessions.Get(r.PathValue("id"))
+	if sess == nil {
+		writeError(w, http.StatusNotFound, errors.New("session not found"))
+		return
+	}
+	reason := r.URL.Query().Get("reason")
+	if reason == "" {
+		reason = "kicked by administrator"
+	}
+	sess.Kick(reason)
+	logger.Info(logger.TagAdmin, "Session kicked | Session: %s, UserID: %d, Reason: %s", sess.ID, sess.UserID, reason)
+	writeJSON(w, http.StatusOK, map[string]any{"kicked": sess.ID})
+}
+
+// pushRequest 测试推送请求体
+type pushRequest struct {
+	Message  string `json:"message"`
+	Priority string `json:"priority"` // high / normal（默认）/ low
+}
+
+func (s *Server) handlePush(w http.ResponseWriter, r *http.Request) {
+	userID, err := parseUserID(r.PathValue("user"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var req pushRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("decode body: %w", err))
+		return
+	}
+	prio, err := parsePriority(req.Priority)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	sessions := s.sessions.GetByUserID(userID)
+	if len(sessions) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("user %d has no online session", userID))
+		return
+	}
+	payload, err := protocol.EncodeSystemPayload(protocol.PayloadSystemNotice, &protocol.Notice{
+		Message: req.Message,
+		SentAt:  time.Now().UnixMilli(),
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	results := make(map[string]string, len(sessions))
+	for _, sess := range sessions {
+		results[sess.ID] = sess.Send(prio, protocol.RouteSystem, payload).String()
+	}
+	logger.Info(logger.TagAdmin, "Test push sent | UserID: %d, Sessions: %d", userID, len(sessions))
+	writeJSON(w, http.StatusOK, map[string]any{"user_id": userID, "results": results})
+}
+
+func (s *Server) handleListGames(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"games": s.games.List()})
+}
+
+func (s *Server) handleAddGame(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	added, err := s.games.Add(id)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if added {
+		logger.Info(logger.TagAdmin, "Game registered | Game: %s", id)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"game_id": id, "added": added})
+}
+
+func (s *Server) handleRemoveGame(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if s.configured != nil && s.configured(id) {
+		writeError(w, http.StatusConflict, errors.New("game is defined in the config file; remove it there instead"))
+		return
+	}
+	if !s.games.Remove(id) {
+		writeError(w, http.StatusNotFound, errors.New("game not registered"))
+		return
+	}
+	logger.Info(logger.TagAdmin, "Game removed | Game: %s", id)
+	writeJSON(w, http.StatusOK, map[string]any{"removed": id})
+}
+
+// logState 日志级别及标签状态
+type logState struct {
+	Level string                 `json:"level"`
+	Tags  map[logger.LogTag]bool `json:"tags"`
+}
+
+func currentLogState() logState {
+	return logState{Level: logger.Level().String(), Tags: logger.Tags()}
+}
+
+func (s *Server) handleGetLog(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, currentLogState())
+}
+
+// logRequest 修改日志配置请求体，未填写的项保持不变
+type logRequest struct {
+	Level   string          `json:"level"`
+	Enable  []logger.LogTag `json:"enable"`
+	Disable []logger.LogTag `json:"disable"`
+}
+
+func (s *Server) handleSetLog(w http.ResponseWriter, r *http.Request) {
+	var req logRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("decode body: %w", err))
+		return
+	}
+	if req.Level != "" {
+		level, err := logger.ParseLevel(req.Level)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		logger.SetLevel(level)
+	}
+	for _, tag := range req.Enable {
+		logger.EnableTag(logger.LogTag(strings.ToUpper(string(tag))))
+	}
+	for _, tag := range req.Disable {
+		logger.DisableTag(logger.LogTag(strings.ToUpper(string(tag))))
+	}
+
+	state := currentLogState()
+	logger.Info(logger.TagAdmin, "Log config changed | Level: %s, Enable: %v, Disable: %v", state.Level, req.Enable, req.Disable)
+	writeJSON(w, http.StatusOK, state)
+}
+
+func parseUserID(s string) (int32, error) {
+	id, err := strconv.ParseInt(s, 10, 32)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid user id %q", s)
+	}
+	return int32(id), nil
+}
+
+func parsePriority(s string) (session.Priority, error) {
+	switch s {
+	case "", "normal":
+		return session.PriorityNormal, nil
+	case "high":
+		return session.PriorityHigh, nil
+	case "low":
+		return session.PriorityLow, nil
+	default:
+		return 0, fmt.Errorf("unknown priority %q (high / normal / low)", s)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,204 @@
+package admission
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"game-gateway/internal/ratelimit"
+
+	cmap "github.com/orcaman/concurrent-map/v2"
+)
+
+// Config 连接准入配置，可在运行时通过 Controller.Update 整体替换
+type Config struct {
+	MaxConnections int // 网关总连接数上限，<= 0 不限制
+	MaxPerIP       int // 单个 IP 的连接数上限，<= 0 不限制
+
+	Allow []*net.IPNet // 非空时只接受这些网段
+	Deny  []*net.IPNet // 拒绝的网段（优先于 Allow）
+
+	// Origins GameID -> 允许的浏览器 Origin（"*" 表示任意），为空不校验
+	// 客户端通过 /ws?game={GameID} 声明游戏；未声明或该游戏未配置列表时 Origin 须在任一游戏的列表中
+	// （如经管理 API 新增的游戏），避免借未配置的游戏绕过其他游戏的白名单
+	Origins map[string][]string
+
+	ConnectRate ratelimit.Limit // 全网关新建连接速率，用于抵御重连风暴
+}
+
+// ParseCIDRs 解析 CIDR 列表，单个 IP 视为 /32 或 /128
+func ParseCIDRs(list []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", s, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// Rejection 拒绝原因及对应的 HTTP 状态码
+type Rejection struct {
+	Status     int
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (r *Rejection) Error() string {
+	return r.Reason
+}
+
+// WriteResponse 以 HTTP 错误响应拒绝升级
+func (r *Rejection) WriteResponse(w http.ResponseWriter) {
+	if r.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(r.RetryAfter.Seconds())+1))
+	}
+	http.Error(w, r.Reason, r.Status)
+}
+
+// Controller 在 WebSocket 升级前做准入检查并统计连接数
+type Controller struct {
+	cfg atomic.Pointer[Config]
+
+	total   atomic.Int64
+	perIP   cmap.ConcurrentMap[string, int]
+	connect ratelimit.Bucket
+}
+
+func NewController(cfg Config) *Controller {
+	c := &Controller{perIP: cmap.New[int]()}
+	c.cfg.Store(&cfg)
+	return c
+}
+
+// Update 热更新准入配置，已建立的连接不受影响
+func (c *Controller) Update(cfg Config) {
+	c.cfg.Store(&cfg)
+}
+
+// Config 返回当前配置
+func (c *Controller) Config() Config {
+	return *c.cfg.Load()
+}
+
+// Admit 检查连接是否准入；通过时返回 release，连接关闭时必须调用一次
+func (c *Controller) Admit(r *http.Request, ip string) (release func(), rej *Rejection) {
+	cfg := c.cfg.Load()
+
+	parsed := net.ParseIP(ip)
+	if matchAny(cfg.Deny, parsed) {
+		return nil, &Rejection{Status: http.StatusForbidden, Reason: "ip denied"}
+	}
+	if len(cfg.Allow) > 0 && !matchAny(cfg.Allow, parsed) {
+		return nil, &Rejection{Status: http.StatusForbidden, Reason: "ip not allowed"}
+	}
+	if !originAllowed(cfg.Origins, r.URL.Query().Get("game"), r.Header.Get("Origin")) {
+		return nil, &Rejection{Status: http.StatusForbidden, Reason: "origin not allowed"}
+	}
+
+	if ok, wait := c.connect.Allow(cfg.ConnectRate, time.Now().UnixNano()); !ok {
+		return nil, &Rejection{Status: http.StatusTooManyRequests, Reason: "connect rate exceeded", RetryAfter: wait}
+	}
+
+	if n := c.total.Add(1); cfg.MaxConnections > 0 && n > int64(cfg.MaxConnections) {
+		c.total.Add(-1)
+		return nil, &Rejection{Status: http.StatusServiceUnavailable, Reason: "gateway at capacity", RetryAfter: time.Second}
+	}
+
+	limited := false
+	c.perIP.Upsert(ip, 0, func(_ bool, cur, _ int) int {
+		if cfg.MaxPerIP > 0 && cur >= cfg.MaxPerIP {
+			limited = true
+			return cur
+		}
+		return cur + 1
+	})
+	if limited {
+		c.total.Add(-1)
+		c.releaseIP(ip)
+		return nil, &Rejection{Status: http.StatusTooManyRequests, Reason: "too many connections from this ip"}
+	}
+
+	var once atomic.Bool
+	return func() {
+		if once.CompareAndSwap(false, true) {
+			c.total.Add(-1)
+			c.decIP(ip)
+		}
+	}, nil
+}
+
+// releaseIP 清理计数为 0 的 IP（Upsert 对不存在的 key 会写入初始值）
+func (c *Controller) releaseIP(ip string) {
+	c.perIP.RemoveCb(ip, func(_ string, n int, exists bool) bool {
+		return exists && n <= 0
+	})
+}
+
+func (c *Controller) decIP(ip string) {
+	c.perIP.Upsert(ip, 0, func(_ bool, cur, _ int) int {
+		return cur - 1
+	})
+	c.releaseIP(ip)
+}
+
+// Connections 返回当前准入的连接数
+func (c *Controller) Connections() int64 {
+	return c.total.Load()
+}
+
+func matchAny(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// OriginAllowed 按当前配置检查 origin 能否访问 gameID（gameID 为空时检查任一游戏），
+// 用于未声明游戏的连接首次发送聊天请求时校验其目标游戏
+func (c *Controller) OriginAllowed(gameID, origin string) bool {
+	return originAllowed(c.cfg.Load().Origins, gameID, origin)
+}
+
+// originAllowed 非浏览器客户端不带 Origin，直接放行
+func originAllowed(origins map[string][]string, gameID, origin string) bool {
+	if origin == "" || len(origins) == 0 {
+		return true
+	}
+	if list, ok := origins[gameID]; ok {
+		return containsOrigin(list, origin)
+	}
+	for _, list := range origins {
+		if containsOrigin(list, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsOrigin(list []string, origin string) bool {
+	for _, o := range list {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,313 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"game-gateway/internal/logger"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrUnavailable 所有后端连接均不可用（或发送队列已满）
+var ErrUnavailable = errors.New("no backend connection available")
+
+// Options 连接池参数
+type Options struct {
+	Size         int           // 每个后端实例的连接数
+	QueueSize    int           // 每条连接的发送队列容量
+	DialTimeout  time.Duration // 建连（含 WebSocket 握手）超时
+	WriteTimeout time.Duration // 单次写超时
+	PingInterval time.Duration // 心跳间隔
+	PongTimeout  time.Duration // 超过 PingInterval+PongTimeout 未收到任何数据即判定连接失效
+	MaxBackoff   time.Duration // 重连退避上限
+	Header       http.Header   // 建连时附加的请求头（如 Gateway 实例 ID）
+}
+
+// DefaultOptions 默认连接池参数
+func DefaultOptions() Options {
+	return Options{
+		Size:         4,
+		QueueSize:    1024,
+		DialTimeout:  3 * time.Second,
+		WriteTimeout: 5 * time.Second,
+		PingInterval: 5 * time.Second,
+		PongTimeout:  5 * time.Second,
+		MaxBackoff:   10 * time.Second,
+	}
+}
+
+// Option 连接池选项
+type Option func(*Options)
+
+// WithSize 设置每个实例的连接数
+func WithSize(n int) Option {
+	return func(o *Options) {
+		if n > 0 {
+			o.Size = n
+		}
+	}
+}
+
+// WithQueueSize 设置每条连接的发送队列容量
+func WithQueueSize(n int) Option {
+	return func(o *Options) {
+		if n > 0 {
+			o.QueueSize = n
+		}
+	}
+}
+
+// WithHeartbeat 设置心跳间隔与超时
+func WithHeartbeat(interval, timeout time.Duration) Option {
+	return func(o *Options) {
+		if interval > 0 {
+			o.PingInterval = interval
+		}
+		if timeout > 0 {
+			o.PongTimeout = timeout
+		}
+	}
+}
+
+// WithHeader 建连时附加请求头
+func WithHeader(key, value string) Option {
+	return func(o *Options) {
+		if o.Header == nil {
+			o.Header = make(http.Header)
+		}
+		o.Header.Set(key, value)
+	}
+}
+
+// Pool 到一组后端实例（如 Chat Service）的 WebSocket 连接池
+// 每个实例维持 Size 条连接，连接断开或心跳超时后标记为不可用并自动重连；
+// Send 轮询选择可用连接，失效连接中未发出的消息转投到其他可用连接（failover）
+type Pool struct {
+	name    string
+	opts    Options
+	conns   []*conn
+	next    atomic.Uint64
+	handler func(data []byte)
+
+	dropped atomic.Uint64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewPool 创建连接池并开始连接 addrs（host:port），handler 在读 goroutine 中处理后端下行消息
+func NewPool(name string, addrs []string, handler func(data []byte), opts ...Option) (*Pool, error) {
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("backend %s: no address configured", name)
+	}
+	o := DefaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{
+		name:    name,
+		opts:    o,
+		handler: handler,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	for _, addr := range addrs {
+		for i := 0; i < o.Size; i++ {
+			p.conns = append(p.conns, &conn{
+				pool: p,
+				addr: addr,
+				send: make(chan []byte, o.QueueSize),
+			})
+		}
+	}
+	for _, c := range p.conns {
+		p.wg.Add(1)
+		go c.run()
+	}
+	return p, nil
+}
+
+// Send 投递一条消息到任一可用连接
+func (p *Pool) Send(data []byte) error {
+	n := uint64(len(p.conns))
+	start := p.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		c := p.conns[(start+i)%n]
+		if !c.healthy.Load() {
+			continue
+		}
+		select {
+		case c.send <- data:
+			return nil
+		default: // 队列满，尝试下一条连接
+		}
+	}
+	return ErrUnavailable
+}
+
+// Available 返回当前可用的连接数
+func (p *Pool) Available() int {
+	n := 0
+	for _, c := range p.conns {
+		if c.healthy.Load() {
+			n++
+		}
+	}
+	return n
+}
+
+// Check 健康检查：至少有一条可用连接
+func (p *Pool) Check() error {
+	if p.Available() == 0 {
+		return fmt.Errorf("backend %s: %w", p.name, ErrUnavailable)
+	}
+	return nil
+}
+
+// Dropped 返回 failover 时因无可用连接而丢弃的消息数
+func (p *Pool) Dropped() uint64 {
+	return p.dropped.Load()
+}
+
+// Close 关闭所有连接并停止重连
+func (p *Pool) Close() {
+	p.cancel()
+	p.wg.Wait()
+}
+
+// failover 将失效连接中未发出的消息转投到其他连接
+func (p *Pool) failover(data []byte) {
+	if err := p.Send(data); err != nil {
+		p.dropped.Add(1)
+		logger.Warn(logger.TagBackend, "Backend message dropped during failover | Backend: %s, Size: %d", p.name, len(data))
+	}
+}
+
+func (p *Pool) backoff(attempt int) time.Duration {
+	d := 100 * time.Millisecond << min(attempt, 10)
+	if d > p.opts.MaxBackoff {
+		d = p.opts.MaxBackoff
+	}
+	return d
+}
+
+type conn struct {
+	pool    *Pool
+	addr    string
+	send    chan []byte
+	healthy atomic.Bool
+}
+
+// run 维持连接：断开后按指数退避重连，直到连接池关闭
+func (c *conn) run() {
+	p := c.pool
+	defer p.wg.Done()
+
+	dialer := websocket.Dialer{HandshakeTimeout: p.opts.DialTimeout}
+	url := "ws://" + c.addr + "/"
+	for attempt := 0; ; {
+		ws, _, err := dialer.DialContext(p.ctx, url, p.opts.Header)
+		if err != nil {
+			if p.ctx.Err() != nil {
+				return
+			}
+			logger.Warn(logger.TagBackend, "Backend dial failed | Backend: %s, Addr: %s, Attempt: %d, Error: %v", p.name, c.addr, attempt+1, err)
+			select {
+			case <-time.After(p.backoff(attempt)):
+			case <-p.ctx.Done():
+				return
+			}
+			attempt++
+			continue
+		}
+		attempt = 0
+
+		logger.Info(logger.TagBackend, "Backend connected | Backend: %s, Addr: %s", p.name, c.addr)
+		err = c.serve(ws)
+		if p.ctx.Err() != nil {
+			return
+		}
+		logger.Warn(logger.TagBackend, "Backend connection lost | Backend: %s, Addr: %s, Error: %v", p.name, c.addr, err)
+	}
+}
+
+// serve 收发消息直到连接失效或连接池关闭
+func (c *conn) serve(ws *websocket.Conn) error {
+	p := c.pool
+	deadline := p.opts.PingInterval + p.opts.PongTimeout
+
+	readErr := make(chan error, 1)
+	go func() {
+		ws.SetReadDeadline(time.Now().Add(deadline))
+		ws.SetPongHandler(func(string) error {
+			return ws.SetReadDeadline(time.Now().Add(deadline))
+		})
+		for {
+			_, data, err := ws.ReadMessage()
+			if err != nil {
+				readErr <- err
+				return
+			}
+			ws.SetReadDeadline(time.Now().Add(deadline))
+			p.handler(data)
+		}
+	}()
+
+	c.healthy.Store(true)
+	ticker := time.NewTicker(p.opts.PingInterval)
+	defer ticker.Stop()
+
+	var err error
+	readDone := false
+loop:
+	for {
+		select {
+		case data := <-c.send:
+			ws.SetWriteDeadline(time.Now().Add(p.opts.WriteTimeout))
+			if err = ws.WriteMessage(websocket.BinaryMessage, data); err != nil {
+				c.healthy.Store(false)
+				p.failover(data)
+				break loop
+			}
+		case <-ticker.C:
+			if err = ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(p.opts.WriteTimeout)); err != nil {
+				break loop
+			}
+		case err = <-readErr:
+			readDone = true
+			break loop
+		case <-p.ctx.Done():
+			c.healthy.Store(false)
+			ws.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "gateway shutting down"),
+				time.Now().Add(time.Second))
+			ws.Close()
+			<-readErr
+			return p.ctx.Err()
+		}
+	}
+
+	c.healthy.Store(false)
+	ws.Close()
+	if !readDone {
+		<-readErr
+	}
+	// 未发出的消息转投到其他连接
+	for {
+		select {
+		case data := <-c.send:
+			p.failover(data)
+		default:
+			return err
+		}
+	}
+}
@@ -1,9 +1,18 @@
 package config
 
 import (
+	"errors"
 	"flag"
-	"log"
+	"fmt"
+	"os"
+	"strings"
+	"time"
 
+	"game-gateway/internal/logger"
+	"game-pkg/mq"
+	"game-pkg/tracing"
+
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 )
 
@@ -12,46 +21,228 @@ type Config struct {
 		Host string `mapstructure:"host"`
 		Port int    `mapstructure:"port"`
 		Env  string `mapstructure:"env"`
+		// MetricsHost / MetricsPort /metrics 及 pprof（非 prod）的 HTTP 监听地址，与客户端端口分离，应只监听内网
+		MetricsHost string `mapstructure:"metrics_host"`
+		MetricsPort int    `mapstructure:"metrics_port"`
+		// ShutdownTimeout 优雅下线时等待发送队列清空的最长时间
+		ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+		// ReconnectDelay 下线通知中建议客户端的重连等待时间
+		ReconnectDelay time.Duration `mapstructure:"reconnect_delay"`
 	} `mapstructure:"server"`
 
 	Games []GameConfig `mapstructure:"games"`
 
-	Redis struct {
-		Addr     string `mapstructure:"addr"`
-		Password string `mapstructure:"password"`
-	} `mapstructure:"redis"`
+	Session struct {
+		// SendQueue 下行发送队列（按优先级分类）及慢消费者策略
+		SendQueue struct {
+			High                 QueueClassConfig `mapstructure:"high"`   // ACK、私聊
+			Normal               QueueClassConfig `mapstructure:"normal"` // 频道聊天
+			Low                  QueueClassConfig `mapstructure:"low"`    // 系统通知
+			DisconnectAfterDrops int              `mapstructure:"disconnect_after_drops"`
+			DisconnectWindow     time.Duration    `mapstructure:"disconnect_window"`
+		} `mapstructure:"send_queue"`
+		// Resume 断线续传：断线后保留用户最近的下行包，客户端携带 Token 重连时补发
+		Resume struct {
+			Enabled    bool          `mapstructure:"enabled"`
+			ReplaySize int           `mapstructure:"replay_size"` // 每个用户保留的最近下行包数
+			Window     time.Duration `mapstructure:"window"`      // 断线后保留续传状态的时长
+		} `mapstructure:"resume"`
+	} `mapstructure:"session"`
+
+	// RateLimit 上行令牌桶限流
+	RateLimit struct {
+		Enabled  bool                       `mapstructure:"enabled"`
+		IPHeader string                     `mapstructure:"ip_header"` // 可信代理传递客户端 IP 的请求头，为空使用连接地址
+		IPHops   int                        `mapstructure:"ip_hops"`   // 向 ip_header 追加地址的可信代理层数，从右数第 ip_hops 个为客户端 IP
+		Session  RateLimitConfig            `mapstructure:"session"`   // 单个会话
+		User     RateLimitConfig            `mapstructure:"user"`      // 同一用户所有会话
+		IP       RateLimitConfig            `mapstructure:"ip"`        // 同一 IP 所有会话
+		Routes   map[string]RateLimitConfig `mapstructure:"routes"`    // 单个会话按路由: game / chat / system
+		Ban      struct {
+			Violations int           `mapstructure:"violations"` // Window 内超限次数达到该值即断开并封禁
+			Window     time.Duration `mapstructure:"window"`
+			Duration   time.Duration `mapstructure:"duration"` // 封禁时长（用户和 IP）
+		} `mapstructure:"ban"`
+	} `mapstructure:"rate_limit"`
+
+	// Admission 连接准入控制（WebSocket 升级前），修改配置文件后热更新
+	Admission struct {
+		MaxConnections int             `mapstructure:"max_connections"` // 总连接数上限，0 不限制
+		MaxPerIP       int             `mapstructure:"max_per_ip"`      // 单个 IP 连接数上限，0 不限制
+		Allow          []string        `mapstructure:"allow"`           // CIDR 白名单，非空时只接受其中的地址
+		Deny           []string        `mapstructure:"deny"`            // CIDR 黑名单，优先于白名单
+		ConnectRate    RateLimitConfig `mapstructure:"connect_rate"`    // 全网关新建连接速率（重连风暴保护）
+	} `mapstructure:"admission"`
+
+	// Admin 运维管理 API（会话查询 / 踢下线 / 测试推送 / 日志级别），监听独立地址
+	Admin struct {
+		Addr      string `mapstructure:"addr"`       // 监听地址，为空不启动
+		Token     string `mapstructure:"token"`      // Bearer Token，可由环境变量 GATEWAY_ADMIN_TOKEN 覆盖，为空不启动
+		TokenFile string `mapstructure:"token_file"` // 从文件读取 Token（如 K8s Secret 挂载），优先于 token
+	} `mapstructure:"admin"`
+
+	// Tracing 链路追踪（OpenTelemetry），上下文经 ChatRequest.meta 及 Envelope 跨服务传递
+	Tracing tracing.Config `mapstructure:"tracing"`
+
+	Redis RedisConfig `mapstructure:"redis"`
 
 	MQ struct {
-		Type     string `mapstructure:"type"`
+		Type     string `mapstructure:"type"` // robustmq / redis / none
 		RobustMQ struct {
-			Broker   string `mapstructure:"broker"`
-			ClientID string `mapstructure:"client_id"`
-			Username string `mapstructure:"username"`
-			Password string `mapstructure:"password"`
+			Broker       string `mapstructure:"broker"`
+			ClientID     string `mapstructure:"client_id"`
+			Username     string `mapstructure:"username"`
+			Password     string `mapstructure:"password"`
+			PasswordFile string `mapstructure:"password_file"` // 从文件读取密码，优先于 password
 		} `mapstructure:"robustmq"`
-		Redis struct {
-			Addr     string `mapstructure:"addr"`
-			Password string `mapstructure:"password"`
-		} `mapstructure:"redis"`
+		Redis RedisConfig `mapstructure:"redis"`
+		// Subscribe 订阅缓冲区与背压策略
+		Subscribe mq.SubscribeConfig `mapstructure:"subscribe"`
 	} `mapstructure:"mq"`
 }
 
+// RedisConfig Redis 连接配置
+type RedisConfig struct {
+	Addr         string `mapstructure:"addr"`
+	Password     string `mapstructure:"password"`
+	PasswordFile string `mapstructure:"password_file"` // 从文件读取密码，优先于 password
+}
+
+// QueueClassConfig 单个优先级发送队列配置
+type QueueClassConfig struct {
+	Size   int    `mapstructure:"size"`
+	Policy string `mapstructure:"policy"` // drop_oldest / drop_newest / coalesce / disconnect
+}
+
+// RateLimitConfig 令牌桶参数，Rate <= 0 表示不限制
+type RateLimitConfig struct {
+	Rate  float64 `mapstructure:"rate"`  // 每秒令牌数
+	Burst int     `mapstructure:"burst"` // 桶容量（允许的突发）
+}
+
 type GameConfig struct {
 	ID          string        `mapstructure:"id"`
 	GameBackend BackendConfig `mapstructure:"game_backend"`
 	ChatBackend BackendConfig `mapstructure:"chat_backend"`
+	// DuplicateLogin 同一用户在不同设备重复登录的策略: allow / kick_old / reject_new
+	DuplicateLogin string `mapstructure:"duplicate_login"`
+	// AllowedOrigins 浏览器客户端允许的 Origin（"*" 表示任意），为空时须在其他游戏的列表中（所有游戏均为空不校验）
+	AllowedOrigins []string `mapstructure:"allowed_origins"`
+	// MaxPayloadBytes 该游戏上行包 Payload 的大小上限，0 不限制
+	MaxPayloadBytes int `mapstructure:"max_payload_bytes"`
 }
 
 type BackendConfig struct {
 	Host     string `mapstructure:"host"`
 	Port     int    `mapstructure:"port"`
 	PoolSize int    `mapstructure:"pool_size"`
+	// Transport 转发方式: mq（默认）/ direct（直连连接池，仅 chat_backend 支持）
+	Transport string `mapstructure:"transport"`
+	// Addrs direct 模式下的多个后端实例（host:port），连接失效时切换到其他实例；为空则使用 Host:Port
+	Addrs []string `mapstructure:"addrs"`
+	// Token direct 模式下建连携带的共享密钥（Authorization: Bearer），与 Chat Service 的 server.gateway_token 一致
+	Token     string `mapstructure:"token"`
+	TokenFile string `mapstructure:"token_file"` // 从文件读取，优先于 token
+}
+
+// GameIDs 返回配置的所有 GameID
+func (c *Config) GameIDs() []string {
+	ids := make([]string, 0, len(c.Games))
+	for _, g := range c.Games {
+		ids = append(ids, g.ID)
+	}
+	return ids
 }
 
+// EnvPrefix 环境变量覆盖前缀：配置项路径转为大写、"." 替换为 "_"
+// 如 GATEWAY_SERVER_PORT、GATEWAY_MQ_ROBUSTMQ_BROKER、GATEWAY_ADMIN_TOKEN_FILE；games 列表只能在文件中配置
+const EnvPrefix = "GATEWAY"
+
+var checkOnly bool
+
+// CheckOnly 是否以 -check-config 启动：Load 校验通过后应直接退出
+func CheckOnly() bool {
+	return checkOnly
+}
+
+// setDefaults 未在文件及环境变量中配置的项使用的默认值（同时使这些项可被环境变量覆盖）
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("server.host", "0.0.0.0")
+	v.SetDefault("server.port", 8080)
+	v.SetDefault("server.env", "dev")
+	v.SetDefault("server.metrics_host", "127.0.0.1")
+	v.SetDefault("server.metrics_port", 6060)
+	v.SetDefault("server.shutdown_timeout", "10s")
+	v.SetDefault("server.reconnect_delay", "1s")
+
+	v.SetDefault("session.send_queue.high.size", 512)
+	v.SetDefault("session.send_queue.high.policy", "disconnect")
+	v.SetDefault("session.send_queue.normal.size", 1024)
+	v.SetDefault("session.send_queue.normal.policy", "drop_oldest")
+	v.SetDefault("session.send_queue.low.size", 256)
+	v.SetDefault("session.send_queue.low.policy", "coalesce")
+	v.SetDefault("session.send_queue.disconnect_after_drops", 50)
+	v.SetDefault("session.send_queue.disconnect_window", "10s")
+	v.SetDefault("session.resume.enabled", true)
+	v.SetDefault("session.resume.replay_size", 256)
+	v.SetDefault("session.resume.window", "30s")
+
+	v.SetDefault("rate_limit.enabled", true)
+	v.SetDefault("rate_limit.ip_header", "")
+	v.SetDefault("rate_limit.ip_hops", 1)
+	v.SetDefault("rate_limit.session.rate", 20)
+	v.SetDefault("rate_limit.session.burst", 40)
+	v.SetDefault("rate_limit.user.rate", 30)
+	v.SetDefault("rate_limit.user.burst", 60)
+	v.SetDefault("rate_limit.ip.rate", 200)
+	v.SetDefault("rate_limit.ip.burst", 400)
+	v.SetDefault("rate_limit.ban.violations", 50)
+	v.SetDefault("rate_limit.ban.window", "10s")
+	v.SetDefault("rate_limit.ban.duration", "5m")
+
+	v.SetDefault("admission.max_connections", 100000)
+	v.SetDefault("admission.max_per_ip", 200)
+	v.SetDefault("admission.allow", []string{})
+	v.SetDefault("admission.deny", []string{})
+	v.SetDefault("admission.connect_rate.rate", 2000)
+	v.SetDefault("admission.connect_rate.burst", 5000)
+
+	v.SetDefault("admin.addr", "127.0.0.1:6070")
+	v.SetDefault("admin.token", "")
+	v.SetDefault("admin.token_file", "")
+
+	v.SetDefault("tracing.exporter", "none")
+	v.SetDefault("tracing.file", "traces-gateway.json")
+	v.SetDefault("tracing.endpoint", "localhost:4318")
+	v.SetDefault("tracing.insecure", true)
+	v.SetDefault("tracing.sample_ratio", 1.0)
+
+	v.SetDefault("redis.addr", "localhost:6379")
+	v.SetDefault("redis.password", "")
+	v.SetDefault("redis.password_file", "")
+
+	v.SetDefault("mq.type", "robustmq")
+	v.SetDefault("mq.robustmq.broker", "tcp://localhost:1883")
+	v.SetDefault("mq.robustmq.client_id", "gateway-1")
+	v.SetDefault("mq.robustmq.username", "")
+	v.SetDefault("mq.robustmq.password", "")
+	v.SetDefault("mq.robustmq.password_file", "")
+	v.SetDefault("mq.redis.addr", "localhost:6379")
+	v.SetDefault("mq.redis.password", "")
+	v.SetDefault("mq.redis.password_file", "")
+	v.SetDefault("mq.subscribe.buffer_size", 4096)
+	v.SetDefault("mq.subscribe.overflow", "block")
+	v.SetDefault("mq.subscribe.block_timeout", "1s")
+	v.SetDefault("mq.subscribe.spill_dir", "")
+}
+
+// Load 读取配置：默认值 < 配置文件 < GATEWAY_* 环境变量 < *_file 密钥文件，并校验
+// -config 指定的文件不存在时报错；未指定且默认路径下没有配置文件时只使用默认值及环境变量
 func Load() (*Config, error) {
 	// 支持 -config 命令行参数
 	var configPath string
 	flag.StringVar(&configPath, "config", "", "配置文件路径")
+	flag.BoolVar(&checkOnly, "check-config", false, "校验配置文件后退出")
 	flag.Parse()
 
 	if configPath != "" {
@@ -65,16 +256,79 @@ func Load() (*Config, error) {
 		viper.AddConfigPath(".")
 	}
 
+	setDefaults(viper.GetViper())
+	viper.SetEnvPrefix(EnvPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv()
 
 	if err := viper.ReadInConfig(); err != nil {
-		log.Printf("Warning: Config file not found, using defaults or env vars: %v", err)
+		var notFound viper.ConfigFileNotFoundError
+		if configPath != "" || !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("read config: %w", err)
+		}
+		logger.Warn(logger.TagSystem, "Config file not found, using defaults and %s_* env vars", EnvPrefix)
+	} else {
+		logger.Info(logger.TagSystem, "Loaded config from %s", viper.ConfigFileUsed())
 	}
 
+	return decode()
+}
+
+// decode 解析当前 viper 配置，读取密钥文件并校验；文件中出现未知配置项（多为拼写错误）时报错
+func decode() (*Config, error) {
 	var cfg Config
-	if err := viper.Unmarshal(&cfg); err != nil {
+	if err := viper.UnmarshalExact(&cfg); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	if err := cfg.loadSecrets(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
-
 	return &cfg, nil
 }
+
+// loadSecrets {key}_file 非空时读取文件内容（去除首尾空白）覆盖 {key}
+func (c *Config) loadSecrets() error {
+	type secret struct {
+		key   string
+		file  string
+		value *string
+	}
+	secrets := []secret{
+		{"admin.token", c.Admin.TokenFile, &c.Admin.Token},
+		{"redis.password", c.Redis.PasswordFile, &c.Redis.Password},
+		{"mq.robustmq.password", c.MQ.RobustMQ.PasswordFile, &c.MQ.RobustMQ.Password},
+		{"mq.redis.password", c.MQ.Redis.PasswordFile, &c.MQ.Redis.Password},
+	}
+	for i := range c.Games {
+		cb := &c.Games[i].ChatBackend
+		secrets = append(secrets, secret{fmt.Sprintf("games[%d].chat_backend.token", i), cb.TokenFile, &cb.Token})
+	}
+	for _, s := range secrets {
+		if s.file == "" {
+			continue
+		}
+		data, err := os.ReadFile(s.file)
+		if err != nil {
+			return fmt.Errorf("%s_file: %w", s.key, err)
+		}
+		*s.value = strings.TrimSpace(string(data))
+	}
+	return nil
+}
+
+// Watch 监听配置文件变化，重新解析并校验通过后回调 fn（用于热更新）；校验失败保留旧配置
+// 只有部分配置项支持热更新，其余变化需重启生效（见 RestartRequired）
+func Watch(fn func(*Config)) {
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		cfg, err := decode()
+		if err != nil {
+			logger.Warn(logger.TagSystem, "Reload config %s rejected, keeping current config: %v", e.Name, err)
+			return
+		}
+		fn(cfg)
+	})
+	viper.WatchConfig()
+}
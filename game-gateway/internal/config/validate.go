@@ -0,0 +1,279 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+
+	"game-gateway/internal/admission"
+	"game-gateway/internal/session"
+	"game-pkg/mq"
+	"game-pkg/registry"
+)
+
+// ValidationError 配置校验失败，列出所有问题（配置项路径: 原因）
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d invalid setting(s):\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// problems 收集校验问题
+type problems []string
+
+func (p *problems) addf(key, format string, args ...any) {
+	*p = append(*p, key+": "+fmt.Sprintf(format, args...))
+}
+
+func (p *problems) port(key string, port int) {
+	if port < 1 || port > 65535 {
+		p.addf(key, "must be between 1 and 65535, got %d", port)
+	}
+}
+
+func (p *problems) nonNegative(key string, n int64) {
+	if n < 0 {
+		p.addf(key, "must not be negative, got %d", n)
+	}
+}
+
+func (p *problems) hostPort(key, addr string) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		p.addf(key, "must be host:port, got %q", addr)
+	}
+}
+
+func (p *problems) limit(key string, l RateLimitConfig) {
+	if l.Rate < 0 {
+		p.addf(key+".rate", "must not be negative, got %v", l.Rate)
+	}
+	p.nonNegative(key+".burst", int64(l.Burst))
+}
+
+// rateLimitRoutes rate_limit.routes 支持的路由名
+var rateLimitRoutes = []string{"game", "chat", "system"}
+
+// Validate 校验所有配置项，返回的 *ValidationError 列出全部问题
+func (c *Config) Validate() error {
+	var p problems
+
+	p.port("server.port", c.Server.Port)
+	p.port("server.metrics_port", c.Server.MetricsPort)
+	if c.Server.MetricsPort == c.Server.Port {
+		p.addf("server.metrics_port", "must differ from server.port (%d)", c.Server.Port)
+	}
+	p.nonNegative("server.shutdown_timeout", int64(c.Server.ShutdownTimeout))
+	p.nonNegative("server.reconnect_delay", int64(c.Server.ReconnectDelay))
+
+	c.validateGames(&p)
+
+	sq := c.Session.SendQueue
+	for _, class := range []struct {
+		name string
+		cfg  QueueClassConfig
+	}{{"high", sq.High}, {"normal", sq.Normal}, {"low", sq.Low}} {
+		key := "session.send_queue." + class.name
+		p.nonNegative(key+".size", int64(class.cfg.Size))
+		if _, err := session.ParseSlowConsumerPolicy(class.cfg.Policy); err != nil {
+			p.addf(key+".policy", "%v", err)
+		}
+	}
+	p.nonNegative("session.send_queue.disconnect_after_drops", int64(sq.DisconnectAfterDrops))
+	p.nonNegative("session.send_queue.disconnect_window", int64(sq.DisconnectWindow))
+	if rc := c.Session.Resume; rc.Enabled {
+		if rc.ReplaySize <= 0 {
+			p.addf("session.resume.replay_size", "must be positive when resume is enabled, got %d", rc.ReplaySize)
+		}
+		if rc.Window <= 0 {
+			p.addf("session.resume.window", "must be positive when resume is enabled, got %s", rc.Window)
+		}
+	}
+
+	rl := c.RateLimit
+	p.limit("rate_limit.session", rl.Session)
+	p.limit("rate_limit.user", rl.User)
+	p.limit("rate_limit.ip", rl.IP)
+	for _, name := range slices.Sorted(maps.Keys(rl.Routes)) {
+		key := "rate_limit.routes." + name
+		if !slices.Contains(rateLimitRoutes, name) {
+			p.addf(key, "unknown route (%s)", strings.Join(rateLimitRoutes, " / "))
+		}
+		p.limit(key, rl.Routes[name])
+	}
+	if rl.IPHeader != "" && rl.IPHops < 1 {
+		p.addf("rate_limit.ip_hops", "must be at least 1 when ip_header is set, got %d", rl.IPHops)
+	}
+	p.nonNegative("rate_limit.ban.violations", int64(rl.Ban.Violations))
+	if rl.Ban.Violations > 0 {
+		if rl.Ban.Window <= 0 {
+			p.addf("rate_limit.ban.window", "must be positive when ban.violations is set, got %s", rl.Ban.Window)
+		}
+		p.nonNegative("rate_limit.ban.duration", int64(rl.Ban.Duration))
+	}
+
+	ac := c.Admission
+	p.nonNegative("admission.max_connections", int64(ac.MaxConnections))
+	p.nonNegative("admission.max_per_ip", int64(ac.MaxPerIP))
+	if _, err := admission.ParseCIDRs(ac.Allow); err != nil {
+		p.addf("admission.allow", "%v", err)
+	}
+	if _, err := admission.ParseCIDRs(ac.Deny); err != nil {
+		p.addf("admission.deny", "%v", err)
+	}
+	p.limit("admission.connect_rate", ac.ConnectRate)
+
+	if c.Admin.Addr != "" {
+		p.hostPort("admin.addr", c.Admin.Addr)
+	}
+
+	c.validateTracing(&p)
+	c.validateMQ(&p)
+
+	if len(p) > 0 {
+		return &ValidationError{Problems: p}
+	}
+	return nil
+}
+
+func (c *Config) validateGames(p *problems) {
+	if len(c.Games) == 0 {
+		p.addf("games", "at least one game is required")
+	}
+	seen := make(map[string]bool, len(c.Games))
+	for i, g := range c.Games {
+		key := fmt.Sprintf("games[%d]", i)
+		switch {
+		case g.ID == "":
+			p.addf(key+".id", "is required")
+		case registry.ValidID(g.ID) != nil:
+			p.addf(key+".id", "%v", registry.ValidID(g.ID))
+		case seen[g.ID]:
+			p.addf(key+".id", "duplicate game %q", g.ID)
+		default:
+			key = fmt.Sprintf("games[%s]", g.ID)
+		}
+		seen[g.ID] = true
+
+		if _, err := session.ParseLoginPolicy(g.DuplicateLogin); err != nil {
+			p.addf(key+".duplicate_login", "%v", err)
+		}
+		for _, origin := range g.AllowedOrigins {
+			if origin == "" {
+				p.addf(key+".allowed_origins", "must not contain empty entries")
+			}
+		}
+		p.nonNegative(key+".max_payload_bytes", int64(g.MaxPayloadBytes))
+
+		cb := g.ChatBackend
+		switch cb.Transport {
+		case "", "mq":
+			if c.MQ.Type == "none" {
+				p.addf(key+".chat_backend.transport", "must be direct when mq.type is none")
+			}
+		case "direct":
+			if len(cb.Addrs) == 0 {
+				if cb.Host == "" {
+					p.addf(key+".chat_backend.host", "is required for direct transport without addrs")
+				}
+				p.port(key+".chat_backend.port", cb.Port)
+			}
+			for _, addr := range cb.Addrs {
+				p.hostPort(key+".chat_backend.addrs", addr)
+			}
+			if cb.Token == "" {
+				p.addf(key+".chat_backend.token", "is required for direct transport (or token_file), must match chat server.gateway_token")
+			}
+		default:
+			p.addf(key+".chat_backend.transport", "unknown transport %q (mq / direct)", cb.Transport)
+		}
+		p.nonNegative(key+".chat_backend.pool_size", int64(cb.PoolSize))
+	}
+}
+
+func (c *Config) validateTracing(p *problems) {
+	tc := c.Tracing
+	switch tc.Exporter {
+	case "", "none", "stdout", "otlp":
+	case "file":
+		if tc.File == "" {
+			p.addf("tracing.file", "is required for the file exporter")
+		}
+	default:
+		p.addf("tracing.exporter", "unknown exporter %q (none / file / stdout / otlp)", tc.Exporter)
+	}
+	if tc.SampleRatio < 0 || tc.SampleRatio > 1 {
+		p.addf("tracing.sample_ratio", "must be between 0 and 1, got %v", tc.SampleRatio)
+	}
+}
+
+func (c *Config) validateMQ(p *problems) {
+	switch c.MQ.Type {
+	case "none":
+		return
+	case "robustmq":
+		rc := c.MQ.RobustMQ
+		if u, err := url.Parse(rc.Broker); err != nil || u.Scheme == "" || u.Host == "" {
+			p.addf("mq.robustmq.broker", "must be a URL such as tcp://host:1883, got %q", rc.Broker)
+		}
+		if rc.ClientID == "" {
+			p.addf("mq.robustmq.client_id", "is required (unique per instance)")
+		}
+	case "redis":
+		p.hostPort("mq.redis.addr", c.MQ.Redis.Addr)
+	default:
+		p.addf("mq.type", "unknown type %q (robustmq / redis / none)", c.MQ.Type)
+		return
+	}
+
+	sc := c.MQ.Subscribe
+	p.nonNegative("mq.subscribe.buffer_size", int64(sc.BufferSize))
+	if _, err := mq.ParseOverflowPolicy(sc.Overflow); err != nil {
+		p.addf("mq.subscribe.overflow", "%v", err)
+	}
+	p.nonNegative("mq.subscribe.block_timeout", int64(sc.BlockTimeout))
+}
+
+// RestartRequired 返回 old 与 next 之间不支持热更新的变化（配置项路径），需重启后生效
+// 支持热更新的项：rate_limit（enabled、ip_header、ip_hops 除外）、admission、增删游戏（chat_backend.transport=direct 除外）、
+// games[*].duplicate_login / allowed_origins / max_payload_bytes；日志级别及标签见 logger.yaml
+func RestartRequired(old, next *Config) []string {
+	var changed []string
+	diff := func(key string, a, b any) {
+		if !reflect.DeepEqual(a, b) {
+			changed = append(changed, key)
+		}
+	}
+	diff("server", old.Server, next.Server)
+	diff("session", old.Session, next.Session)
+	diff("rate_limit.enabled", old.RateLimit.Enabled, next.RateLimit.Enabled)
+	diff("rate_limit.ip_header", old.RateLimit.IPHeader, next.RateLimit.IPHeader)
+	diff("rate_limit.ip_hops", old.RateLimit.IPHops, next.RateLimit.IPHops)
+	diff("admin", old.Admin, next.Admin)
+	diff("tracing", old.Tracing, next.Tracing)
+	diff("redis", old.Redis, next.Redis)
+	diff("mq", old.MQ, next.MQ)
+
+	oldGames := make(map[string]GameConfig, len(old.Games))
+	for _, g := range old.Games {
+		oldGames[g.ID] = g
+	}
+	for _, g := range next.Games {
+		prev, ok := oldGames[g.ID]
+		if !ok {
+			// 新增游戏经 MQ 转发聊天请求即可生效，直连连接池只在启动时创建
+			if g.ChatBackend.Transport == "direct" {
+				changed = append(changed, fmt.Sprintf("games[%s].chat_backend", g.ID))
+			}
+			continue
+		}
+		diff(fmt.Sprintf("games[%s].game_backend", g.ID), prev.GameBackend, g.GameBackend)
+		diff(fmt.Sprintf("games[%s].chat_backend", g.ID), prev.ChatBackend, g.ChatBackend)
+	}
+	return changed
+}
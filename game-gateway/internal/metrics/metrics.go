@@ -1,9 +1,11 @@
 package metrics
 
 import (
-	"log"
 	"sync/atomic"
 	"time"
+
+	"game-gateway/internal/logger"
+	"game-pkg/mq"
 )
 
 // Metrics 性能指标收集器
@@ -21,6 +23,31 @@ type Metrics struct {
 
 	// 性能统计
 	SlowMessages uint64 // 处理时间 > 100ms 的消息数
+
+	// 慢消费者统计（会话发送队列满时按策略处理）
+	SendDroppedOldest       uint64 // 丢弃旧消息腾出空间
+	SendDroppedNewest       uint64 // 丢弃新消息
+	SendCoalesced           uint64 // 与待发送消息合并
+	SlowConsumerDisconnects uint64 // 因持续丢弃被断开的会话
+
+	// 断线续传统计
+	SessionsResumed uint64 // 续传成功的连接数
+	ResumeFailed    uint64 // 携带 Token 但续传失败（过期/无效）的连接数
+	PacketsReplayed uint64 // 续传时补发的下行包数
+
+	// 上行限流统计
+	RateLimited   uint64 // 被限流丢弃的上行包数
+	RateLimitBans uint64 // 因反复超限被断开并封禁的连接数
+
+	// 连接准入统计
+	AdmissionRejected uint64 // 升级前被拒绝的连接数
+
+	// MQ 订阅侧统计及连接状态来源（由 MQ 实现提供）
+	mqStats atomic.Pointer[func() mq.Stats]
+	mqState atomic.Pointer[func() mq.ConnState]
+
+	// 按游戏的会话统计来源（由 Session Manager 提供）
+	sessionStats atomic.Pointer[func() map[string]SessionStats]
 }
 
 var GlobalMetrics = &Metrics{}
@@ -62,21 +89,95 @@ func (m *Metrics) IncrementSlowMessages() {
 	atomic.AddUint64(&m.SlowMessages, 1)
 }
 
-// PrintStats 打印统计信息
+// IncrementSendDroppedOldest 增加丢弃旧消息数
+func (m *Metrics) IncrementSendDroppedOldest() {
+	atomic.AddUint64(&m.SendDroppedOldest, 1)
+}
+
+// IncrementSendDroppedNewest 增加丢弃新消息数
+func (m *Metrics) IncrementSendDroppedNewest() {
+	atomic.AddUint64(&m.SendDroppedNewest, 1)
+}
+
+// IncrementSendCoalesced 增加合并消息数
+func (m *Metrics) IncrementSendCoalesced() {
+	atomic.AddUint64(&m.SendCoalesced, 1)
+}
+
+// IncrementSlowConsumerDisconnects 增加慢消费者断开数
+func (m *Metrics) IncrementSlowConsumerDisconnects() {
+	atomic.AddUint64(&m.SlowConsumerDisconnects, 1)
+}
+
+// IncrementSessionsResumed 记录一次续传成功及补发的包数
+func (m *Metrics) IncrementSessionsResumed(replayed int) {
+	atomic.AddUint64(&m.SessionsResumed, 1)
+	atomic.AddUint64(&m.PacketsReplayed, uint64(replayed))
+}
+
+// IncrementResumeFailed 增加续传失败数
+func (m *Metrics) IncrementResumeFailed() {
+	atomic.AddUint64(&m.ResumeFailed, 1)
+}
+
+// IncrementRateLimited 增加被限流的上行包数
+func (m *Metrics) IncrementRateLimited() {
+	atomic.AddUint64(&m.RateLimited, 1)
+}
+
+// IncrementRateLimitBans 增加限流封禁数
+func (m *Metrics) IncrementRateLimitBans() {
+	atomic.AddUint64(&m.RateLimitBans, 1)
+}
+
+// IncrementAdmissionRejected 增加准入拒绝数
+func (m *Metrics) IncrementAdmissionRejected() {
+	atomic.AddUint64(&m.AdmissionRejected, 1)
+}
+
+// SetMQStatsSource 设置 MQ 订阅侧统计来源
+func (m *Metrics) SetMQStatsSource(fn func() mq.Stats) {
+	m.mqStats.Store(&fn)
+}
+
+// MQStats 返回 MQ 订阅侧统计（未设置来源时为零值）
+func (m *Metrics) MQStats() mq.Stats {
+	if fn := m.mqStats.Load(); fn != nil {
+		return (*fn)()
+	}
+	return mq.Stats{}
+}
+
+// SetMQStateSource 设置 MQ 连接状态来源
+func (m *Metrics) SetMQStateSource(fn func() mq.ConnState) {
+	m.mqState.Store(&fn)
+}
+
+// MQState 返回 MQ 连接状态（未设置来源时视为 StateConnecting）
+func (m *Metrics) MQState() mq.ConnState {
+	if fn := m.mqState.Load(); fn != nil {
+		return (*fn)()
+	}
+	return mq.StateConnecting
+}
+
+// PrintStats 输出统计信息（SYSTEM 标签，默认启用）
 func (m *Metrics) PrintStats() {
-	log.Printf("========== Gateway Metrics ==========")
-	log.Printf("Connections:")
-	log.Printf("  Total:        %d", atomic.LoadUint64(&m.TotalConnections))
-	log.Printf("  Active:       %d", atomic.LoadUint64(&m.ActiveConnections))
-	log.Printf("  Disconnected: %d", atomic.LoadUint64(&m.TotalDisconnections))
-	log.Printf("Messages:")
-	log.Printf("  Received:     %d", atomic.LoadUint64(&m.MessagesReceived))
-	log.Printf("  Sent:         %d", atomic.LoadUint64(&m.MessagesSent))
-	log.Printf("  Routed:       %d", atomic.LoadUint64(&m.MessagesRouted))
-	log.Printf("  Routing Err:  %d", atomic.LoadUint64(&m.RoutingErrors))
-	log.Printf("Performance:")
-	log.Printf("  Slow Msgs:    %d", atomic.LoadUint64(&m.SlowMessages))
-	log.Printf("=====================================")
+	logger.Info(logger.TagSystem, "Connections | Total: %d, Active: %d, Disconnected: %d",
+		atomic.LoadUint64(&m.TotalConnections), atomic.LoadUint64(&m.ActiveConnections), atomic.LoadUint64(&m.TotalDisconnections))
+	logger.Info(logger.TagSystem, "Messages | Received: %d, Sent: %d, Routed: %d, RoutingErr: %d, Slow: %d",
+		atomic.LoadUint64(&m.MessagesReceived), atomic.LoadUint64(&m.MessagesSent), atomic.LoadUint64(&m.MessagesRouted),
+		atomic.LoadUint64(&m.RoutingErrors), atomic.LoadUint64(&m.SlowMessages))
+	logger.Info(logger.TagSystem, "Slow consumers | DropOldest: %d, DropNewest: %d, Coalesced: %d, Disconnected: %d",
+		atomic.LoadUint64(&m.SendDroppedOldest), atomic.LoadUint64(&m.SendDroppedNewest),
+		atomic.LoadUint64(&m.SendCoalesced), atomic.LoadUint64(&m.SlowConsumerDisconnects))
+	logger.Info(logger.TagSystem, "Session resume | Resumed: %d, Failed: %d, Replayed: %d",
+		atomic.LoadUint64(&m.SessionsResumed), atomic.LoadUint64(&m.ResumeFailed), atomic.LoadUint64(&m.PacketsReplayed))
+	logger.Info(logger.TagSystem, "Admission | Throttled: %d, Banned: %d, Rejected: %d",
+		atomic.LoadUint64(&m.RateLimited), atomic.LoadUint64(&m.RateLimitBans), atomic.LoadUint64(&m.AdmissionRejected))
+	mqStats := m.MQStats()
+	logger.Info(logger.TagSystem, "MQ subscribe | State: %s, Reconnects: %d, Delivered: %d, Delayed: %d, Dropped: %d, Spilled: %d",
+		m.MQState(), mqStats.Reconnects, mqStats.Delivered, mqStats.Delayed, mqStats.Dropped, mqStats.Spilled)
 }
 
 // StartPeriodicReport 启动定期报告
@@ -0,0 +1,223 @@
+package metrics
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"game-pkg/mq"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gateway"
+
+// 按游戏 / 路由 / MQ Topic 维度的 Prometheus 指标，经 /metrics 暴露
+var (
+	registry = prometheus.NewRegistry()
+
+	messagesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_total",
+		Help:      "Messages routed by the gateway (direction: in = client -> backend, out = backend -> client)",
+	}, []string{"game_id", "route", "direction"})
+
+	routingErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "routing_errors_total",
+		Help:      "Inbound packets that failed to route",
+	}, []string{"game_id", "route"})
+
+	routingLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "routing_latency_seconds",
+		Help:      "Time spent routing an inbound packet (including MQ publish / backend enqueue)",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"game_id", "route"})
+
+	frameSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "frame_size_bytes",
+		Help:      "WebSocket frame size including the protocol header",
+		Buckets:   prometheus.ExponentialBuckets(64, 4, 8), // 64B ~ 1MB
+	}, []string{"direction"})
+
+	mqPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mq_published_total",
+		Help:      "Messages published to MQ",
+	}, []string{"topic", "result"})
+
+	mqPublishLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "mq_publish_duration_seconds",
+		Help:      "MQ publish latency",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"topic"})
+
+	mqConsumed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mq_consumed_total",
+		Help:      "Messages consumed from MQ subscriptions",
+	}, []string{"topic"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		messagesTotal, routingErrorsTotal, routingLatency, frameSize,
+		mqPublished, mqPublishLatency, mqConsumed,
+		sessionCollector{GlobalMetrics},
+	)
+	GlobalMetrics.registerCounters()
+}
+
+// Handler 返回 /metrics 的 HTTP Handler
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// gameLabel 未知游戏（如未绑定的会话、广播）统一记为 unknown
+func gameLabel(gameID string) string {
+	if gameID == "" {
+		return "unknown"
+	}
+	return gameID
+}
+
+// ObserveInbound 记录一个上行包的路由结果及耗时
+func (m *Metrics) ObserveInbound(gameID, route string, d time.Duration, err error) {
+	game := gameLabel(gameID)
+	messagesTotal.WithLabelValues(game, route, "in").Inc()
+	routingLatency.WithLabelValues(game, route).Observe(d.Seconds())
+	if err != nil {
+		routingErrorsTotal.WithLabelValues(game, route).Inc()
+	}
+}
+
+// ObserveOutbound 记录一条投递到客户端的下行消息
+func (m *Metrics) ObserveOutbound(gameID, route string) {
+	messagesTotal.WithLabelValues(gameLabel(gameID), route, "out").Inc()
+}
+
+// ObserveFrame 记录收发的帧大小，direction 为 in / out
+func (m *Metrics) ObserveFrame(direction string, size int) {
+	frameSize.WithLabelValues(direction).Observe(float64(size))
+}
+
+// IncrementConsumed 记录一条从 topic 消费的 MQ 消息
+func (m *Metrics) IncrementConsumed(topic string) {
+	mqConsumed.WithLabelValues(topic).Inc()
+}
+
+// InstrumentProducer 包装 mq.Producer，统计发布数、失败数及耗时
+func InstrumentProducer(p mq.Producer) mq.Producer {
+	return instrumentedProducer{p}
+}
+
+type instrumentedProducer struct {
+	mq.Producer
+}
+
+func (p instrumentedProducer) Publish(topic string, payload []byte) error {
+	start := time.Now()
+	err := p.Producer.Publish(topic, payload)
+	mqPublishLatency.WithLabelValues(topic).Observe(time.Since(start).Seconds())
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	mqPublished.WithLabelValues(topic, result).Inc()
+	return err
+}
+
+// SessionStats 单个游戏的会话统计
+type SessionStats struct {
+	Sessions int // 在线会话数
+	Queued   int // 所有会话发送队列中待发送的消息数
+}
+
+// SetSessionSource 设置按游戏统计会话的来源（采集时调用）
+func (m *Metrics) SetSessionSource(fn func() map[string]SessionStats) {
+	m.sessionStats.Store(&fn)
+}
+
+var (
+	sessionsDesc = prometheus.NewDesc(namespace+"_sessions_active",
+		"Active client sessions", []string{"game_id"}, nil)
+	queueDepthDesc = prometheus.NewDesc(namespace+"_send_queue_depth",
+		"Messages waiting in session send queues", []string{"game_id"}, nil)
+)
+
+// sessionCollector 采集时遍历会话，避免在连接 / 断开的热路径上维护按游戏的计数
+type sessionCollector struct {
+	m *Metrics
+}
+
+func (c sessionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sessionsDesc
+	ch <- queueDepthDesc
+}
+
+func (c sessionCollector) Collect(ch chan<- prometheus.Metric) {
+	fn := c.m.sessionStats.Load()
+	if fn == nil {
+		return
+	}
+	for gameID, st := range (*fn)() {
+		game := gameLabel(gameID)
+		ch <- prometheus.MustNewConstMetric(sessionsDesc, prometheus.GaugeValue, float64(st.Sessions), game)
+		ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(st.Queued), game)
+	}
+}
+
+// registerCounters 将原有的原子计数器及 MQ 订阅统计导出为 Prometheus 指标
+func (m *Metrics) registerCounters() {
+	counter := func(name, help string, v *uint64) {
+		registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace, Name: name, Help: help,
+		}, func() float64 { return float64(atomic.LoadUint64(v)) }))
+	}
+	counter("connections_total", "Accepted WebSocket connections", &m.TotalConnections)
+	counter("disconnections_total", "Closed WebSocket connections", &m.TotalDisconnections)
+	counter("packets_received_total", "Packets read from clients", &m.MessagesReceived)
+	counter("packets_sent_total", "Packets written to clients", &m.MessagesSent)
+	counter("slow_messages_total", "Inbound packets that took longer than 100ms to route", &m.SlowMessages)
+	counter("send_dropped_oldest_total", "Queued messages dropped to make room (drop_oldest)", &m.SendDroppedOldest)
+	counter("send_dropped_newest_total", "New messages dropped on a full send queue", &m.SendDroppedNewest)
+	counter("send_coalesced_total", "Messages coalesced with a pending message", &m.SendCoalesced)
+	counter("slow_consumer_disconnects_total", "Sessions disconnected for dropping too many messages", &m.SlowConsumerDisconnects)
+	counter("sessions_resumed_total", "Connections that resumed a previous session", &m.SessionsResumed)
+	counter("resume_failed_total", "Resume attempts with an invalid or expired token", &m.ResumeFailed)
+	counter("packets_replayed_total", "Packets replayed to resumed sessions", &m.PacketsReplayed)
+	counter("rate_limited_total", "Inbound packets rejected by rate limiting", &m.RateLimited)
+	counter("rate_limit_bans_total", "Connections banned for repeatedly exceeding rate limits", &m.RateLimitBans)
+	counter("admission_rejected_total", "Connections rejected before WebSocket upgrade", &m.AdmissionRejected)
+
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace, Name: "connections_active", Help: "Open WebSocket connections",
+	}, func() float64 { return float64(atomic.LoadUint64(&m.ActiveConnections)) }))
+
+	mqCounter := func(name, help string, field func(mq.Stats) uint64) {
+		registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "mq", Name: name, Help: help,
+		}, func() float64 { return float64(field(m.MQStats())) }))
+	}
+	mqCounter("delivered_total", "Messages placed into subscription buffers", func(s mq.Stats) uint64 { return s.Delivered })
+	mqCounter("delayed_total", "Messages delivered after waiting on a full buffer", func(s mq.Stats) uint64 { return s.Delayed })
+	mqCounter("dropped_total", "Messages dropped on a full subscription buffer", func(s mq.Stats) uint64 { return s.Dropped })
+	mqCounter("spilled_total", "Messages spilled to disk", func(s mq.Stats) uint64 { return s.Spilled })
+	mqCounter("reconnects_total", "Successful MQ reconnects", func(s mq.Stats) uint64 { return s.Reconnects })
+
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace, Subsystem: "mq", Name: "connected", Help: "1 if the MQ connection is up",
+	}, func() float64 {
+		if m.MQState() == mq.StateConnected {
+			return 1
+		}
+		return 0
+	}))
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Limit 令牌桶参数：每秒补充 Rate 个令牌，最多积累 Burst 个
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Enabled 是否启用该限制
+func (l Limit) Enabled() bool {
+	return l.Rate > 0
+}
+
+// interval 每个令牌的补充间隔（纳秒）
+func (l Limit) interval() int64 {
+	return int64(float64(time.Second) / l.Rate)
+}
+
+// Bucket 基于 GCRA 的无锁令牌桶，零值可用
+// 只保存一个原子变量 tat（理论到达时间），10 万会话也只需每会话 8 字节
+type Bucket struct {
+	tat atomic.Int64 // UnixNano
+}
+
+// Allow 尝试消耗一个令牌；失败时返回需要等待的时间
+func (b *Bucket) Allow(l Limit, now int64) (bool, time.Duration) {
+	if !l.Enabled() {
+		return true, 0
+	}
+	interval := l.interval()
+	burst := int64(l.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	tolerance := interval * burst
+
+	for {
+		cur := b.tat.Load()
+		tat := cur
+		if tat < now {
+			tat = now
+		}
+		next := tat + interval
+		if wait := next - now - tolerance; wait > 0 {
+			return false, time.Duration(wait)
+		}
+		if b.tat.CompareAndSwap(cur, next) {
+			return true, 0
+		}
+	}
+}
+
+// refund 退还 Allow 成功消耗的一个令牌（后续维度拒绝时调用）
+func (b *Bucket) refund(l Limit) {
+	if l.Enabled() {
+		b.tat.Add(-l.interval())
+	}
+}
+
+// idle 令牌已补满（可以安全丢弃该桶）
+func (b *Bucket) idle(now int64) bool {
+	return b.tat.Load() <= now
+}
@@ -0,0 +1,261 @@
+package ratelimit
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"game-gateway/pkg/protocol"
+
+	cmap "github.com/orcaman/concurrent-map/v2"
+)
+
+// Scope 触发限流的维度
+type Scope string
+
+const (
+	ScopeRoute   Scope = "route"   // 单个会话在某个路由上的速率
+	ScopeSession Scope = "session" // 单个会话的总速率
+	ScopeUser    Scope = "user"    // 同一用户所有会话的总速率
+	ScopeIP      Scope = "ip"      // 同一 IP 所有会话的总速率
+	ScopeBanned  Scope = "banned"  // 用户或 IP 处于封禁期
+)
+
+// Config 限流配置
+type Config struct {
+	Session Limit
+	User    Limit
+	IP      Limit
+	Routes  map[protocol.RouteType]Limit
+
+	// BanWindow 内超限 BanViolations 次即断开连接，并封禁其用户和 IP BanDuration
+	BanViolations int
+	BanWindow     time.Duration
+	BanDuration   time.Duration
+}
+
+// SessionState 单个会话的限流状态，随会话创建，无需加锁
+type SessionState struct {
+	session Bucket
+	routes  [protocol.RouteSystem + 1]Bucket
+
+	windowStart atomic.Int64
+	violations  atomic.Int32
+}
+
+func NewSessionState() *SessionState {
+	return &SessionState{}
+}
+
+// Decision 限流判定结果
+type Decision struct {
+	Allowed    bool
+	Scope      Scope
+	RetryAfter time.Duration
+	Ban        bool // 超限次数达到阈值，应断开连接（用户与 IP 已被封禁）
+}
+
+// Limiter 按会话 / 路由 / 用户 / IP 四个维度做令牌桶限流
+type Limiter struct {
+	cfg atomic.Pointer[Config]
+
+	users cmap.ConcurrentMap[int32, *Bucket]
+	ips   cmap.ConcurrentMap[string, *Bucket]
+	bans  cmap.ConcurrentMap[string, int64] // "user:{id}" / "ip:{addr}" -> 解封时间 UnixNano
+
+	// hasBans 无封禁时跳过查表（热路径只读一个原子变量）
+	hasBans atomic.Bool
+
+	stop chan struct{}
+}
+
+func NewLimiter(cfg Config) *Limiter {
+	l := &Limiter{
+		users: cmap.NewWithCustomShardingFunction[int32, *Bucket](func(key int32) uint32 {
+			return uint32(key)
+		}),
+		ips:  cmap.New[*Bucket](),
+		bans: cmap.New[int64](),
+		stop: make(chan struct{}),
+	}
+	l.cfg.Store(&cfg)
+	return l
+}
+
+// Update 热更新限流配置；已有令牌桶保留当前状态，按新速率继续补充
+func (l *Limiter) Update(cfg Config) {
+	l.cfg.Store(&cfg)
+}
+
+// Config 返回当前配置
+func (l *Limiter) Config() Config {
+	return *l.cfg.Load()
+}
+
+// Allow 判定一个上行包是否放行；userID 为 0 表示会话尚未绑定用户
+func (l *Limiter) Allow(st *SessionState, userID int32, ip string, route protocol.RouteType) Decision {
+	now := time.Now().UnixNano()
+
+	if until, banned := l.bannedUntil(userID, ip, now); banned {
+		return Decision{Scope: ScopeBanned, RetryAfter: time.Duration(until - now), Ban: true}
+	}
+
+	cfg := l.cfg.Load()
+	scope, wait, ok := l.take(cfg, st, userID, ip, route, now)
+	if ok {
+		return Decision{Allowed: true}
+	}
+
+	d := Decision{Scope: scope, RetryAfter: wait}
+	if l.violate(cfg, st, now) {
+		l.ban(cfg, userID, ip, now)
+		d.Ban = true
+	}
+	return d
+}
+
+// take 依次从各维度取令牌；任一维度拒绝时退还已取的令牌，被拒绝的包不消耗任何配额
+func (l *Limiter) take(cfg *Config, st *SessionState, userID int32, ip string, route protocol.RouteType, now int64) (Scope, time.Duration, bool) {
+	type charge struct {
+		scope  Scope
+		bucket *Bucket
+		limit  Limit
+	}
+	charges := make([]charge, 0, 4)
+	if route <= protocol.RouteSystem {
+		charges = append(charges, charge{ScopeRoute, &st.routes[route], cfg.Routes[route]})
+	}
+	charges = append(charges, charge{ScopeSession, &st.session, cfg.Session})
+	if userID != 0 && cfg.User.Enabled() {
+		charges = append(charges, charge{ScopeUser, bucketFor(l.users, userID), cfg.User})
+	}
+	if ip != "" && cfg.IP.Enabled() {
+		charges = append(charges, charge{ScopeIP, bucketFor(l.ips, ip), cfg.IP})
+	}
+
+	for i, c := range charges {
+		if ok, wait := c.bucket.Allow(c.limit, now); !ok {
+			for _, taken := range charges[:i] {
+				taken.bucket.refund(taken.limit)
+			}
+			return c.scope, wait, false
+		}
+	}
+	return "", 0, true
+}
+
+// bucketFor 读锁快速路径取桶，不存在时才加写锁创建
+func bucketFor[K comparable](m cmap.ConcurrentMap[K, *Bucket], key K) *Bucket {
+	if b, ok := m.Get(key); ok {
+		return b
+	}
+	return m.Upsert(key, nil, keepBucket)
+}
+
+func keepBucket(exist bool, cur, _ *Bucket) *Bucket {
+	if exist {
+		return cur
+	}
+	return &Bucket{}
+}
+
+// violate 记录一次超限，返回是否达到封禁阈值
+func (l *Limiter) violate(cfg *Config, st *SessionState, now int64) bool {
+	if cfg.BanViolations <= 0 {
+		return false
+	}
+	start := st.windowStart.Load()
+	if now-start > int64(cfg.BanWindow) && st.windowStart.CompareAndSwap(start, now) {
+		st.violations.Store(0)
+	}
+	return int(st.violations.Add(1)) >= cfg.BanViolations
+}
+
+func (l *Limiter) ban(cfg *Config, userID int32, ip string, now int64) {
+	if cfg.BanDuration <= 0 {
+		return
+	}
+	until := now + int64(cfg.BanDuration)
+	if userID != 0 {
+		l.bans.Set(userKey(userID), until)
+	}
+	if ip != "" {
+		l.bans.Set("ip:"+ip, until)
+	}
+	// 先写入再置位，与 sweep 的复查配合保证不漏
+	l.hasBans.Store(true)
+}
+
+// Banned 检查 IP 是否处于封禁期（建立连接前调用），返回剩余时间
+func (l *Limiter) Banned(ip string) (bool, time.Duration) {
+	now := time.Now().UnixNano()
+	until, banned := l.bannedUntil(0, ip, now)
+	return banned, time.Duration(until - now)
+}
+
+func (l *Limiter) bannedUntil(userID int32, ip string, now int64) (int64, bool) {
+	if !l.hasBans.Load() {
+		return 0, false
+	}
+	if userID != 0 {
+		if until, ok := l.bans.Get(userKey(userID)); ok && until > now {
+			return until, true
+		}
+	}
+	if ip != "" {
+		if until, ok := l.bans.Get("ip:" + ip); ok && until > now {
+			return until, true
+		}
+	}
+	return 0, false
+}
+
+func userKey(userID int32) string {
+	return "user:" + strconv.FormatInt(int64(userID), 10)
+}
+
+// StartJanitor 定期清理已补满的用户 / IP 令牌桶和过期的封禁
+func (l *Limiter) StartJanitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				l.sweep(time.Now().UnixNano())
+			case <-l.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (l *Limiter) sweep(now int64) {
+	for _, id := range l.users.Keys() {
+		l.users.RemoveCb(id, func(_ int32, b *Bucket, exists bool) bool {
+			return exists && b.idle(now)
+		})
+	}
+	for _, ip := range l.ips.Keys() {
+		l.ips.RemoveCb(ip, func(_ string, b *Bucket, exists bool) bool {
+			return exists && b.idle(now)
+		})
+	}
+	for _, key := range l.bans.Keys() {
+		l.bans.RemoveCb(key, func(_ string, until int64, exists bool) bool {
+			return exists && until <= now
+		})
+	}
+	if l.hasBans.Load() && l.bans.IsEmpty() {
+		l.hasBans.Store(false)
+		// 与 ban 并发时重新检查，避免漏掉刚写入的封禁
+		if !l.bans.IsEmpty() {
+			l.hasBans.Store(true)
+		}
+	}
+}
+
+// Stop 停止清理 goroutine
+func (l *Limiter) Stop() {
+	close(l.stop)
+}
@@ -0,0 +1,260 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"game-gateway/internal/metrics"
+	"game-gateway/internal/session"
+	"game-gateway/pkg/protocol"
+
+	"game-protocols/chat"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Inbound 一个上行包的路由上下文
+type Inbound struct {
+	Ctx     context.Context // 携带追踪 Span（见 Tracing）
+	Session *session.Session
+	Packet  *protocol.Packet
+	GameID  string            // 目标游戏：RouteChat 取请求中的 game_id，RouteGame 取会话的 GameID
+	Chat    *chat.ChatRequest // RouteChat 时为已解析的请求
+}
+
+// Outbound 一条下行消息的投递上下文
+type Outbound struct {
+	Ctx       context.Context // 携带后端传回的追踪上下文（见 OutboundTracing）
+	Route     protocol.RouteType
+	Priority  session.Priority
+	Sequence  uint32 // 非 0 表示对请求的响应，只投递到 SessionID 对应的会话并保留该序列号
+	UserID    int32
+	SessionID string
+	GameID    string // 来源已知时填写（如游戏逻辑服 Envelope），否则为空
+	Payload   []byte
+}
+
+// InboundHandler 处理上行包
+type InboundHandler func(in *Inbound) error
+
+// OutboundHandler 投递下行消息
+type OutboundHandler func(out *Outbound) error
+
+// InboundMiddleware 包装上行处理：可修改上下文、拒绝（返回错误）或在 next 前后执行逻辑
+type InboundMiddleware func(next InboundHandler) InboundHandler
+
+// OutboundMiddleware 包装下行投递
+type OutboundMiddleware func(next OutboundHandler) OutboundHandler
+
+// Scope 中间件生效范围，字段为空表示不限制
+type Scope struct {
+	Routes []protocol.RouteType
+	Games  []string
+}
+
+// ScopeOption 限定中间件生效范围
+type ScopeOption func(*Scope)
+
+// ForRoutes 只对指定路由生效
+func ForRoutes(routes ...protocol.RouteType) ScopeOption {
+	return func(s *Scope) {
+		s.Routes = append(s.Routes, routes...)
+	}
+}
+
+// ForGames 只对指定游戏生效（GameID 未知的消息不经过该中间件）
+func ForGames(gameIDs ...string) ScopeOption {
+	return func(s *Scope) {
+		s.Games = append(s.Games, gameIDs...)
+	}
+}
+
+func (s Scope) all() bool {
+	return len(s.Routes) == 0 && len(s.Games) == 0
+}
+
+func (s Scope) match(route protocol.RouteType, gameID string) bool {
+	if len(s.Routes) > 0 && !slices.Contains(s.Routes, route) {
+		return false
+	}
+	if len(s.Games) > 0 && !slices.Contains(s.Games, gameID) {
+		return false
+	}
+	return true
+}
+
+type inboundEntry struct {
+	mw    InboundMiddleware
+	scope Scope
+}
+
+type outboundEntry struct {
+	mw    OutboundMiddleware
+	scope Scope
+}
+
+// UseInbound 注册上行中间件（包裹 RoutePacket），先注册的在外层；需在启动前调用
+func (r *Router) UseInbound(mw InboundMiddleware, opts ...ScopeOption) {
+	var scope Scope
+	for _, opt := range opts {
+		opt(&scope)
+	}
+	r.inboundMWs = append(r.inboundMWs, inboundEntry{mw: mw, scope: scope})
+
+	h := InboundHandler(r.dispatchInbound)
+	for i := len(r.inboundMWs) - 1; i >= 0; i-- {
+		h = r.inboundMWs[i].wrap(h)
+	}
+	r.inbound = h
+}
+
+// UseOutbound 注册下行中间件（包裹投递到客户端），先注册的在外层；需在启动前调用
+func (r *Router) UseOutbound(mw OutboundMiddleware, opts ...ScopeOption) {
+	var scope Scope
+	for _, opt := range opts {
+		opt(&scope)
+	}
+	r.outboundMWs = append(r.outboundMWs, outboundEntry{mw: mw, scope: scope})
+
+	h := OutboundHandler(r.dispatchOutbound)
+	for i := len(r.outboundMWs) - 1; i >= 0; i-- {
+		h = r.outboundMWs[i].wrap(h)
+	}
+	r.outbound = h
+}
+
+func (e inboundEntry) wrap(next InboundHandler) InboundHandler {
+	wrapped := e.mw(next)
+	if e.scope.all() {
+		return wrapped
+	}
+	return func(in *Inbound) error {
+		if e.scope.match(in.Packet.Route, in.GameID) {
+			return wrapped(in)
+		}
+		return next(in)
+	}
+}
+
+func (e outboundEntry) wrap(next OutboundHandler) OutboundHandler {
+	wrapped := e.mw(next)
+	if e.scope.all() {
+		return wrapped
+	}
+	return func(out *Outbound) error {
+		if e.scope.match(out.Route, out.GameID) {
+			return wrapped(out)
+		}
+		return next(out)
+	}
+}
+
+// slowMessageThreshold 上行处理超过该时长计为慢消息
+const slowMessageThreshold = 100 * time.Millisecond
+
+// Metrics 统计上行包的路由成功 / 失败数、耗时及慢消息数（按游戏和路由）
+func Metrics() InboundMiddleware {
+	return func(next InboundHandler) InboundHandler {
+		return func(in *Inbound) error {
+			start := time.Now()
+			err := next(in)
+			elapsed := time.Since(start)
+			if err != nil {
+				metrics.GlobalMetrics.IncrementRoutingErrors()
+			} else {
+				metrics.GlobalMetrics.IncrementMessagesRouted()
+			}
+			if elapsed > slowMessageThreshold {
+				metrics.GlobalMetrics.IncrementSlowMessages()
+			}
+			metrics.GlobalMetrics.ObserveInbound(in.GameID, in.Packet.Route.String(), elapsed, err)
+			return err
+		}
+	}
+}
+
+// OutboundMetrics 按游戏和路由统计投递到客户端的下行消息
+func OutboundMetrics() OutboundMiddleware {
+	return func(next OutboundHandler) OutboundHandler {
+		return func(out *Outbound) error {
+			err := next(out)
+			if err == nil {
+				metrics.GlobalMetrics.ObserveOutbound(out.GameID, out.Route.String())
+			}
+			return err
+		}
+	}
+}
+
+// MaxPayload 拒绝 Payload 超过 limit 字节的上行包
+func MaxPayload(limit int) InboundMiddleware {
+	return MaxPayloadBy(func(string) int { return limit })
+}
+
+// MaxPayloadBy 按游戏限制上行包 Payload 大小，limit 每个包调用一次（支持热更新），返回 <= 0 不限制
+func MaxPayloadBy(limit func(gameID string) int) InboundMiddleware {
+	return func(next InboundHandler) InboundHandler {
+		return func(in *Inbound) error {
+			if max := limit(in.GameID); max > 0 {
+				if n := len(in.Packet.Payload); n > max {
+					return fmt.Errorf("payload too large: %d > %d bytes", n, max)
+				}
+			}
+			return next(in)
+		}
+	}
+}
+
+// Tracing 为每个上行包创建 gateway.receive 根 Span（客户端携带的 trace_id 已在入口清除，见 parseInbound）
+func Tracing() InboundMiddleware {
+	return func(next InboundHandler) InboundHandler {
+		return func(in *Inbound) error {
+			ctx, span := tracer.Start(in.Ctx, "gateway.receive", trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("route", in.Packet.Route.String()),
+					attribute.String("game.id", in.GameID),
+					attribute.String("session.id", in.Session.ID),
+					attribute.Int("payload.size", len(in.Packet.Payload)),
+				))
+			in.Ctx = ctx
+			err := next(in)
+			endSpan(span, err)
+			return err
+		}
+	}
+}
+
+// OutboundTracing 为携带追踪上下文的下行消息创建 gateway.deliver Span（未携带的不追踪）
+func OutboundTracing() OutboundMiddleware {
+	return func(next OutboundHandler) OutboundHandler {
+		return func(out *Outbound) error {
+			if !trace.SpanContextFromContext(out.Ctx).IsValid() {
+				return next(out)
+			}
+			ctx, span := tracer.Start(out.Ctx, "gateway.deliver", trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(
+					attribute.String("route", out.Route.String()),
+					attribute.String("game.id", out.GameID),
+					attribute.Int("user.id", int(out.UserID)),
+					attribute.String("session.id", out.SessionID),
+				))
+			out.Ctx = ctx
+			err := next(out)
+			endSpan(span, err)
+			return err
+		}
+	}
+}
+
+// endSpan 记录错误并结束 Span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -1,31 +1,75 @@
 package router
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 
 	"game-gateway/internal/logger"
+	"game-gateway/internal/metrics"
 	"game-gateway/internal/session"
 	"game-gateway/pkg/protocol"
+	"game-pkg/envelope"
 	"game-pkg/mq"
+	"game-pkg/registry"
+	"game-pkg/tracing"
 
 	"game-protocols/chat"
+	"game-protocols/common"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/protobuf/proto"
 )
 
+var tracer = tracing.Tracer("game-gateway/router")
+
 type SessionManager interface {
 	Get(id string) *session.Session
-	GetByUserID(userID int32) *session.Session
-	Bind(userID int32, sessionID string)
+	GetByUserID(userID int32) []*session.Session
+	Bind(userID int32, sessionID, deviceID, gameID string) error
+	GetReplays(userID int32) []*session.ReplayBuffer
+}
+
+// Sender 直连后端的发送接口（如 backend.Pool），消息为编码后的 envelope.Envelope
+type Sender interface {
+	Send(data []byte) error
 }
 
 type Router struct {
 	sessionManager SessionManager
 	mqProducer     mq.Producer
+	rpcClient      *mq.RPCClient
+
+	// games 已注册的游戏，未注册 GameID 的请求被拒绝；gameReplyTopic 本实例接收 RouteGame 响应的 Topic
+	games          *registry.Registry
+	gameReplyTopic string
+
+	// originAllowed 检查未声明游戏的连接能否以其 Origin 访问聊天请求的游戏（见 SetOriginCheck）
+	originAllowed func(gameID, origin string) bool
+
+	// payloadLimit 解析前按连接的游戏检查上行包大小（见 SetPayloadLimit），为空不检查
+	payloadLimit func(gameID string) int
+
+	// chatBackends GameID -> Chat Service 直连连接池，未配置的游戏经 MQ 转发
+	chatBackends map[string]Sender
+
+	// 中间件链：inbound 包裹上行路由，outbound 包裹下行投递（见 UseInbound / UseOutbound）
+	inboundMWs  []inboundEntry
+	outboundMWs []outboundEntry
+	inbound     InboundHandler
+	outbound    OutboundHandler
 }
 
 func NewRouter() *Router {
-	return &Router{}
+	r := &Router{
+		games:        registry.New(),
+		chatBackends: make(map[string]Sender),
+	}
+	r.inbound = r.dispatchInbound
+	r.outbound = r.dispatchOutbound
+	return r
 }
 
 func (r *Router) SetSessionManager(sm SessionManager) {
@@ -36,42 +80,181 @@ func (r *Router) SetMQ(producer mq.Producer) {
 	r.mqProducer = producer
 }
 
-// RoutePacket 使用二进制协议路由数据包
+// SetRPC 注入 MQ RPC 客户端，用于需要同步等待结果的操作（如鉴权）
+func (r *Router) SetRPC(client *mq.RPCClient) {
+	r.rpcClient = client
+}
+
+// SetRegistry 设置游戏注册表，需在启动前调用；注册表可在运行时增删游戏
+func (r *Router) SetRegistry(games *registry.Registry) {
+	r.games = games
+}
+
+// SetOriginCheck 设置 Origin 校验（通常为 admission.Controller.OriginAllowed），需在启动前调用
+func (r *Router) SetOriginCheck(fn func(gameID, origin string) bool) {
+	r.originAllowed = fn
+}
+
+// SetPayloadLimit 设置解析前的上行包大小上限（支持热更新），返回 <= 0 不限制；需在启动前调用
+// 按连接声明的游戏检查，未声明游戏的连接以 gameID "" 调用（应返回各游戏中最宽松的上限），
+// 超大的包在 proto 解析之前即被拒绝；按请求中 game_id 的精确检查见 MaxPayloadBy
+func (r *Router) SetPayloadLimit(limit func(gameID string) int) {
+	r.payloadLimit = limit
+}
+
+// SetChatBackend 设置某个游戏的 Chat Service 直连传输（替代 MQ），需在启动前调用
+func (r *Router) SetChatBackend(gameID string, backend Sender) {
+	r.chatBackends[gameID] = backend
+}
+
+// SetGameReplyTopic 设置本实例接收游戏逻辑服响应的 Topic（写入请求 Envelope 的 ReplyTo）
+func (r *Router) SetGameReplyTopic(topic string) {
+	r.gameReplyTopic = topic
+}
+
+// Authenticate 通过 Chat Service 校验 Token（连接时携带 /ws?token= 的会话，见 server.handleConnection）
+func (r *Router) Authenticate(ctx context.Context, token string) (*chat.UserIdentity, error) {
+	if r.rpcClient == nil {
+		return nil, fmt.Errorf("MQ rpc client not initialized")
+	}
+
+	payload, err := proto.Marshal(&chat.AuthTokenRequest{Token: token})
+	if err != nil {
+		return nil, fmt.Errorf("marshal AuthTokenRequest: %w", err)
+	}
+
+	reply, err := r.rpcClient.Call(ctx, mq.TopicAuthRPC, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var identity chat.UserIdentity
+	if err := proto.Unmarshal(reply, &identity); err != nil {
+		return nil, fmt.Errorf("unmarshal UserIdentity: %w", err)
+	}
+	return &identity, nil
+}
+
+// RoutePacket 使用二进制协议路由数据包：解析路由上下文后经过上行中间件链
+// 解析失败的包不会进入中间件链，在此计入路由错误（游戏按连接声明的游戏统计）
 func (r *Router) RoutePacket(s *session.Session, pkt *protocol.Packet) error {
+	in, err := r.parseInbound(s, pkt)
+	if err != nil {
+		metrics.GlobalMetrics.IncrementRoutingErrors()
+		metrics.GlobalMetrics.ObserveInbound(s.GameID, pkt.Route.String(), 0, err)
+		return err
+	}
+	return r.inbound(in)
+}
+
+// parseInbound 解析上行包的路由上下文（聊天请求解析 ChatRequest 并校验 GameID）
+func (r *Router) parseInbound(s *session.Session, pkt *protocol.Packet) (*Inbound, error) {
+	if r.payloadLimit != nil {
+		if max := r.payloadLimit(s.GameID); max > 0 && len(pkt.Payload) > max {
+			return nil, fmt.Errorf("payload too large: %d > %d bytes", len(pkt.Payload), max)
+		}
+	}
+
+	in := &Inbound{Ctx: context.Background(), Session: s, Packet: pkt}
 	switch pkt.Route {
 	case protocol.RouteChat:
-		return r.routeChatPacket(s, pkt)
+		// 解析为 ChatRequest 以获取 GameID
+		var req chat.ChatRequest
+		if err := proto.Unmarshal(pkt.Payload, &req); err != nil {
+			return nil, fmt.Errorf("unmarshal ChatRequest: %w", err)
+		}
+		if req.Base == nil {
+			return nil, fmt.Errorf("missing base info")
+		}
+		if req.Base.GameId == "" {
+			return nil, fmt.Errorf("missing game_id")
+		}
+		if !r.games.Has(req.Base.GameId) {
+			return nil, fmt.Errorf("unknown game_id: %s", req.Base.GameId)
+		}
+		// 连接只能访问准入时声明（或首次聊天绑定）的游戏，Origin 白名单按该游戏校验
+		if s.GameID != "" && req.Base.GameId != s.GameID {
+			return nil, fmt.Errorf("game_id %s does not match connection game %s", req.Base.GameId, s.GameID)
+		}
+		if s.GameID == "" && r.originAllowed != nil && !r.originAllowed(req.Base.GameId, s.Origin) {
+			return nil, fmt.Errorf("origin %q not allowed for game %s", s.Origin, req.Base.GameId)
+		}
+		// 追踪上下文只由 Gateway 生成：清除客户端伪造的 trace_id / span_id（否则可指定父 Span、污染链路）
+		if meta := req.GetMeta(); meta != nil && (meta.TraceId != "" || meta.SpanId != "") {
+			meta.TraceId, meta.SpanId = "", ""
+			data, err := proto.Marshal(&req)
+			if err != nil {
+				return nil, fmt.Errorf("marshal ChatRequest: %w", err)
+			}
+			pkt.Payload = data
+		}
+		in.Chat = &req
+		in.GameID = req.Base.GameId
+	case protocol.RouteGame, protocol.RouteSystem:
+		in.GameID = s.GameID
+	default:
+		return nil, fmt.Errorf("unknown route: %d", pkt.Route)
+	}
+	return in, nil
+}
+
+// dispatchInbound 上行中间件链末端，按路由转发
+func (r *Router) dispatchInbound(in *Inbound) error {
+	switch in.Packet.Route {
+	case protocol.RouteChat:
+		return r.routeChatPacket(in.Ctx, in.Session, in.Packet, in.Chat)
 	case protocol.RouteGame:
-		return fmt.Errorf("game route not implemented")
-	case protocol.RouteSystem:
-		return nil // Heartbeat etc.
+		return r.routeGamePacket(in.Ctx, in.Session, in.Packet)
 	default:
-		return fmt.Errorf("unknown route: %d", pkt.Route)
+		return nil // Heartbeat etc.
 	}
 }
 
 // routeChatPacket 处理聊天路由
-func (r *Router) routeChatPacket(s *session.Session, pkt *protocol.Packet) error {
-	// 解析为 ChatRequest 以获取 GameID
-	var req chat.ChatRequest
-	if err := proto.Unmarshal(pkt.Payload, &req); err != nil {
-		return fmt.Errorf("unmarshal ChatRequest: %w", err)
+func (r *Router) routeChatPacket(ctx context.Context, s *session.Session, pkt *protocol.Packet, req *chat.ChatRequest) (err error) {
+	gameID := req.Base.GameId
+
+	// 以 Token 鉴权的会话只能以 Token 对应的用户发送
+	if s.AuthToken != "" && s.UserID != 0 && req.Base.UserId != s.UserID {
+		return fmt.Errorf("user_id %d does not match authenticated user %d", req.Base.UserId, s.UserID)
 	}
 
-	if req.Base == nil {
-		return fmt.Errorf("missing base info")
+	// 自动绑定 UserID（如果还没绑定），按设备区分同一用户的多个会话
+	if s.UserID == 0 && req.Base.UserId > 0 {
+		logger.Debug(logger.TagSession, "Binding Session %s to UserID %d", s.ID, req.Base.UserId)
+		if err := r.sessionManager.Bind(req.Base.UserId, s.ID, req.GetMeta().GetDeviceId(), gameID); err != nil {
+			return fmt.Errorf("bind user %d: %w", req.Base.UserId, err)
+		}
 	}
 
-	gameID := req.Base.GameId
-	if gameID == "" {
-		return fmt.Errorf("missing game_id")
+	backend, direct := r.chatBackends[gameID]
+	ctx, span := tracer.Start(ctx, "chat.forward", trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("game.id", gameID), attribute.Bool("direct", direct)))
+	defer func() { endSpan(span, err) }()
+
+	// 追踪上下文写入 ChatRequest.meta，Chat Service 以此为父 Span
+	payload := pkt.Payload
+	if traceID, spanID, ok := tracing.IDs(ctx); ok {
+		if req.Meta == nil {
+			req.Meta = &common.MessageMeta{}
+		}
+		req.Meta.TraceId, req.Meta.SpanId = traceID, spanID
+		if data, err := proto.Marshal(req); err == nil {
+			payload = data
+		}
 	}
 
-	// 自动绑定 UserID（如果还没绑定）
-	if s.UserID == 0 && req.Base.UserId > 0 {
-		logger.Debug(logger.TagSession, "Binding Session %s to UserID %d", s.ID, req.Base.UserId)
-		r.sessionManager.Bind(req.Base.UserId, s.ID)
-		s.UserID = req.Base.UserId
+	// 直连模式：封装会话上下文，响应沿原连接返回并保留 Sequence
+	if direct {
+		env := &envelope.Envelope{
+			Route:     uint8(protocol.RouteChat),
+			Sequence:  pkt.Sequence,
+			UserID:    req.Base.UserId,
+			SessionID: s.ID,
+			GameID:    gameID,
+			Payload:   payload,
+		}
+		return backend.Send(env.Encode())
 	}
 
 	// 通过 MQ 发布请求
@@ -79,9 +262,101 @@ func (r *Router) routeChatPacket(s *session.Session, pkt *protocol.Packet) error
 		return fmt.Errorf("MQ producer not initialized")
 	}
 
-	topic := fmt.Sprintf("game:request:%s", gameID)
-	logger.Debug(logger.TagMQ, "Publishing request to MQ | Topic: %s", topic)
-	return r.mqProducer.Publish(topic, pkt.Payload)
+	topic := mq.RequestTopic(gameID)
+	logger.DebugCtx(ctx, logger.TagMQ, "Publishing chat request",
+		logger.SessionID(s.ID), logger.UserID(s.UserID), logger.GameID(gameID), logger.Seq(pkt.Sequence), "topic", topic)
+	return r.mqProducer.Publish(topic, payload)
+}
+
+// routeGamePacket 将 RouteGame 包封装为 Envelope 转发到会话所属游戏的逻辑服
+// GameID 来自连接参数 /ws?game={GameID} 或聊天请求绑定时的 GameID
+func (r *Router) routeGamePacket(ctx context.Context, s *session.Session, pkt *protocol.Packet) (err error) {
+	gameID := s.GameID
+	if gameID == "" {
+		return fmt.Errorf("missing game_id (connect with ?game=)")
+	}
+	if !r.games.Has(gameID) {
+		return fmt.Errorf("unknown game_id: %s", gameID)
+	}
+	if r.mqProducer == nil {
+		return fmt.Errorf("MQ producer not initialized")
+	}
+
+	topic := mq.GameLogicTopic(gameID)
+	ctx, span := tracer.Start(ctx, "game.forward", trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("game.id", gameID), attribute.String("mq.topic", topic)))
+	defer func() { endSpan(span, err) }()
+
+	env := &envelope.Envelope{
+		Route:       uint8(protocol.RouteGame),
+		Sequence:    pkt.Sequence,
+		UserID:      s.UserID,
+		SessionID:   s.ID,
+		GameID:      gameID,
+		ReplyTo:     r.gameReplyTopic,
+		TraceParent: tracing.TraceParent(ctx),
+		Payload:     pkt.Payload,
+	}
+	logger.DebugCtx(ctx, logger.TagMQ, "Forwarding game packet",
+		logger.SessionID(s.ID), logger.UserID(s.UserID), logger.GameID(gameID), logger.Seq(pkt.Sequence), "topic", topic)
+	return r.mqProducer.Publish(topic, env.Encode())
+}
+
+// HandleEnvelope 处理后端以 Envelope 返回的消息：游戏逻辑服的响应（ReplyTo Topic）与推送（mq.GamePushTopic），
+// 以及 Chat Service 直连连接上的响应与推送
+// 响应（Sequence 非 0）按 SessionID 投递并保留 Sequence；推送按 SessionID 或 UserID 投递到所有设备
+func (r *Router) HandleEnvelope(data []byte) {
+	env, err := envelope.Decode(data)
+	if err != nil {
+		logger.Warn(logger.TagBackend, "Invalid envelope | Size: %d, Error: %v", len(data), err)
+		return
+	}
+	if r.sessionManager == nil {
+		return
+	}
+	route := protocol.RouteType(env.Route)
+	ctx := tracing.ContextWithTraceParent(context.Background(), env.TraceParent)
+
+	if env.Sequence != 0 {
+		err := r.outbound(&Outbound{
+			Ctx:       ctx,
+			Route:     route,
+			Priority:  session.PriorityHigh,
+			Sequence:  env.Sequence,
+			UserID:    env.UserID,
+			SessionID: env.SessionID,
+			GameID:    env.GameID,
+			Payload:   env.Payload,
+		})
+		if err != nil {
+			logger.Debug(logger.TagRouter, "Failed to route response | Route: %d, Session: %s, Seq: %d, Error: %v", route, env.SessionID, env.Sequence, err)
+		}
+		return
+	}
+
+	// 未指定会话的聊天推送与 MQ 广播格式相同（MessageBroadcast），按其中的 TargetUserId 投递
+	if route == protocol.RouteChat && env.SessionID == "" {
+		r.handleBroadcast(ctx, env.Payload)
+		return
+	}
+
+	prio := session.PriorityNormal
+	if route == protocol.RouteChat {
+		prio = session.PriorityHigh // 未带 Sequence 的请求的 ACK
+	}
+	// 推送发往所有 Gateway 实例，用户不在本实例是正常情况
+	err = r.outbound(&Outbound{
+		Ctx:       ctx,
+		Route:     route,
+		Priority:  prio,
+		UserID:    env.UserID,
+		SessionID: env.SessionID,
+		GameID:    env.GameID,
+		Payload:   env.Payload,
+	})
+	if err != nil {
+		logger.Debug(logger.TagRouter, "Push not delivered | Route: %d, To: %d, Session: %s, Error: %v", route, env.UserID, env.SessionID, err)
+	}
 }
 
 func (r *Router) HandleBackendMessage(data []byte) {
@@ -94,7 +369,16 @@ func (r *Router) HandleBackendMessage(data []byte) {
 		logger.Debug(logger.TagRouter, "ChatResponse parsed | To: %d, Session: %s, Success: %v",
 			resp.TargetUserId, resp.TargetSessionId, resp.Success)
 
-		if err := r.routeToClient(protocol.RouteChat, resp.TargetUserId, resp.TargetSessionId, data); err != nil {
+		err := r.outbound(&Outbound{
+			Ctx:       context.Background(),
+			Route:     protocol.RouteChat,
+			Priority:  session.PriorityHigh,
+			UserID:    resp.TargetUserId,
+			SessionID: resp.TargetSessionId,
+			GameID:    resp.GetBase().GetGameId(),
+			Payload:   data,
+		})
+		if err != nil {
 			logger.Warn(logger.TagRouter, "Failed to route ChatResponse | To: %d, Error: %v", resp.TargetUserId, err)
 		} else {
 			logger.Debug(logger.TagRouter, "ChatResponse routed successfully | To: %d", resp.TargetUserId)
@@ -108,7 +392,16 @@ func (r *Router) HandleBackendMessage(data []byte) {
 func (r *Router) HandleBroadcast(data []byte) {
 	// 这是一个来自 MQ 的广播消息
 	// 目前我们约定 MQ 中的 "broadcast" topic 只传输 chat.MessageBroadcast
+	// 携带追踪上下文时封装为 Envelope（首字节为版本号，不会与 Protobuf 冲突）
+	ctx := context.Background()
+	if env, err := envelope.Decode(data); err == nil {
+		ctx = tracing.ContextWithTraceParent(ctx, env.TraceParent)
+		data = env.Payload
+	}
+	r.handleBroadcast(ctx, data)
+}
 
+func (r *Router) handleBroadcast(ctx context.Context, data []byte) {
 	var broadcast chat.MessageBroadcast
 	if err := proto.Unmarshal(data, &broadcast); err != nil {
 		logger.Error(logger.TagMQ, "Failed to parse broadcast | Error: %v", err)
@@ -123,12 +416,25 @@ func (r *Router) HandleBroadcast(data []byte) {
 	logger.Debug(logger.TagMQ, "Received broadcast | To: %d, From: %d, Size: %d",
 		broadcast.TargetUserId, broadcast.SenderId, len(data))
 
-	if err := r.routeToClient(protocol.RouteChat, broadcast.TargetUserId, "", data); err != nil {
+	// ACK (ChatResponse) 和私聊为高优先级，频道聊天为普通优先级
+	prio := session.PriorityNormal
+	if protocol.IsChatResponse(data) || broadcast.ChannelId == 0 {
+		prio = session.PriorityHigh
+	}
+
+	err := r.outbound(&Outbound{
+		Ctx:      ctx,
+		Route:    protocol.RouteChat,
+		Priority: prio,
+		UserID:   broadcast.TargetUserId,
+		Payload:  data,
+	})
+	if err != nil {
 		// target not found 错误在 Gateway 是正常的（如果用户没连这个 Gateway）
 		// 但如果是 buffer full 则是问题
 		// 降低 "target not found" 的日志级别或忽略，只记录其他错误
 		// 这里还是先记录，方便调试
-		if err.Error()[:16] != "target not found" {
+		if !strings.HasPrefix(err.Error(), "target not found") {
 			logger.Debug(logger.TagMQ, "Failed to route broadcast (target not found) | To: %d", broadcast.TargetUserId)
 		}
 	} else {
@@ -143,46 +449,94 @@ func min(a, b int) int {
 	return b
 }
 
-func (r *Router) routeToClient(route protocol.RouteType, userID int32, sessionID string, payload []byte) error {
-	var sess *session.Session
-
+// dispatchOutbound 下行中间件链末端，投递到目标会话
+func (r *Router) dispatchOutbound(out *Outbound) error {
 	if r.sessionManager == nil {
 		return fmt.Errorf("session manager not set")
 	}
+	if out.Sequence != 0 {
+		sess := r.sessionManager.Get(out.SessionID)
+		if sess == nil {
+			return fmt.Errorf("target not found (Session: %s)", out.SessionID)
+		}
+		return r.replyToSession(sess, out.Route, out.Sequence, out.UserID, out.Payload)
+	}
+	return r.routeToClient(out.Route, out.Priority, out.UserID, out.SessionID, out.Payload)
+}
+
+func (r *Router) routeToClient(route protocol.RouteType, prio session.Priority, userID int32, sessionID string, payload []byte) error {
 
 	// 优先使用 SessionID 路由
 	if sessionID != "" {
-		sess = r.sessionManager.Get(sessionID)
+		if sess := r.sessionManager.Get(sessionID); sess != nil {
+			return r.sendToSession(sess, route, prio, userID, payload)
+		}
 	}
-	// 否则使用 UserID
-	if sess == nil && userID > 0 {
-		sess = r.sessionManager.GetByUserID(userID)
+	if userID <= 0 {
+		return fmt.Errorf("target not found (User: %d, Session: %s)", userID, sessionID)
 	}
 
-	if sess == nil {
+	// 否则投递到该用户所有设备的在线会话；
+	// 刚断线、仍在续传窗口内的设备记录到续传缓冲，重连后补发
+	sessions := r.sessionManager.GetByUserID(userID)
+	replays := r.sessionManager.GetReplays(userID)
+	if len(sessions) == 0 && len(replays) == 0 {
 		return fmt.Errorf("target not found (User: %d, Session: %s)", userID, sessionID)
 	}
+	for _, replay := range replays {
+		replay.Send(prio, route, payload)
+	}
 
-	// 构建二进制协议包
-	pkt := protocol.NewPacket(route, payload)
-
-	// 编码并发送
-	encoded := pkt.Encode()
+	var errs []error
+	for _, sess := range sessions {
+		if err := r.sendToSession(sess, route, prio, userID, payload); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
 
-	select {
-	case sess.Send <- encoded:
-		return nil
-	default:
-		// 详细的丢弃日志
-		bufferUsage := len(sess.Send)
-		bufferCap := cap(sess.Send)
-		usagePercent := bufferUsage * 100 / bufferCap
+// sendToSession 投递到单个会话，并按入队结果记录慢消费者指标
+func (r *Router) sendToSession(sess *session.Session, route protocol.RouteType, prio session.Priority, userID int32, payload []byte) error {
+	return r.pushResult(sess.Send(prio, route, payload), sess, route, prio, userID, len(payload))
+}
 
-		logger.Error(logger.TagRouter, "MESSAGE DROPPED - Session buffer full | "+
-			"UserID: %d, SessionID: %s, Route: %d, "+
-			"BufferUsage: %d/%d (%d%%), PayloadSize: %d bytes",
-			userID, sess.ID, route, bufferUsage, bufferCap, usagePercent, len(payload))
+// replyToSession 投递对请求的响应（保留客户端 Sequence），高优先级
+func (r *Router) replyToSession(sess *session.Session, route protocol.RouteType, seq uint32, userID int32, payload []byte) error {
+	return r.pushResult(sess.Reply(session.PriorityHigh, route, seq, payload), sess, route, session.PriorityHigh, userID, len(payload))
+}
 
-		return fmt.Errorf("session %s send buffer full (%d/%d)", sess.ID, bufferUsage, bufferCap)
+func (r *Router) pushResult(result session.PushResult, sess *session.Session, route protocol.RouteType, prio session.Priority, userID int32, size int) error {
+	switch result {
+	case session.PushQueued, session.PushBuffered:
+		return nil
+	case session.PushCoalesced:
+		metrics.GlobalMetrics.IncrementSendCoalesced()
+		return nil
+	case session.PushDroppedOldest:
+		metrics.GlobalMetrics.IncrementSendDroppedOldest()
+		logger.WarnCtx(context.Background(), logger.TagRouter, "Session queue full, oldest message dropped",
+			logger.SessionID(sess.ID), logger.UserID(userID), logger.GameID(sess.GameID), logger.Route(route),
+			"priority", prio.String(), "queue_len", sess.Queue.Len(), "queue_cap", sess.Queue.Cap())
+		return nil
+	case session.PushDisconnect:
+		metrics.GlobalMetrics.IncrementSendDroppedNewest()
+		metrics.GlobalMetrics.IncrementSlowConsumerDisconnects()
+		logger.ErrorCtx(context.Background(), logger.TagRouter, "Slow consumer disconnected after too many drops",
+			logger.SessionID(sess.ID), logger.UserID(userID), logger.GameID(sess.GameID), "priority", prio.String())
+		sess.Close()
+		return fmt.Errorf("session %s disconnected as slow consumer", sess.ID)
+	case session.PushClosed:
+		return fmt.Errorf("session %s closed", sess.ID)
+	default: // session.PushDroppedNewest
+		metrics.GlobalMetrics.IncrementSendDroppedNewest()
+		queueLen := sess.Queue.Len()
+		queueCap := sess.Queue.Cap()
+
+		logger.ErrorCtx(context.Background(), logger.TagRouter, "Message dropped, session send buffer full",
+			logger.SessionID(sess.ID), logger.UserID(userID), logger.GameID(sess.GameID), logger.Route(route),
+			"priority", prio.String(), "queue_len", queueLen, "queue_cap", queueCap, "payload_size", size)
+
+		return fmt.Errorf("session %s send buffer full (%d/%d)", sess.ID, queueLen, queueCap)
 	}
 }
@@ -1,37 +1,73 @@
 package server
 
 import (
-	"log"
+	"context"
+	"encoding/binary"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"game-gateway/internal/admission"
 	"game-gateway/internal/logger"
 	"game-gateway/internal/metrics"
+	"game-gateway/internal/ratelimit"
 	"game-gateway/internal/router"
 	"game-gateway/internal/session"
 	"game-gateway/pkg/protocol"
+	"game-pkg/health"
+	"game-pkg/registry"
+
+	"game-protocols/chat"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
+// authTimeout 连接时 Token 鉴权（MQ RPC）的最长等待时间
+const authTimeout = 3 * time.Second
+
 type Server struct {
 	addr     string
 	router   *router.Router
 	sessions *session.Manager
 	upgrader websocket.Upgrader
+
+	health *health.Registry
+
+	queueConfig session.QueueConfig
+
+	limiter  *ratelimit.Limiter
+	ipHeader string // 反向代理传递客户端 IP 的请求头（如 X-Real-IP），为空则使用 RemoteAddr
+	ipHops   int    // 向 ipHeader 追加地址的可信代理层数
+
+	admission *admission.Controller
+
+	games *registry.Registry // 为空不校验 /ws?game=
+
+	mux        *http.ServeMux // 客户端端口只暴露 /ws 及健康检查，/metrics 与 pprof 在内网调试端口
+	httpServer *http.Server
+	draining   atomic.Bool
 }
 
 func NewServer(addr string, r *router.Router, s *session.Manager) *Server {
+	mux := http.NewServeMux()
 	return &Server{
-		addr:     addr,
-		router:   r,
-		sessions: s,
+		addr:        addr,
+		router:      r,
+		sessions:    s,
+		health:      health.NewRegistry(),
+		queueConfig: session.DefaultQueueConfig(),
+		// 在启动前创建：Start 之前收到 SIGTERM 时 Shutdown 同样生效，Start 随即返回 http.ErrServerClosed
+		mux:        mux,
+		httpServer: &http.Server{Addr: addr, Handler: mux},
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  8192, // 增加到 8KB
 			WriteBufferSize: 8192, // 增加到 8KB
 			CheckOrigin: func(r *http.Request) bool {
-				return true // Allow all origins for dev
+				return true // Origin 由准入控制按游戏校验（见 SetAdmission）
 			},
 		},
 	}
@@ -41,15 +77,211 @@ func (s *Server) Start() error {
 	// 启动性能指标定期报告（每30秒）
 	metrics.GlobalMetrics.StartPeriodicReport(30 * time.Second)
 
-	http.HandleFunc("/ws", s.handleConnection)
+	s.mux.HandleFunc("/ws", s.handleConnection)
+	s.health.SetDraining(s.Draining)
+	s.mux.Handle("/healthz", s.health.LivenessHandler())
+	s.mux.Handle("/readyz", s.health.StatusHandler()) // 客户端端口只返回状态，详情见 ReadinessHandler
 	logger.Info(logger.TagSession, "Gateway listening on %s", s.addr)
-	return http.ListenAndServe(s.addr, nil)
+
+	return s.httpServer.ListenAndServe()
+}
+
+// Shutdown 优雅下线：
+// 1. 拒绝新的 /ws 升级
+// 2. 通知所有客户端重连到其他实例 (RouteSystem ReconnectNotice)
+// 3. 在 ctx 截止前等待各会话发送队列清空
+// 4. 关闭所有会话，等待会话注册全部移除后停止 HTTP 服务
+func (s *Server) Shutdown(ctx context.Context, retryAfter time.Duration) error {
+	s.draining.Store(true)
+
+	sessions := s.sessions.All()
+	logger.Info(logger.TagSession, "Draining gateway | Sessions: %d", len(sessions))
+
+	notice, err := protocol.EncodeSystemPayload(protocol.PayloadSystemReconnect, &protocol.ReconnectNotice{
+		Reason:       "server shutting down",
+		RetryAfterMs: retryAfter.Milliseconds(),
+	})
+	if err != nil {
+		return err
+	}
+	encoded := protocol.NewPacket(protocol.RouteSystem, notice).Encode()
+	for _, sess := range sessions {
+		sess.Queue.Push(session.PriorityHigh, encoded, "")
+	}
+
+	s.flushQueues(ctx, sessions)
+
+	for _, sess := range sessions {
+		sess.Close()
+	}
+	s.waitSessionsRemoved(ctx)
+
+	return s.httpServer.Shutdown(ctx)
+}
+
+// flushQueues 等待所有会话发送队列清空或 ctx 截止
+func (s *Server) flushQueues(ctx context.Context, sessions []*session.Session) {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		pending := 0
+		for _, sess := range sessions {
+			pending += sess.Queue.Len()
+		}
+		if pending == 0 {
+			logger.Info(logger.TagSession, "All send queues flushed")
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Warn(logger.TagSession, "Drain deadline reached | Unsent messages: %d", pending)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitSessionsRemoved 等待 readPump 清理完所有会话（含 UserID 绑定）或 ctx 截止
+func (s *Server) waitSessionsRemoved(ctx context.Context) {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for s.sessions.Count() > 0 {
+		select {
+		case <-ctx.Done():
+			logger.Warn(logger.TagSession, "Drain deadline reached | Remaining sessions: %d", s.sessions.Count())
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Draining 是否正在下线
+func (s *Server) Draining() bool {
+	return s.draining.Load()
+}
+
+// SetQueueConfig 设置新会话的发送队列配置
+func (s *Server) SetQueueConfig(cfg session.QueueConfig) {
+	s.queueConfig = cfg
+}
+
+// SetRateLimiter 启用上行限流
+func (s *Server) SetRateLimiter(l *ratelimit.Limiter) {
+	s.limiter = l
+}
+
+// SetAdmission 设置连接准入控制（升级前检查连接数、IP 名单、Origin 与建连速率）
+func (s *Server) SetAdmission(c *admission.Controller) {
+	s.admission = c
+}
+
+// SetRegistry 设置游戏注册表，/ws?game= 指定未注册的游戏时拒绝连接
+func (s *Server) SetRegistry(games *registry.Registry) {
+	s.games = games
+}
+
+// SetClientIPHeader 设置获取客户端 IP 的请求头（仅在网关位于可信代理之后时使用）
+// 代理向 X-Forwarded-For 追加而非替换地址，最左侧的地址由客户端任意填写；hops 为追加地址的可信代理层数，
+// 取从右数第 hops 个地址（单层代理为 1，即最右侧）
+func (s *Server) SetClientIPHeader(header string, hops int) {
+	s.ipHeader, s.ipHops = header, hops
+}
+
+// clientIP 返回请求的客户端 IP；请求头中的地址少于 ipHops 个（未经过全部可信代理）时使用连接地址
+func (s *Server) clientIP(r *http.Request) string {
+	if s.ipHeader != "" {
+		var addrs []string
+		for _, h := range r.Header.Values(s.ipHeader) {
+			addrs = append(addrs, strings.Split(h, ",")...)
+		}
+		if i := len(addrs) - max(s.ipHops, 1); i >= 0 {
+			if ip := strings.TrimSpace(addrs[i]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ReadinessHandler 完整的就绪报告（各依赖的错误及详情），挂载在内网 metrics 端口
+func (s *Server) ReadinessHandler() http.Handler {
+	return s.health.ReadinessHandler()
+}
+
+// AddHealthCheck 注册 /readyz 检查项，返回 error 表示未就绪
+func (s *Server) AddHealthCheck(name string, check health.Check) {
+	s.health.Add(name, check)
 }
 
 func (s *Server) handleConnection(w http.ResponseWriter, r *http.Request) {
+	if s.draining.Load() {
+		http.Error(w, "gateway is draining", http.StatusServiceUnavailable)
+		return
+	}
+
+	if game := r.URL.Query().Get("game"); game != "" && s.games != nil && !s.games.Has(game) {
+		logger.Debug(logger.TagSession, "Connection rejected | Remote: %s, Reason: unknown game %q", r.RemoteAddr, game)
+		http.Error(w, "unknown game", http.StatusNotFound)
+		return
+	}
+
+	ip := s.clientIP(r)
+	if s.limiter != nil {
+		if banned, remaining := s.limiter.Banned(ip); banned {
+			w.Header().Set("Retry-After", strconv.Itoa(int(remaining.Seconds())+1))
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	release := func() {}
+	if s.admission != nil {
+		var rej *admission.Rejection
+		if release, rej = s.admission.Admit(r, ip); rej != nil {
+			metrics.GlobalMetrics.IncrementAdmissionRejected()
+			logger.Debug(logger.TagSession, "Connection rejected | IP: %s, Status: %d, Reason: %s", ip, rej.Status, rej.Reason)
+			rej.WriteResponse(w)
+			return
+		}
+	}
+
+	query := r.URL.Query()
+	// 携带登录 Token 时经 Chat Service（MQ RPC）鉴权，会话直接绑定 Token 对应的用户；
+	// 在准入检查之后执行，被拒绝的连接不会产生 RPC
+	var identity *chat.UserIdentity
+	if token := query.Get("token"); token != "" {
+		ctx, cancel := context.WithTimeout(r.Context(), authTimeout)
+		id, err := s.router.Authenticate(ctx, token)
+		cancel()
+		switch {
+		case err != nil:
+			logger.Warn(logger.TagSession, "Authenticate failed | Remote: %s, Error: %v", r.RemoteAddr, err)
+			http.Error(w, "authentication unavailable", http.StatusServiceUnavailable)
+			release()
+			return
+		case !id.Valid || id.UserId <= 0:
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			release()
+			return
+		case id.GameId != "" && query.Get("game") != "" && id.GameId != query.Get("game"):
+			http.Error(w, "token not valid for game", http.StatusForbidden)
+			release()
+			return
+		}
+		identity = id
+	}
+
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Println("Upgrade error:", err)
+		release()
+		logger.Warn(logger.TagSession, "WebSocket upgrade failed | Remote: %s, Error: %v", r.RemoteAddr, err)
 		return
 	}
 
@@ -57,40 +289,104 @@ func (s *Server) handleConnection(w http.ResponseWriter, r *http.Request) {
 	wsConn := protocol.NewWSConn(conn)
 	wsConn.SetReadLimit(16 * 1024 * 1024) // 16MB
 
-	// Create session with larger buffer for high concurrency
+	// Create session with per-priority send queues
 	sess := &session.Session{
 		ID:        uuid.New().String(),
-		Conn:      conn,                    // 保留原始连接用于底层操作
-		Send:      make(chan []byte, 1024), // 增加到 1024
-		AuthToken: "",
+		Conn:      conn, // 保留原始连接用于底层操作
+		Queue:     session.NewSendQueue(s.queueConfig),
+		AuthToken: query.Get("token"),
+		RemoteIP:  ip,
+		GameID:    query.Get("game"), // 连接声明的游戏：聊天请求须属于该游戏，未声明时由首次聊天绑定
+		Origin:    r.Header.Get("Origin"),
+
+		ConnectedAt: time.Now(),
+	}
+	if s.limiter != nil {
+		sess.RateLimit = ratelimit.NewSessionState()
 	}
 	s.sessions.Add(sess)
 	metrics.GlobalMetrics.IncrementConnections()
 
+	// 断线续传：恢复用户绑定并补发断线期间的下行包，然后下发新的续传 Token
+	if sess.ResumeToken != "" {
+		s.resumeSession(sess, r)
+	}
+
+	// 已鉴权且未续传的会话绑定 Token 对应的用户（登录策略拒绝时 Bind 会下发踢出通知）
+	if identity != nil && sess.UserID == 0 {
+		gameID := sess.GameID
+		if gameID == "" {
+			gameID = identity.GameId
+		}
+		if err := s.sessions.Bind(identity.UserId, sess.ID, query.Get("device"), gameID); err != nil {
+			logger.Info(logger.TagSession, "Bind rejected | Session: %s, UserID: %d, Error: %v", sess.ID, identity.UserId, err)
+		}
+	}
+
 	// 在 session 中存储协议连接（扩展 Session 结构体）
-	log.Printf("[INFO][SESSION] [CONN] New connection | Session: %s | RemoteAddr: %s", sess.ID, r.RemoteAddr)
+	logger.Info(logger.TagSession, "New connection | Session: %s, RemoteAddr: %s", sess.ID, r.RemoteAddr)
 
 	// Start loops
 	go s.writePump(sess)
-	go s.readPump(sess, wsConn)
+	go func() {
+		defer release()
+		s.readPump(sess, wsConn)
+	}()
+}
+
+// resumeSession 处理 /ws?resume_token=...&last_seq=... 并下发 SessionInfo
+func (s *Server) resumeSession(sess *session.Session, r *http.Request) {
+	info := &protocol.SessionInfo{
+		ResumeToken:    sess.ResumeToken,
+		ResumeWindowMs: s.sessions.ReplayConfig().Window.Milliseconds(),
+	}
+
+	query := r.URL.Query()
+	if token := query.Get("resume_token"); token != "" {
+		lastSeq, _ := strconv.ParseUint(query.Get("last_seq"), 10, 32)
+		if result, ok := s.sessions.Resume(sess, token, uint32(lastSeq)); ok {
+			info.Resumed = true
+			info.Replayed = result.Replayed
+			info.Gap = result.Gap
+			metrics.GlobalMetrics.IncrementSessionsResumed(result.Replayed)
+			logger.Info(logger.TagSession, "Session resumed | Session: %s, UserID: %d, Replayed: %d, Gap: %v",
+				sess.ID, result.UserID, result.Replayed, result.Gap)
+		} else {
+			metrics.GlobalMetrics.IncrementResumeFailed()
+			logger.Debug(logger.TagSession, "Resume token invalid or expired | Session: %s", sess.ID)
+		}
+	}
+
+	payload, err := protocol.EncodeSystemPayload(protocol.PayloadSystemSession, info)
+	if err != nil {
+		logger.Error(logger.TagSession, "Encode SessionInfo failed | Session: %s, Error: %v", sess.ID, err)
+		return
+	}
+	// 排在补发包之后、实时消息之前
+	sess.Queue.Preload(protocol.NewPacket(protocol.RouteSystem, payload).Encode())
 }
 
 // readPump 使用二进制协议读取消息
 func (s *Server) readPump(sess *session.Session, wsConn *protocol.WSConn) {
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("[ERROR][SESSION] [PANIC] ReadPump panic | Session: %s | UserID: %d | Panic: %v", sess.ID, sess.UserID, r)
+			logger.Error(logger.TagSession, "ReadPump panic | Session: %s, UserID: %d, Panic: %v", sess.ID, sess.UserID, r)
 		}
 		metrics.GlobalMetrics.DecrementConnections()
-		log.Printf("[INFO][SESSION] [DISCONN] Session closed | Session: %s | UserID: %d", sess.ID, sess.UserID)
+		logger.Info(logger.TagSession, "Session closed | Session: %s, UserID: %d", sess.ID, sess.UserID)
 		s.sessions.Remove(sess.ID)
-		sess.Conn.Close()
+		sess.Close()
 	}()
 
 	sess.Conn.SetReadLimit(16 * 1024 * 1024) // 16MB max
 	sess.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-	sess.Conn.SetPongHandler(func(string) error {
+	sess.Conn.SetPongHandler(func(data string) error {
 		sess.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		// Ping 携带发送时间（见 writePump），用于计算 RTT
+		if len(data) == 8 {
+			sent := time.Unix(0, int64(binary.BigEndian.Uint64([]byte(data))))
+			sess.ObserveRTT(time.Since(sent))
+		}
 		return nil
 	})
 
@@ -99,30 +395,61 @@ func (s *Server) readPump(sess *session.Session, wsConn *protocol.WSConn) {
 		pkt, err := wsConn.ReadPacket()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("[WARN][SESSION] [READ-ERR] Unexpected close | Session: %s | UserID: %d | Error: %v", sess.ID, sess.UserID, err)
+				logger.Warn(logger.TagSession, "Unexpected close | Session: %s, UserID: %d, Error: %v", sess.ID, sess.UserID, err)
 			} else {
-				log.Printf("[DEBUG][SESSION] [READ-CLOSE] Normal close | Session: %s | UserID: %d", sess.ID, sess.UserID)
+				logger.Debug(logger.TagSession, "Normal close | Session: %s, UserID: %d", sess.ID, sess.UserID)
 			}
 			break
 		}
 
 		metrics.GlobalMetrics.IncrementMessagesReceived()
-		log.Printf("ReadPump: Session %s received packet: Route=%d, Seq=%d, PayloadLen=%d",
-			sess.ID, pkt.Route, pkt.Sequence, len(pkt.Payload))
+		metrics.GlobalMetrics.ObserveFrame("in", protocol.HeaderSize+len(pkt.Payload))
+		// 每包日志：未启用时跳过参数构造，启用时按采样配置限流
+		if logger.Enabled(logger.TagProtocol, logger.DEBUG) {
+			logger.DebugCtx(context.Background(), logger.TagProtocol, "Received packet",
+				logger.SessionID(sess.ID), logger.UserID(sess.UserID), logger.GameID(sess.GameID),
+				logger.Route(pkt.Route), logger.Seq(pkt.Sequence), "payload_len", len(pkt.Payload))
+		}
 
 		// 重置读取超时
 		sess.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 
-		// 路由消息 - 只传递 Payload (纯 Protobuf)
+		// 上行限流：超限包丢弃并回复 ThrottleNotice，反复超限则断开
+		if s.limiter != nil {
+			if d := s.limiter.Allow(sess.RateLimit, sess.UserID, sess.RemoteIP, pkt.Route); !d.Allowed {
+				metrics.GlobalMetrics.IncrementRateLimited()
+				if d.Ban {
+					metrics.GlobalMetrics.IncrementRateLimitBans()
+					logger.Warn(logger.TagSession, "Rate limit exceeded repeatedly, disconnecting | Session: %s, UserID: %d, IP: %s, Scope: %s",
+						sess.ID, sess.UserID, sess.RemoteIP, d.Scope)
+					sess.Kick("rate limit exceeded")
+					break
+				}
+				s.sendThrottle(sess, pkt.Sequence, d)
+				continue
+			}
+		}
+
+		// 路由消息 - 只传递 Payload (纯 Protobuf)，路由统计由 router.Metrics 中间件记录
 		if err := s.router.RoutePacket(sess, pkt); err != nil {
 			logger.Warn(logger.TagRouter, "Routing error for Session %s: %v", sess.ID, err)
-			metrics.GlobalMetrics.IncrementRoutingErrors()
-		} else {
-			metrics.GlobalMetrics.IncrementMessagesRouted()
 		}
 	}
 }
 
+// sendThrottle 通知客户端序列号为 seq 的请求被限流
+// 使用低优先级并按 key 合并，持续超限时队列中最多只有一条通知
+func (s *Server) sendThrottle(sess *session.Session, seq uint32, d ratelimit.Decision) {
+	payload, err := protocol.EncodeSystemPayload(protocol.PayloadSystemThrottle, &protocol.ThrottleNotice{
+		Scope:        string(d.Scope),
+		RetryAfterMs: d.RetryAfter.Milliseconds(),
+	})
+	if err != nil {
+		return
+	}
+	sess.Queue.Push(session.PriorityLow, protocol.NewPacketWithSeq(protocol.RouteSystem, seq, payload).Encode(), "throttle")
+}
+
 // writePump 使用二进制协议发送消息
 func (s *Server) writePump(sess *session.Session) {
 	ticker := time.NewTicker(50 * time.Second) // Ping period
@@ -134,34 +461,57 @@ func (s *Server) writePump(sess *session.Session) {
 
 	for {
 		select {
-		case message, ok := <-sess.Send:
+		case <-sess.Queue.Ready():
 			// 检查队列长度
-			queueLen := len(sess.Send)
-			if queueLen > 512 {
-				log.Printf("[WARN][SESSION] [QUEUE-WARN] Send queue high | Session: %s | UserID: %d | QueueLen: %d/1024", sess.ID, sess.UserID, queueLen)
+			queueLen := sess.Queue.Len()
+			queueCap := sess.Queue.Cap()
+			if queueLen > queueCap/2 {
+				logger.WarnCtx(context.Background(), logger.TagSession, "Send queue high",
+					logger.SessionID(sess.ID), logger.UserID(sess.UserID), logger.GameID(sess.GameID),
+					"queue_len", queueLen, "queue_cap", queueCap)
 			}
 
-			sess.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if !ok {
-				sess.Conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
+			// 按优先级发送所有待发送消息（已包含协议头部）
+			for {
+				message, ok := sess.Queue.Pop()
+				if !ok {
+					break
+				}
 
-			// message 现在是完整的协议包（已包含头部）
-			// 直接通过 WebSocket 发送
-			logger.Debug(logger.TagProtocol, "Sending %d bytes to Session %s", len(message), sess.ID)
+				if logger.Enabled(logger.TagProtocol, logger.DEBUG) {
+					logger.DebugCtx(context.Background(), logger.TagProtocol, "Sending packet", logger.SessionID(sess.ID), "size", len(message))
+				}
 
-			if err := sess.Conn.WriteMessage(websocket.BinaryMessage, message); err != nil {
-				logger.Error(logger.TagSession, "Write error for Session %s: %v", sess.ID, err)
-				return
+				sess.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+				if err := sess.Conn.WriteMessage(websocket.BinaryMessage, message); err != nil {
+					logger.Error(logger.TagSession, "Write error for Session %s: %v", sess.ID, err)
+					return
+				}
+				metrics.GlobalMetrics.IncrementMessagesSent()
+				metrics.GlobalMetrics.ObserveFrame("out", len(message))
 			}
-			metrics.GlobalMetrics.IncrementMessagesSent()
 
-			logger.Debug(logger.TagProtocol, "Successfully sent to Session %s", sess.ID)
+		case <-sess.Queue.Done():
+			// 发送关闭前已入队的消息（如踢下线通知），再发送 Close 帧
+			sess.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			for {
+				message, ok := sess.Queue.Pop()
+				if !ok {
+					break
+				}
+				if err := sess.Conn.WriteMessage(websocket.BinaryMessage, message); err != nil {
+					return
+				}
+				metrics.GlobalMetrics.IncrementMessagesSent()
+				metrics.GlobalMetrics.ObserveFrame("out", len(message))
+			}
+			sess.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+			return
 
 		case <-ticker.C:
 			sess.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if err := sess.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			ping := binary.BigEndian.AppendUint64(nil, uint64(time.Now().UnixNano()))
+			if err := sess.Conn.WriteMessage(websocket.PingMessage, ping); err != nil {
 				return
 			}
 		}
@@ -0,0 +1,109 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+
+	cmap "github.com/orcaman/concurrent-map/v2"
+)
+
+// LoginPolicy 同一用户在不同设备上重复登录时的处理策略（按游戏配置）
+// 同一设备重复登录总是替换旧会话
+type LoginPolicy int
+
+const (
+	LoginAllow     LoginPolicy = iota // 允许多端同时在线，下行消息投递到所有设备
+	LoginKickOld                      // 踢掉其他设备上的会话
+	LoginRejectNew                    // 已在其他设备在线时拒绝新登录
+)
+
+// ErrLoginRejected 用户已在其他设备在线，按 LoginRejectNew 策略拒绝
+var ErrLoginRejected = errors.New("login rejected: user already online on another device")
+
+// ParseLoginPolicy 解析配置中的策略名称
+func ParseLoginPolicy(s string) (LoginPolicy, error) {
+	switch s {
+	case "", "allow":
+		return LoginAllow, nil
+	case "kick_old":
+		return LoginKickOld, nil
+	case "reject_new":
+		return LoginRejectNew, nil
+	default:
+		return LoginAllow, fmt.Errorf("unknown duplicate login policy: %q", s)
+	}
+}
+
+func (p LoginPolicy) String() string {
+	switch p {
+	case LoginAllow:
+		return "allow"
+	case LoginKickOld:
+		return "kick_old"
+	case LoginRejectNew:
+		return "reject_new"
+	default:
+		return fmt.Sprintf("LoginPolicy(%d)", int(p))
+	}
+}
+
+// deviceIndex UserID -> (DeviceID -> V)
+// 内层 map 写时复制，只在 cmap 分片锁内替换，读取方拿到的是不可变快照
+type deviceIndex[V comparable] struct {
+	m cmap.ConcurrentMap[int32, map[string]V]
+}
+
+func newDeviceIndex[V comparable]() deviceIndex[V] {
+	return deviceIndex[V]{m: cmap.NewWithCustomShardingFunction[int32, map[string]V](func(key int32) uint32 {
+		return uint32(key) // Simple hash for int32
+	})}
+}
+
+// get 返回用户各设备的快照（不可修改）
+func (d deviceIndex[V]) get(userID int32) map[string]V {
+	v, _ := d.m.Get(userID)
+	return v
+}
+
+// update 在分片锁内基于当前快照计算新快照；fn 返回 nil 表示不修改
+func (d deviceIndex[V]) update(userID int32, fn func(cur map[string]V) map[string]V) {
+	d.m.Upsert(userID, nil, func(_ bool, cur, _ map[string]V) map[string]V {
+		if next := fn(cur); next != nil {
+			return next
+		}
+		return cur
+	})
+	d.m.RemoveCb(userID, func(_ int32, v map[string]V, exists bool) bool {
+		return exists && len(v) == 0
+	})
+}
+
+// put 设置某设备的值，返回被替换的旧值
+func (d deviceIndex[V]) put(userID int32, deviceID string, v V) (old V, replaced bool) {
+	d.update(userID, func(cur map[string]V) map[string]V {
+		old, replaced = cur[deviceID]
+		next := make(map[string]V, len(cur)+1)
+		for k, x := range cur {
+			next[k] = x
+		}
+		next[deviceID] = v
+		return next
+	})
+	return old, replaced
+}
+
+// remove 仅当该设备当前值为 v 时移除
+func (d deviceIndex[V]) remove(userID int32, deviceID string, v V) {
+	d.update(userID, func(cur map[string]V) map[string]V {
+		if x, ok := cur[deviceID]; !ok || x != v {
+			return nil
+		}
+		next := make(map[string]V, len(cur))
+		for k, x := range cur {
+			if k != deviceID {
+				next[k] = x
+			}
+		}
+		return next
+	})
+}
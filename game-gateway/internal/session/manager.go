@@ -1,10 +1,16 @@
 package session
 
 import (
-	"log"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"game-gateway/internal/logger"
+	"game-gateway/internal/ratelimit"
+	"game-gateway/pkg/protocol"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	cmap "github.com/orcaman/concurrent-map/v2"
 )
@@ -12,57 +18,354 @@ import (
 type Session struct {
 	ID        string
 	Conn      *websocket.Conn
-	Send      chan []byte
+	Queue     *SendQueue // 按优先级分类的下行发送队列
 	UserID    int32
 	AuthToken string
+	DeviceID  string // 绑定时上报的设备 ID（未上报时为会话 ID）
+	GameID    string
+	RemoteIP  string
+	Origin    string // 浏览器客户端升级请求的 Origin，非浏览器客户端为空
+
+	ConnectedAt time.Time    // 连接建立时间
+	rtt         atomic.Int64 // 最近一次 Ping/Pong 往返时间（纳秒），0 表示尚未测得
+
+	// RateLimit 上行限流状态（未启用限流时为 nil）
+	RateLimit *ratelimit.SessionState
+
+	// 断线续传：ResumeToken 由 Manager.Add 分配，Replay 为该用户的下行包缓冲（未启用时为 nil）
+	ResumeToken string
+	Replay      *ReplayBuffer
+
+	closeOnce sync.Once
+}
+
+// RTT 返回最近一次 Ping/Pong 往返时间，尚未测得时为 0
+func (s *Session) RTT() time.Duration {
+	return time.Duration(s.rtt.Load())
+}
+
+// ObserveRTT 记录一次 Ping/Pong 往返时间
+func (s *Session) ObserveRTT(d time.Duration) {
+	s.rtt.Store(int64(d))
+}
+
+// Send 编码并投递一个下行包；启用续传时由 Replay 分配服务端序号并记录
+func (s *Session) Send(prio Priority, route protocol.RouteType, payload []byte) PushResult {
+	if s.Replay != nil {
+		return s.Replay.Send(prio, route, payload)
+	}
+	return s.Queue.Push(prio, protocol.NewPacket(route, payload).Encode(), "")
+}
+
+// Reply 投递对客户端请求的响应，保留请求的 Sequence；
+// 响应不进入续传缓冲（续传包的 Sequence 字段为服务端序号）
+func (s *Session) Reply(prio Priority, route protocol.RouteType, seq uint32, payload []byte) PushResult {
+	return s.Queue.Push(prio, protocol.NewPacketWithSeq(route, seq, payload).Encode(), "")
+}
+
+// Kick 通知客户端被踢下线的原因后关闭会话
+func (s *Session) Kick(reason string) {
+	if payload, err := protocol.EncodeSystemPayload(protocol.PayloadSystemKicked, &protocol.KickNotice{Reason: reason}); err == nil {
+		s.Queue.Push(PriorityHigh, protocol.NewPacket(protocol.RouteSystem, payload).Encode(), "")
+	}
+	s.Close()
+}
+
+// closeGracePeriod 关闭会话时留给 writePump 发送剩余消息及 Close 帧的时间
+const closeGracePeriod = time.Second
+
+// Close 关闭发送队列，writePump 发送剩余消息及 Close 帧后退出；宽限期后强制关闭底层连接，
+// readPump 随后退出并清理会话（可重复调用）
+func (s *Session) Close() {
+	s.closeOnce.Do(func() {
+		s.Queue.Close()
+		time.AfterFunc(closeGracePeriod, func() {
+			s.Conn.Close()
+		})
+	})
+}
+
+// detachedState 断开连接后保留的续传状态
+type detachedState struct {
+	token    string
+	userID   int32
+	deviceID string
+	gameID   string
+	replay   *ReplayBuffer
+	timer    *time.Timer
+}
+
+// ResumeResult 续传结果
+type ResumeResult struct {
+	UserID   int32
+	Replayed int  // 补发的包数
+	Gap      bool // 部分包已超出缓冲区，客户端需自行补拉
 }
 
 // Manager using lock-free concurrent map (Phase 3 optimization)
 type Manager struct {
 	sessions     cmap.ConcurrentMap[string, *Session] // SessionID -> Session
-	userSessions cmap.ConcurrentMap[int32, *Session]  // UserID -> Session
+	userSessions deviceIndex[*Session]                // UserID -> DeviceID -> Session
+
+	loginPolicies cmap.ConcurrentMap[string, LoginPolicy] // GameID -> 重复登录策略，未配置的游戏允许多端在线
+
+	replayCfg    ReplayConfig
+	tokens       cmap.ConcurrentMap[string, *Session]       // ResumeToken -> 在线 Session
+	detached     cmap.ConcurrentMap[string, *detachedState] // ResumeToken -> 断开的续传状态
+	userDetached deviceIndex[*detachedState]                // UserID -> DeviceID -> 断开的续传状态
 }
 
 func NewManager() *Manager {
 	return &Manager{
-		sessions: cmap.New[*Session](),
-		userSessions: cmap.NewWithCustomShardingFunction[int32, *Session](func(key int32) uint32 {
-			return uint32(key) // Simple hash for int32
-		}),
+		sessions:      cmap.New[*Session](),
+		userSessions:  newDeviceIndex[*Session](),
+		loginPolicies: cmap.New[LoginPolicy](),
+		tokens:        cmap.New[*Session](),
+		detached:      cmap.New[*detachedState](),
+		userDetached:  newDeviceIndex[*detachedState](),
 	}
 }
 
+// SetReplayConfig 设置断线续传配置，只影响之后新建的会话
+func (m *Manager) SetReplayConfig(cfg ReplayConfig) {
+	m.replayCfg = cfg
+}
+
+// ReplayConfig 返回断线续传配置
+func (m *Manager) ReplayConfig() ReplayConfig {
+	return m.replayCfg
+}
+
+// SetLoginPolicy 设置某个游戏的重复登录策略，可在运行时热更新（只影响之后的登录）
+func (m *Manager) SetLoginPolicy(gameID string, policy LoginPolicy) {
+	m.loginPolicies.Set(gameID, policy)
+}
+
+// LoginPolicy 返回游戏的重复登录策略
+func (m *Manager) LoginPolicy(gameID string) LoginPolicy {
+	policy, _ := m.loginPolicies.Get(gameID)
+	return policy
+}
+
 func (m *Manager) Add(s *Session) {
+	if m.replayCfg.Enabled() {
+		s.ResumeToken = uuid.New().String()
+		s.Replay = NewReplayBuffer(m.replayCfg.Size, s.Queue)
+		m.tokens.Set(s.ResumeToken, s)
+	}
 	m.sessions.Set(s.ID, s)
 	logger.Debug(logger.TagSession, "Added session %s", s.ID)
 }
 
-func (m *Manager) Bind(userID int32, sessionID string) {
-	logger.Debug(logger.TagSession, "Trying to bind UserID %d to Session %s", userID, sessionID)
+// Bind 将会话绑定到用户的某个设备，并按游戏的重复登录策略处理该用户的其他会话；
+// 被拒绝时新会话会收到踢下线通知并关闭，返回 ErrLoginRejected
+func (m *Manager) Bind(userID int32, sessionID, deviceID, gameID string) error {
+	logger.Debug(logger.TagSession, "Trying to bind UserID %d to Session %s | Device: %s, Game: %s", userID, sessionID, deviceID, gameID)
 
 	s, ok := m.sessions.Get(sessionID)
 	if !ok {
 		logger.Error(logger.TagSession, "Bind ERROR - Session %s not found!", sessionID)
-		return
+		return fmt.Errorf("session %s not found", sessionID)
+	}
+	if deviceID == "" {
+		// 未上报设备 ID 时每个会话视为独立设备
+		deviceID = s.ID
 	}
 
-	// Update session UserID (atomic operation on the field level)
-	s.UserID = userID
-	m.userSessions.Set(userID, s)
+	policy := m.LoginPolicy(gameID)
+	if err := m.admit(s, userID, deviceID, gameID, policy); err != nil {
+		logger.Warn(logger.TagSession, "Login rejected | UserID: %d, Session: %s, Device: %s, Policy: %s", userID, sessionID, deviceID, policy)
+		s.Kick(err.Error())
+		return err
+	}
+
+	// 该设备以新会话登录（未续传），旧的续传状态作废；kick_old 时其他设备的也作废
+	for dev, d := range m.userDetached.get(userID) {
+		if dev == deviceID || policy == LoginKickOld {
+			m.dropDetached(d)
+		}
+	}
 	logger.Debug(logger.TagSession, "Successfully bound UserID %d to Session %s", userID, sessionID)
+	return nil
 }
 
-func (m *Manager) Remove(id string) {
-	s, ok := m.sessions.Get(id)
-	if ok {
-		if s.UserID != 0 {
-			logger.Debug(logger.TagSession, "Removing Session %s (UserID=%d)", id, s.UserID)
-			m.userSessions.Remove(s.UserID)
-		} else {
-			logger.Debug(logger.TagSession, "Removing Session %s (no UserID)", id)
+// admit 按策略将 s 加入用户的在线会话，并踢掉被替换的会话
+func (m *Manager) admit(s *Session, userID int32, deviceID, gameID string, policy LoginPolicy) error {
+	var (
+		kicked []*Session
+		err    error
+	)
+	m.userSessions.update(userID, func(cur map[string]*Session) map[string]*Session {
+		next := make(map[string]*Session, len(cur)+1)
+		for dev, other := range cur {
+			switch {
+			case other == s:
+				continue // 重复绑定
+			case dev == deviceID:
+				kicked = append(kicked, other)
+				continue
+			case policy == LoginKickOld:
+				kicked = append(kicked, other)
+				continue
+			case policy == LoginRejectNew:
+				err = ErrLoginRejected
+			}
+			next[dev] = other
+		}
+		if err != nil {
+			kicked = nil
+			return nil
 		}
-		m.sessions.Remove(id)
+		next[deviceID] = s
+		return next
+	})
+	if err != nil {
+		return err
 	}
+
+	s.UserID = userID
+	s.DeviceID = deviceID
+	s.GameID = gameID
+
+	for _, other := range kicked {
+		// 被踢的会话不再保留续传状态
+		if other.Replay != nil {
+			other.Replay.detachFrom(other.Queue)
+		}
+		reason := "logged in on another device"
+		if other.DeviceID == deviceID {
+			reason = "logged in again on this device"
+		}
+		logger.Info(logger.TagSession, "Kicking session | UserID: %d, Session: %s, Device: %s, Reason: %s",
+			userID, other.ID, other.DeviceID, reason)
+		other.Kick(reason)
+	}
+	return nil
+}
+
+func (m *Manager) Remove(id string) {
+	s, ok := m.sessions.Pop(id)
+	if !ok {
+		return
+	}
+	if s.UserID != 0 {
+		logger.Debug(logger.TagSession, "Removing Session %s (UserID=%d, Device=%s)", id, s.UserID, s.DeviceID)
+		// 该设备可能已绑定到新会话，只移除指向本会话的映射
+		m.userSessions.remove(s.UserID, s.DeviceID, s)
+	} else {
+		logger.Debug(logger.TagSession, "Removing Session %s (no UserID)", id)
+	}
+
+	if s.ResumeToken != "" {
+		m.tokens.RemoveCb(s.ResumeToken, func(_ string, v *Session, exists bool) bool {
+			return exists && v == s
+		})
+		m.park(s)
+	}
+}
+
+// park 保留已绑定用户的会话的续传状态，Window 内未续传则丢弃
+func (m *Manager) park(s *Session) {
+	if s.Replay == nil || s.UserID == 0 {
+		return
+	}
+	// 已被新连接接管或被踢下线
+	if !s.Replay.detachFrom(s.Queue) {
+		return
+	}
+
+	d := &detachedState{
+		token:    s.ResumeToken,
+		userID:   s.UserID,
+		deviceID: s.DeviceID,
+		gameID:   s.GameID,
+		replay:   s.Replay,
+	}
+	// 计时器在发布前创建，其他 goroutine 读到 d 时 timer 已就绪
+	d.timer = time.AfterFunc(m.replayCfg.Window, func() {
+		m.unlinkDetached(d)
+		logger.Debug(logger.TagSession, "Resume window expired | UserID: %d, Device: %s", d.userID, d.deviceID)
+	})
+	m.detached.Set(d.token, d)
+	if old, ok := m.userDetached.put(d.userID, d.deviceID, d); ok {
+		m.dropDetached(old)
+	}
+	logger.Debug(logger.TagSession, "Session %s parked for resume | UserID: %d, Device: %s", s.ID, s.UserID, s.DeviceID)
+}
+
+func (m *Manager) dropDetached(d *detachedState) {
+	d.timer.Stop()
+	m.unlinkDetached(d)
+}
+
+// unlinkDetached 从索引中移除续传状态
+func (m *Manager) unlinkDetached(d *detachedState) {
+	m.detached.RemoveCb(d.token, func(_ string, v *detachedState, exists bool) bool {
+		return exists && v == d
+	})
+	m.userDetached.remove(d.userID, d.deviceID, d)
+}
+
+// Resume 使用续传 Token 恢复之前会话的用户绑定及下行序号，
+// 并将序号大于 lastSeq 的包排在新会话发送队列的最前面；Token 无效、已过期或被登录策略拒绝时返回 false
+func (m *Manager) Resume(s *Session, token string, lastSeq uint32) (ResumeResult, bool) {
+	var (
+		replay   *ReplayBuffer
+		userID   int32
+		deviceID string
+		gameID   string
+	)
+
+	if d, ok := m.detached.Pop(token); ok {
+		m.dropDetached(d)
+		replay, userID, deviceID, gameID = d.replay, d.userID, d.deviceID, d.gameID
+	} else if prev, ok := m.tokens.Get(token); ok && prev != s && prev.UserID != 0 && prev.Replay != nil &&
+		m.tokens.RemoveCb(token, func(_ string, v *Session, exists bool) bool { return exists && v == prev }) {
+		// 旧连接尚未检测到断开（如移动网络切换），接管其续传状态，旧会话作为同设备登录被踢下线
+		replay, userID, deviceID, gameID = prev.Replay, prev.UserID, prev.DeviceID, prev.GameID
+	} else {
+		return ResumeResult{}, false
+	}
+
+	// 先切换到旧的下行缓冲，会话对路由可见后新消息即按原序号继续编号
+	s.Replay = replay
+	if err := m.admit(s, userID, deviceID, gameID, m.LoginPolicy(gameID)); err != nil {
+		logger.Warn(logger.TagSession, "Resume rejected | UserID: %d, Session: %s, Device: %s", userID, s.ID, deviceID)
+		s.Kick(err.Error())
+		return ResumeResult{}, false
+	}
+	replayed, gap := replay.attach(s.Queue, lastSeq)
+
+	logger.Debug(logger.TagSession, "Session %s resumed | UserID: %d, Device: %s, LastSeq: %d, Replayed: %d, Gap: %v",
+		s.ID, userID, deviceID, lastSeq, replayed, gap)
+	return ResumeResult{UserID: userID, Replayed: replayed, Gap: gap}, true
+}
+
+// GetReplays 返回用户已断开、仍在续传窗口内的各设备的下行缓冲
+func (m *Manager) GetReplays(userID int32) []*ReplayBuffer {
+	devices := m.userDetached.get(userID)
+	if len(devices) == 0 {
+		return nil
+	}
+	replays := make([]*ReplayBuffer, 0, len(devices))
+	for _, d := range devices {
+		replays = append(replays, d.replay)
+	}
+	return replays
+}
+
+// All 返回当前所有会话的快照
+func (m *Manager) All() []*Session {
+	all := make([]*Session, 0, m.sessions.Count())
+	for item := range m.sessions.IterBuffered() {
+		all = append(all, item.Val)
+	}
+	return all
+}
+
+// Count 返回当前会话数
+func (m *Manager) Count() int {
+	return m.sessions.Count()
 }
 
 func (m *Manager) Get(id string) *Session {
@@ -70,21 +373,17 @@ func (m *Manager) Get(id string) *Session {
 	return s
 }
 
-func (m *Manager) GetByUserID(userID int32) *Session {
-	s, ok := m.userSessions.Get(userID)
-	if ok {
-		log.Printf("SessionManager.GetByUserID: Found UserID %d -> Session %s", userID, s.ID)
-		return s
+// GetByUserID 返回用户在所有设备上的在线会话
+func (m *Manager) GetByUserID(userID int32) []*Session {
+	devices := m.userSessions.get(userID)
+	if len(devices) == 0 {
+		logger.Debug(logger.TagSession, "UserID %d NOT FOUND", userID)
+		return nil
 	}
 
-	logger.Debug(logger.TagSession, "UserID %d NOT FOUND", userID)
-	// Debug: print all entries
-	count := 0
-	for item := range m.userSessions.IterBuffered() {
-		log.Printf("  - UserID %d -> Session %s", item.Key, item.Val.ID)
-		count++
+	sessions := make([]*Session, 0, len(devices))
+	for _, s := range devices {
+		sessions = append(sessions, s)
 	}
-	logger.Debug(logger.TagSession, "Current userSessions map has %d entries", count)
-
-	return nil
+	return sessions
 }
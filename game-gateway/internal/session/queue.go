@@ -0,0 +1,339 @@
+package session
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Priority 下行消息优先级，数值越小越优先发送
+type Priority int
+
+const (
+	PriorityHigh   Priority = iota // ACK、私聊等可靠消息
+	PriorityNormal                 // 频道聊天
+	PriorityLow                    // 在线状态等可合并的通知
+	numPriorities
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityHigh:
+		return "high"
+	case PriorityNormal:
+		return "normal"
+	case PriorityLow:
+		return "low"
+	default:
+		return fmt.Sprintf("Priority(%d)", int(p))
+	}
+}
+
+// SlowConsumerPolicy 某优先级队列满时的处理策略
+type SlowConsumerPolicy int
+
+const (
+	PolicyDropOldest SlowConsumerPolicy = iota // 丢弃队列中最旧的消息
+	PolicyDropNewest                           // 丢弃新消息
+	PolicyCoalesce                             // 相同 key 的消息只保留最新一条，队列满时丢弃最旧
+	PolicyDisconnect                           // 丢弃新消息，窗口内丢弃次数超过阈值后断开连接
+)
+
+// ParseSlowConsumerPolicy 解析配置中的策略名称
+func ParseSlowConsumerPolicy(s string) (SlowConsumerPolicy, error) {
+	switch s {
+	case "", "drop_oldest":
+		return PolicyDropOldest, nil
+	case "drop_newest":
+		return PolicyDropNewest, nil
+	case "coalesce":
+		return PolicyCoalesce, nil
+	case "disconnect":
+		return PolicyDisconnect, nil
+	default:
+		return PolicyDropOldest, fmt.Errorf("unknown slow consumer policy: %q", s)
+	}
+}
+
+func (p SlowConsumerPolicy) String() string {
+	switch p {
+	case PolicyDropOldest:
+		return "drop_oldest"
+	case PolicyDropNewest:
+		return "drop_newest"
+	case PolicyCoalesce:
+		return "coalesce"
+	case PolicyDisconnect:
+		return "disconnect"
+	default:
+		return fmt.Sprintf("SlowConsumerPolicy(%d)", int(p))
+	}
+}
+
+// ClassConfig 单个优先级队列配置
+type ClassConfig struct {
+	Size   int
+	Policy SlowConsumerPolicy
+}
+
+// QueueConfig 会话发送队列配置
+type QueueConfig struct {
+	Classes [numPriorities]ClassConfig
+
+	// PolicyDisconnect: DisconnectWindow 内丢弃超过 DisconnectAfterDrops 条即断开
+	DisconnectAfterDrops int
+	DisconnectWindow     time.Duration
+}
+
+// DefaultQueueConfig 默认配置：可靠消息满则断开，频道消息丢旧，通知合并
+func DefaultQueueConfig() QueueConfig {
+	return QueueConfig{
+		Classes: [numPriorities]ClassConfig{
+			PriorityHigh:   {Size: 512, Policy: PolicyDisconnect},
+			PriorityNormal: {Size: 1024, Policy: PolicyDropOldest},
+			PriorityLow:    {Size: 256, Policy: PolicyCoalesce},
+		},
+		DisconnectAfterDrops: 50,
+		DisconnectWindow:     10 * time.Second,
+	}
+}
+
+// PushResult 入队结果
+type PushResult int
+
+const (
+	PushQueued        PushResult = iota // 正常入队
+	PushCoalesced                       // 替换了相同 key 的待发送消息
+	PushDroppedOldest                   // 入队成功，但丢弃了一条旧消息
+	PushDroppedNewest                   // 新消息被丢弃
+	PushDisconnect                      // 新消息被丢弃，且该会话应被断开
+	PushClosed                          // 队列已关闭
+	PushBuffered                        // 会话已断开，消息仅记录到续传缓冲区
+)
+
+func (r PushResult) String() string {
+	switch r {
+	case PushQueued:
+		return "queued"
+	case PushCoalesced:
+		return "coalesced"
+	case PushDroppedOldest:
+		return "dropped_oldest"
+	case PushDroppedNewest:
+		return "dropped_newest"
+	case PushDisconnect:
+		return "disconnect"
+	case PushClosed:
+		return "closed"
+	case PushBuffered:
+		return "buffered"
+	default:
+		return fmt.Sprintf("PushResult(%d)", int(r))
+	}
+}
+
+type outMsg struct {
+	data []byte
+	key  string
+}
+
+// ring 固定容量的环形队列
+type ring struct {
+	buf  []outMsg
+	head int
+	n    int
+}
+
+func (r *ring) full() bool { return r.n == len(r.buf) }
+
+func (r *ring) push(m outMsg) {
+	r.buf[(r.head+r.n)%len(r.buf)] = m
+	r.n++
+}
+
+func (r *ring) pop() outMsg {
+	m := r.buf[r.head]
+	r.buf[r.head] = outMsg{}
+	r.head = (r.head + 1) % len(r.buf)
+	r.n--
+	return m
+}
+
+// replace 用新消息替换相同 key 的待发送消息
+func (r *ring) replace(m outMsg) bool {
+	for i := 0; i < r.n; i++ {
+		idx := (r.head + i) % len(r.buf)
+		if r.buf[idx].key == m.key {
+			r.buf[idx] = m
+			return true
+		}
+	}
+	return false
+}
+
+// SendQueue 按优先级分类的会话发送队列
+// 由 Router 入队，writePump 按优先级出队
+type SendQueue struct {
+	cfg QueueConfig
+
+	mu      sync.Mutex
+	classes [numPriorities]ring
+	preload [][]byte // 断线续传时补发的消息，先于所有优先级队列发送
+	closed  bool
+
+	windowStart time.Time
+	windowDrops int
+
+	ready chan struct{}
+	done  chan struct{}
+}
+
+func NewSendQueue(cfg QueueConfig) *SendQueue {
+	q := &SendQueue{
+		cfg:   cfg,
+		ready: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	for i := range q.classes {
+		size := cfg.Classes[i].Size
+		if size <= 0 {
+			size = 1
+		}
+		q.classes[i].buf = make([]outMsg, size)
+	}
+	return q
+}
+
+// Push 入队一条已编码的数据包；key 非空时可被 PolicyCoalesce 合并
+func (q *SendQueue) Push(prio Priority, data []byte, key string) PushResult {
+	if prio < 0 || prio >= numPriorities {
+		prio = PriorityNormal
+	}
+	m := outMsg{data: data, key: key}
+
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return PushClosed
+	}
+
+	c := &q.classes[prio]
+	policy := q.cfg.Classes[prio].Policy
+	result := PushQueued
+
+	switch {
+	case policy == PolicyCoalesce && key != "" && c.replace(m):
+		result = PushCoalesced
+	case !c.full():
+		c.push(m)
+	case policy == PolicyDropNewest:
+		result = PushDroppedNewest
+	case policy == PolicyDisconnect:
+		result = PushDroppedNewest
+		if q.recordDrop() {
+			result = PushDisconnect
+		}
+	default: // PolicyDropOldest, PolicyCoalesce
+		c.pop()
+		c.push(m)
+		result = PushDroppedOldest
+	}
+	q.mu.Unlock()
+
+	if result == PushQueued || result == PushCoalesced || result == PushDroppedOldest {
+		select {
+		case q.ready <- struct{}{}:
+		default:
+		}
+	}
+	return result
+}
+
+// recordDrop 记录一次丢弃，返回是否超过断开阈值（调用方持有锁）
+func (q *SendQueue) recordDrop() bool {
+	now := time.Now()
+	if now.Sub(q.windowStart) > q.cfg.DisconnectWindow {
+		q.windowStart = now
+		q.windowDrops = 0
+	}
+	q.windowDrops++
+	return q.cfg.DisconnectAfterDrops > 0 && q.windowDrops >= q.cfg.DisconnectAfterDrops
+}
+
+// Preload 追加需要先于实时消息发送的数据（不受容量和慢消费者策略限制）
+func (q *SendQueue) Preload(data ...[]byte) {
+	if len(data) == 0 {
+		return
+	}
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.preload = append(q.preload, data...)
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Pop 先取出补发数据，再按优先级取出一条待发送数据，队列为空时返回 false
+func (q *SendQueue) Pop() ([]byte, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.preload) > 0 {
+		data := q.preload[0]
+		q.preload[0] = nil
+		q.preload = q.preload[1:]
+		return data, true
+	}
+	for i := range q.classes {
+		if q.classes[i].n > 0 {
+			return q.classes[i].pop().data, true
+		}
+	}
+	return nil, false
+}
+
+// Len 返回所有优先级队列中待发送的消息数
+func (q *SendQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := len(q.preload)
+	for i := range q.classes {
+		n += q.classes[i].n
+	}
+	return n
+}
+
+// Cap 返回所有优先级队列的总容量
+func (q *SendQueue) Cap() int {
+	n := 0
+	for i := range q.classes {
+		n += len(q.classes[i].buf)
+	}
+	return n
+}
+
+// Ready 有新消息入队时收到信号
+func (q *SendQueue) Ready() <-chan struct{} {
+	return q.ready
+}
+
+// Done 队列关闭时关闭
+func (q *SendQueue) Done() <-chan struct{} {
+	return q.done
+}
+
+// Close 关闭队列，之后的 Push 返回 PushClosed
+func (q *SendQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed = true
+	close(q.done)
+}
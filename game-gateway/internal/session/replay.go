@@ -0,0 +1,110 @@
+package session
+
+import (
+	"sync"
+	"time"
+
+	"game-gateway/pkg/protocol"
+)
+
+// ReplayConfig 断线续传配置
+type ReplayConfig struct {
+	Size   int           // 每个用户保留的最近下行包数
+	Window time.Duration // 断线后保留续传状态的时长
+}
+
+// Enabled 是否启用断线续传
+func (c ReplayConfig) Enabled() bool {
+	return c.Size > 0 && c.Window > 0
+}
+
+type replayEntry struct {
+	seq  uint32
+	data []byte
+}
+
+// ReplayBuffer 为一个用户的下行包分配服务端序号并保留最近 Size 个，
+// 会话断开后继续记录，客户端携带续传 Token 重连时补发其未收到的包
+type ReplayBuffer struct {
+	mu      sync.Mutex
+	entries []replayEntry // 环形缓冲
+	head    int
+	n       int
+	lastSeq uint32     // 最近分配的序号，从 1 开始
+	queue   *SendQueue // 当前连接的发送队列，断开期间为 nil
+}
+
+func NewReplayBuffer(size int, q *SendQueue) *ReplayBuffer {
+	if size <= 0 {
+		size = 1
+	}
+	return &ReplayBuffer{
+		entries: make([]replayEntry, size),
+		queue:   q,
+	}
+}
+
+// Send 分配序号、记录并投递到当前连接；断开期间只记录，返回 PushBuffered
+func (b *ReplayBuffer) Send(prio Priority, route protocol.RouteType, payload []byte) PushResult {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastSeq++
+	pkt := protocol.NewPacketWithSeq(route, b.lastSeq, payload)
+	pkt.Flags.SetFlag(protocol.FlagServerSeq)
+	data := pkt.Encode()
+	b.record(replayEntry{seq: b.lastSeq, data: data})
+
+	if b.queue == nil {
+		return PushBuffered
+	}
+	return b.queue.Push(prio, data, "")
+}
+
+func (b *ReplayBuffer) record(e replayEntry) {
+	if b.n == len(b.entries) {
+		b.entries[b.head] = e
+		b.head = (b.head + 1) % len(b.entries)
+		return
+	}
+	b.entries[(b.head+b.n)%len(b.entries)] = e
+	b.n++
+}
+
+// attach 绑定新连接的发送队列，并将序号大于 lastSeq 的包预加载到队列头部
+// 返回补发数量；gap 为 true 表示部分包已被挤出缓冲区，客户端需要自行补拉
+func (b *ReplayBuffer) attach(q *SendQueue, lastSeq uint32) (replayed int, gap bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.queue = q
+	if lastSeq > b.lastSeq {
+		// 客户端序号比服务端还新，说明状态不一致
+		return 0, true
+	}
+
+	var missed [][]byte
+	for i := 0; i < b.n; i++ {
+		e := b.entries[(b.head+i)%len(b.entries)]
+		if e.seq > lastSeq {
+			missed = append(missed, e.data)
+		}
+	}
+	if b.lastSeq-lastSeq > uint32(len(missed)) {
+		gap = true
+	}
+	q.Preload(missed...)
+	return len(missed), gap
+}
+
+// detachFrom 连接断开，之后的包只记录不投递
+// 若缓冲区已被新连接接管（不再绑定 q），返回 false
+func (b *ReplayBuffer) detachFrom(q *SendQueue) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.queue != q {
+		return false
+	}
+	b.queue = nil
+	return true
+}
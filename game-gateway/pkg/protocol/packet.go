@@ -14,7 +14,7 @@ import (
 //
 // Magic: 0x12345678 (魔数，用于校验)
 // Route: 路由类型 (1=GAME, 2=CHAT, 3=SYSTEM)
-// Flags: 标志位 (bit0=压缩, bit1=加密, bit2-7=保留)
+// Flags: 标志位 (bit0=压缩, bit1=加密, bit2=服务端下行序号, bit3-7=保留)
 // Reserved: 保留字段，用于未来扩展
 // Length: Payload 长度（不包含头部）
 // Sequence: 序列号（用于请求-响应匹配，客户端生成）
@@ -38,6 +38,19 @@ const (
 	RouteSystem  RouteType = 3
 )
 
+func (t RouteType) String() string {
+	switch t {
+	case RouteGame:
+		return "game"
+	case RouteChat:
+		return "chat"
+	case RouteSystem:
+		return "system"
+	default:
+		return "unknown"
+	}
+}
+
 // Flags 标志位
 type Flags byte
 
@@ -45,7 +58,8 @@ const (
 	FlagNone       Flags = 0
 	FlagCompressed Flags = 1 << 0 // bit 0: 是否压缩
 	FlagEncrypted  Flags = 1 << 1 // bit 1: 是否加密
-	// bits 2-7: 保留
+	FlagServerSeq  Flags = 1 << 2 // bit 2: Sequence 为服务端下行序号（用于断线续传）
+	// bits 3-7: 保留
 )
 
 // HasFlag 检查是否包含特定标志
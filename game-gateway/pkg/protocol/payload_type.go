@@ -17,6 +17,11 @@ const (
 	// SYSTEM Route 下的 Payload 类型
 	PayloadSystemPing    PayloadType = 20
 	PayloadSystemPong    PayloadType = 21
+	PayloadSystemReconnect PayloadType = 22 // 服务端 -> 客户端: 请重连到其他实例 (ReconnectNotice)
+	PayloadSystemSession   PayloadType = 23 // 服务端 -> 客户端: 会话信息及续传结果 (SessionInfo)
+	PayloadSystemKicked    PayloadType = 24 // 服务端 -> 客户端: 被踢下线 (KickNotice)，随后连接关闭
+	PayloadSystemThrottle  PayloadType = 25 // 服务端 -> 客户端: 请求被限流丢弃 (ThrottleNotice)
+	PayloadSystemNotice    PayloadType = 26 // 服务端 -> 客户端: 运维通知，如管理 API 发送的测试推送 (Notice)
 )
 
 // GetPayloadType 根据 Route 和消息方向推断 PayloadType
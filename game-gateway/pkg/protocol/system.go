@@ -0,0 +1,62 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SYSTEM Route 下行通知的 Payload 格式:
+// +-------------+-------------+
+// | PayloadType |  JSON Body  |
+// |  (1 byte)   |   (变长)     |
+// +-------------+-------------+
+
+// ReconnectNotice 服务端要求客户端断开后重连到其他 Gateway（如实例下线）
+type ReconnectNotice struct {
+	Reason       string `json:"reason"`
+	RetryAfterMs int64  `json:"retry_after_ms"` // 建议的重连等待时间
+}
+
+// KickNotice 会话被服务端关闭的原因（如重复登录策略）
+type KickNotice struct {
+	Reason string `json:"reason"`
+}
+
+// ThrottleNotice 上行包超过速率限制被丢弃，Packet.Sequence 为被丢弃请求的序列号
+type ThrottleNotice struct {
+	Scope        string `json:"scope"` // route / session / user / ip
+	RetryAfterMs int64  `json:"retry_after_ms"`
+}
+
+// Notice 运维通知（如管理 API 发送的测试推送），客户端可仅记录或展示
+type Notice struct {
+	Message string `json:"message"`
+	SentAt  int64  `json:"sent_at"` // 服务端发送时间（Unix 毫秒）
+}
+
+// SessionInfo 连接建立后下发的会话信息（在补发包之后、实时消息之前）
+// 客户端断线后携带 /ws?resume_token={ResumeToken}&last_seq={最后收到的带 FlagServerSeq 的 Sequence} 重连
+type SessionInfo struct {
+	ResumeToken    string `json:"resume_token"`
+	ResumeWindowMs int64  `json:"resume_window_ms"` // 断线后续传状态的保留时长
+	Resumed        bool   `json:"resumed"`          // 本次连接是否续传成功
+	Replayed       int    `json:"replayed"`         // 补发的包数
+	Gap            bool   `json:"gap"`              // 部分包已超出服务端缓冲，需要客户端自行补拉
+}
+
+// EncodeSystemPayload 编码 SYSTEM Route 的 Payload
+func EncodeSystemPayload(t PayloadType, body interface{}) ([]byte, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal system payload: %w", err)
+	}
+	return append([]byte{byte(t)}, data...), nil
+}
+
+// DecodeSystemPayload 解析 SYSTEM Route 的 Payload，返回类型和 JSON Body
+func DecodeSystemPayload(data []byte) (PayloadType, []byte, error) {
+	if len(data) < 1 {
+		return 0, nil, fmt.Errorf("empty system payload")
+	}
+	return PayloadType(data[0]), data[1:], nil
+}
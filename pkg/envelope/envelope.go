@@ -0,0 +1,103 @@
+package envelope
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Envelope Gateway 与后端服务（游戏逻辑服、Chat Service）之间转发客户端包时的封装，携带会话上下文
+// 后端对请求的响应需原样带回 SessionID 和 Sequence，Gateway 据此投递并保留客户端序列号；
+// 主动推送 Sequence 为 0，按 SessionID（优先）或 UserID 投递
+//
+// 编码格式 (Big Endian):
+// +---------+-------+----------+--------+-----------+-----------+-----------+-------------+---------+
+// | Version | Route | Sequence | UserID | SessionID |  GameID   |  ReplyTo  | TraceParent | Payload |
+// | (1byte) |(1byte)| (4 byte) |(4 byte)| (2+N byte)| (2+N byte)| (2+N byte)| (2+N byte)  |  (变长)  |
+// +---------+-------+----------+--------+-----------+-----------+-----------+-------------+---------+
+// 字符串字段为 2 字节长度 + UTF-8 内容；TraceParent 仅在 Version 2 中出现，
+// 为空时按 Version 1 编码，未升级的一端仍可解码
+type Envelope struct {
+	Route     uint8  // 客户端包的 Route（1=GAME, 2=CHAT）
+	Sequence  uint32 // 客户端请求序列号，推送为 0
+	UserID    int32  // 会话绑定的用户，未绑定为 0
+	SessionID string
+	GameID    string
+	ReplyTo   string // 经 MQ 转发时响应应发布到的 Topic；直连时响应沿原连接返回，为空
+	// TraceParent W3C traceparent，跨服务传递链路追踪上下文（见 game-pkg/tracing）
+	TraceParent string
+	Payload     []byte
+}
+
+// GatewayIDHeader 直连传输建连时携带的 Gateway 实例 ID，
+// 后端据此识别同一 Gateway 的多条连接（推送只需发往其中一条）
+const GatewayIDHeader = "X-Gateway-ID"
+
+// Route 取值与 Gateway 客户端协议的 RouteType 一致
+const (
+	RouteGame uint8 = 1
+	RouteChat uint8 = 2
+)
+
+const (
+	Version byte = 1
+	// VersionTrace 携带 TraceParent 的版本
+	VersionTrace byte = 2
+
+	fixedSize = 1 + 1 + 4 + 4
+)
+
+// Encode 编码 Envelope
+func (e *Envelope) Encode() []byte {
+	strs := []string{e.SessionID, e.GameID, e.ReplyTo}
+	version := Version
+	if e.TraceParent != "" {
+		strs = append(strs, e.TraceParent)
+		version = VersionTrace
+	}
+
+	size := fixedSize + 2*len(strs) + len(e.SessionID) + len(e.GameID) + len(e.ReplyTo) + len(e.TraceParent) + len(e.Payload)
+	buf := make([]byte, fixedSize, size)
+	buf[0] = version
+	buf[1] = e.Route
+	binary.BigEndian.PutUint32(buf[2:6], e.Sequence)
+	binary.BigEndian.PutUint32(buf[6:10], uint32(e.UserID))
+	for _, s := range strs {
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(s)))
+		buf = append(buf, s...)
+	}
+	return append(buf, e.Payload...)
+}
+
+// Decode 解码 Envelope，Payload 引用 data 的底层数组
+func Decode(data []byte) (*Envelope, error) {
+	if len(data) < fixedSize {
+		return nil, fmt.Errorf("envelope too short: %d < %d", len(data), fixedSize)
+	}
+	if data[0] != Version && data[0] != VersionTrace {
+		return nil, fmt.Errorf("unsupported envelope version: %d", data[0])
+	}
+	e := &Envelope{
+		Route:    data[1],
+		Sequence: binary.BigEndian.Uint32(data[2:6]),
+		UserID:   int32(binary.BigEndian.Uint32(data[6:10])),
+	}
+
+	rest := data[fixedSize:]
+	fields := []*string{&e.SessionID, &e.GameID, &e.ReplyTo}
+	if data[0] == VersionTrace {
+		fields = append(fields, &e.TraceParent)
+	}
+	for _, dst := range fields {
+		if len(rest) < 2 {
+			return nil, fmt.Errorf("envelope truncated")
+		}
+		n := int(binary.BigEndian.Uint16(rest))
+		if len(rest) < 2+n {
+			return nil, fmt.Errorf("envelope truncated")
+		}
+		*dst = string(rest[2 : 2+n])
+		rest = rest[2+n:]
+	}
+	e.Payload = rest
+	return e, nil
+}
@@ -0,0 +1,266 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"game-pkg/mq"
+)
+
+// DefaultTimeout 单次就绪检查（所有检查项并发执行）的超时时间
+const DefaultTimeout = 2 * time.Second
+
+// 整体 / 单项状态
+const (
+	StatusOK          = "ok"
+	StatusFail        = "fail"
+	StatusDraining    = "draining"
+	StatusUnavailable = "unavailable"
+)
+
+// Check 单项就绪检查：返回 error 表示未就绪，details 作为 JSON 附加信息（可为 nil）
+type Check func(ctx context.Context) (details any, err error)
+
+// Simple 将 func() error 适配为 Check
+func Simple(fn func() error) Check {
+	return func(context.Context) (any, error) {
+		return nil, fn()
+	}
+}
+
+// Ping 将依赖的连通性检查（如 Redis / Postgres Ping）适配为 Check
+func Ping(fn func(ctx context.Context) error) Check {
+	return func(ctx context.Context) (any, error) {
+		return nil, fn(ctx)
+	}
+}
+
+// Result 单项检查结果
+type Result struct {
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Latency string `json:"latency"`
+	Details any    `json:"details,omitempty"`
+}
+
+// Report /healthz、/readyz 的响应体
+type Report struct {
+	Status   string            `json:"status"`
+	Draining bool              `json:"draining"`
+	Uptime   string            `json:"uptime,omitempty"`
+	Checks   map[string]Result `json:"checks,omitempty"`
+}
+
+// Registry 就绪检查注册表
+// /healthz 为存活探针，只要进程能响应即返回 200；/readyz 为就绪探针，下线中或任一检查失败返回 503
+type Registry struct {
+	mu       sync.RWMutex
+	checks   map[string]Check
+	draining func() bool
+	timeout  time.Duration
+	start    time.Time
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		checks:  make(map[string]Check),
+		timeout: DefaultTimeout,
+		start:   time.Now(),
+	}
+}
+
+// Add 注册就绪检查项，同名覆盖
+func (r *Registry) Add(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = check
+}
+
+// SetDraining 设置下线状态来源，下线中 /readyz 返回 503
+func (r *Registry) SetDraining(fn func() bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.draining = fn
+}
+
+// SetTimeout 设置单次就绪检查的超时时间
+func (r *Registry) SetTimeout(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timeout = d
+}
+
+// Live 存活状态：不执行依赖检查
+func (r *Registry) Live() Report {
+	return Report{
+		Status:   StatusOK,
+		Draining: r.isDraining(),
+		Uptime:   time.Since(r.start).Round(time.Second).String(),
+	}
+}
+
+// Ready 并发执行所有检查项；下线中仍执行检查以便排查
+func (r *Registry) Ready(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := make(map[string]Check, len(r.checks))
+	for name, check := range r.checks {
+		checks[name] = check
+	}
+	timeout := r.timeout
+	r.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]Result, len(checks))
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := run(ctx, check)
+			mu.Lock()
+			results[name] = res
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	report := r.Live()
+	report.Checks = results
+	for _, res := range results {
+		if res.Status != StatusOK {
+			report.Status = StatusUnavailable
+		}
+	}
+	if report.Draining {
+		report.Status = StatusDraining
+	}
+	return report
+}
+
+// run 执行单项检查，超时视为失败（检查本身可能仍在运行）
+func run(ctx context.Context, check Check) Result {
+	start := time.Now()
+	type outcome struct {
+		details any
+		err     error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		details, err := check(ctx)
+		done <- outcome{details, err}
+	}()
+
+	var out outcome
+	select {
+	case out = <-done:
+	case <-ctx.Done():
+		out.err = fmt.Errorf("check timed out: %w", ctx.Err())
+	}
+
+	res := Result{
+		Status:  StatusOK,
+		Latency: time.Since(start).Round(time.Microsecond).String(),
+		Details: out.details,
+	}
+	if out.err != nil {
+		res.Status = StatusFail
+		res.Error = out.err.Error()
+	}
+	return res
+}
+
+func (r *Registry) isDraining() bool {
+	r.mu.RLock()
+	fn := r.draining
+	r.mu.RUnlock()
+	return fn != nil && fn()
+}
+
+// LivenessHandler /healthz
+func (r *Registry) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeReport(w, r.Live())
+	})
+}
+
+// ReadinessHandler /readyz
+func (r *Registry) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writeReport(w, r.Ready(req.Context()))
+	})
+}
+
+// StatusHandler /readyz 的公开版本：只返回状态码（200 / 503）及整体状态，不暴露各依赖的错误信息及详情
+// 完整报告（ReadinessHandler）应只在内网端口提供
+func (r *Registry) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := r.Ready(req.Context())
+		writeReport(w, Report{Status: report.Status, Draining: report.Draining})
+	})
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != StatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+}
+
+// MQ 检查 MQ 连接及订阅状态：连接未就绪或任一订阅断开即失败
+// m 按需实现 mq.StateWatcher / mq.SubscriptionReporter / mq.StatsReporter
+func MQ(m any) Check {
+	return func(context.Context) (any, error) {
+		details := make(map[string]any)
+		var err error
+
+		if w, ok := m.(mq.StateWatcher); ok {
+			state := w.State()
+			details["state"] = state.String()
+			if state != mq.StateConnected {
+				err = fmt.Errorf("mq %s", state)
+			}
+		}
+
+		if r, ok := m.(mq.SubscriptionReporter); ok {
+			subs := r.Subscriptions()
+			lost := 0
+			for _, sub := range subs {
+				if !sub.Healthy {
+					lost++
+				}
+			}
+			details["subscriptions"] = subs
+			if lost > 0 && err == nil {
+				err = fmt.Errorf("%d of %d subscriptions lost", lost, len(subs))
+			}
+		}
+
+		if r, ok := m.(mq.StatsReporter); ok {
+			st := r.Stats()
+			details["stats"] = map[string]uint64{
+				"delivered":  st.Delivered,
+				"delayed":    st.Delayed,
+				"dropped":    st.Dropped,
+				"spilled":    st.Spilled,
+				"reconnects": st.Reconnects,
+			}
+		}
+		return details, err
+	}
+}
@@ -0,0 +1,124 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// Config 日志配置（logger.yaml 的 logger 节）
+type Config struct {
+	Level       string         `mapstructure:"level"`        // DEBUG / INFO / WARN / ERROR
+	Format      string         `mapstructure:"format"`       // text（默认）/ json
+	EnabledTags []string       `mapstructure:"enabled_tags"` // 启用的标签，未列出的标签禁用
+	Sampling    SamplingConfig `mapstructure:"sampling"`     // 高频日志采样，未配置时不采样
+}
+
+// Apply 应用日志配置；Level / Format 为空时保持不变，EnabledTags 为 nil 时保持标签不变，Sampling 整体替换
+func (l *Logger) Apply(cfg Config) error {
+	level := l.Level()
+	if cfg.Level != "" {
+		var err error
+		if level, err = ParseLevel(cfg.Level); err != nil {
+			return err
+		}
+	}
+	format := cfg.Format
+	if format != "" {
+		var ok bool
+		if format, ok = ParseFormat(format); !ok {
+			return fmt.Errorf("unknown log format %q (text / json)", cfg.Format)
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.minLevel = level
+	if format != "" && format != l.format {
+		l.format = format
+		l.handler = newHandler(l.out, format)
+	}
+	l.sampler = newSampler(cfg.Sampling)
+	if cfg.EnabledTags != nil {
+		l.enabledTags = make(map[LogTag]bool, len(cfg.EnabledTags))
+		for _, tag := range cfg.EnabledTags {
+			l.enabledTags[LogTag(strings.ToUpper(strings.TrimSpace(tag)))] = true
+		}
+	}
+	return nil
+}
+
+// Load 加载日志配置并在文件变化时热更新
+// 文件：环境变量 LOGGER_CONFIG 指定的路径，否则在 configs/、当前目录查找 {name}.yaml（不存在时只使用环境变量）
+// 环境变量：LOGGER_LEVEL、LOGGER_FORMAT、LOGGER_ENABLED_TAGS（逗号分隔）优先于文件
+func (l *Logger) Load(name string) error {
+	v := viper.New()
+	if path := os.Getenv("LOGGER_CONFIG"); path != "" {
+		v.SetConfigFile(path)
+	} else {
+		v.SetConfigName(name)
+		v.SetConfigType("yaml")
+		v.AddConfigPath("configs")
+		v.AddConfigPath(".")
+	}
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.BindEnv("logger.level")
+	v.BindEnv("logger.format")
+	v.BindEnv("logger.enabled_tags")
+
+	found := true
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if !errors.As(err, &notFound) {
+			return fmt.Errorf("read logger config: %w", err)
+		}
+		found = false
+	}
+	if err := l.applyViper(v); err != nil {
+		return err
+	}
+	file := "-"
+	if found {
+		file = v.ConfigFileUsed()
+	}
+	l.system(INFO, "Log config loaded", "file", file, "level", l.Level().String(), "tags", l.enabledTagList())
+
+	if found {
+		v.OnConfigChange(func(e fsnotify.Event) {
+			if err := l.applyViper(v); err != nil {
+				l.system(WARN, "Log config reload rejected", "file", e.Name, "error", err)
+				return
+			}
+			l.system(INFO, "Log config reloaded", "level", l.Level().String(), "tags", l.enabledTagList())
+		})
+		v.WatchConfig()
+	}
+	return nil
+}
+
+func (l *Logger) applyViper(v *viper.Viper) error {
+	var file struct {
+		Logger Config `mapstructure:"logger"`
+	}
+	if err := v.Unmarshal(&file); err != nil {
+		return fmt.Errorf("parse logger config: %w", err)
+	}
+	return l.Apply(file.Logger)
+}
+
+// enabledTagList 已启用的标签（用于日志输出）
+func (l *Logger) enabledTagList() []LogTag {
+	var tags []LogTag
+	for tag, enabled := range l.Tags() {
+		if enabled {
+			tags = append(tags, tag)
+		}
+	}
+	slices.Sort(tags)
+	return tags
+}
@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// 输出格式
+const (
+	FormatText = "text" // key=value 文本
+	FormatJSON = "json" // 每行一个 JSON 对象
+)
+
+// 结构化日志的公共字段名
+const (
+	KeySessionID  = "session_id"
+	KeyUserID     = "user_id"
+	KeyGameID     = "game_id"
+	KeyRoute      = "route"
+	KeySeq        = "seq"
+	KeyTraceID    = "trace_id"
+	KeySuppressed = "sampled_out" // 上一条输出之后因采样被丢弃的同类日志条数
+)
+
+func SessionID(id string) slog.Attr { return slog.String(KeySessionID, id) }
+func UserID(id int32) slog.Attr     { return slog.Int64(KeyUserID, int64(id)) }
+func GameID(id string) slog.Attr    { return slog.String(KeyGameID, id) }
+func Seq(seq uint32) slog.Attr      { return slog.Uint64(KeySeq, uint64(seq)) }
+
+// Route 路由类型（如 protocol.RouteType，按 String() 输出）
+func Route(route interface{ String() string }) slog.Attr {
+	return slog.String(KeyRoute, route.String())
+}
+
+// ParseFormat 解析输出格式: text / json（不区分大小写）
+func ParseFormat(s string) (string, bool) {
+	switch strings.ToLower(s) {
+	case FormatText:
+		return FormatText, true
+	case FormatJSON:
+		return FormatJSON, true
+	default:
+		return "", false
+	}
+}
+
+// newHandler 级别过滤由 Logger 负责，Handler 接受所有级别
+func newHandler(w io.Writer, format string) slog.Handler {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	if format == FormatJSON {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
@@ -0,0 +1,246 @@
+// Package logging 各服务共用的日志实现：基于 log/slog 输出，按标签过滤、可对高频日志采样，配置文件热更新；
+// 标签列表及全局 Logger 由各服务的 logger 包定义
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"game-pkg/tracing"
+)
+
+// LogLevel 定义日志级别
+type LogLevel int
+
+const (
+	DEBUG LogLevel = iota
+	INFO
+	WARN
+	ERROR
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case DEBUG:
+		return "debug"
+	case INFO:
+		return "info"
+	case WARN:
+		return "warn"
+	case ERROR:
+		return "error"
+	default:
+		return fmt.Sprintf("LogLevel(%d)", int(l))
+	}
+}
+
+func (l LogLevel) slogLevel() slog.Level {
+	switch l {
+	case DEBUG:
+		return slog.LevelDebug
+	case WARN:
+		return slog.LevelWarn
+	case ERROR:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// ParseLevel 解析日志级别: debug / info / warn / error（不区分大小写）
+func ParseLevel(s string) (LogLevel, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return DEBUG, nil
+	case "info":
+		return INFO, nil
+	case "warn", "warning":
+		return WARN, nil
+	case "error":
+		return ERROR, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q (debug / info / warn / error)", s)
+	}
+}
+
+// LogTag 日志标签，各服务定义自己的标签列表
+type LogTag string
+
+// Logger 可配置的日志记录器，基于 log/slog 输出（text / json），按标签过滤并可对高频日志采样
+type Logger struct {
+	mu          sync.RWMutex
+	enabledTags map[LogTag]bool
+	known       []LogTag // 预定义标签
+	minLevel    LogLevel
+	out         io.Writer
+	format      string
+	handler     slog.Handler
+	sampler     *sampler // nil 表示不采样
+}
+
+// New 创建输出到 stdout 的 Logger（INFO 级别、text 格式）：known 为服务预定义的全部标签（Tags 中列出），
+// defaults 为默认启用的标签（未加载配置文件时生效）
+func New(known []LogTag, defaults ...LogTag) *Logger {
+	l := &Logger{
+		enabledTags: make(map[LogTag]bool),
+		known:       known,
+		minLevel:    INFO,
+		out:         os.Stdout,
+		format:      FormatText,
+	}
+	l.handler = newHandler(l.out, l.format)
+	for _, tag := range defaults {
+		l.enabledTags[tag] = true
+	}
+	return l
+}
+
+// EnableTag 启用指定标签的日志
+func (l *Logger) EnableTag(tag LogTag) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.enabledTags[tag] = true
+}
+
+// DisableTag 禁用指定标签的日志
+func (l *Logger) DisableTag(tag LogTag) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.enabledTags[tag] = false
+}
+
+// IsTagEnabled 检查标签是否启用
+func (l *Logger) IsTagEnabled(tag LogTag) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.enabledTags[tag]
+}
+
+// SetLevel 设置最小日志级别
+func (l *Logger) SetLevel(level LogLevel) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.minLevel = level
+}
+
+// Level 返回当前最小日志级别
+func (l *Logger) Level() LogLevel {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.minLevel
+}
+
+// Tags 返回所有标签（含预定义但未设置的标签）的启用状态
+func (l *Logger) Tags() map[LogTag]bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	tags := make(map[LogTag]bool, len(l.known))
+	for _, tag := range l.known {
+		tags[tag] = false
+	}
+	for tag, enabled := range l.enabledTags {
+		tags[tag] = enabled
+	}
+	return tags
+}
+
+// output 标签已启用且级别不低于最小级别时返回当前 Handler 及采样器（级别与标签可在运行时修改）
+func (l *Logger) output(tag LogTag, level LogLevel) (slog.Handler, *sampler, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if !l.enabledTags[tag] || l.minLevel > level {
+		return nil, nil, false
+	}
+	return l.handler, l.sampler, true
+}
+
+// Enabled 该标签及级别的日志是否输出，用于在热路径上跳过参数构造
+func (l *Logger) Enabled(tag LogTag, level LogLevel) bool {
+	_, _, ok := l.output(tag, level)
+	return ok
+}
+
+// Log 输出结构化日志：msg 应为固定文本（同时作为采样 key），变量通过 args 以键值对或 slog.Attr 传入；
+// ctx 中有被采样的 Span 时附带 trace_id
+func (l *Logger) Log(ctx context.Context, level LogLevel, tag LogTag, msg string, args ...any) {
+	h, s, ok := l.output(tag, level)
+	if !ok {
+		return
+	}
+	l.emit(ctx, h, s, level, tag, msg, msg, args)
+}
+
+// logf 输出 printf 风格日志，以格式串作为采样 key（仅在输出时格式化）
+func (l *Logger) logf(level LogLevel, tag LogTag, format string, v []interface{}) {
+	h, s, ok := l.output(tag, level)
+	if !ok {
+		return
+	}
+	l.emit(context.Background(), h, s, level, tag, format, fmt.Sprintf(format, v...), nil)
+}
+
+func (l *Logger) emit(ctx context.Context, h slog.Handler, s *sampler, level LogLevel, tag LogTag, key, msg string, args []any) {
+	var suppressed uint64
+	if s != nil {
+		ok, n := s.allow(sampleKey{level, tag, key})
+		if !ok {
+			return
+		}
+		suppressed = n
+	}
+
+	rec := slog.NewRecord(time.Now(), level.slogLevel(), msg, 0)
+	rec.AddAttrs(slog.String("tag", string(tag)))
+	if traceID, _, ok := tracing.IDs(ctx); ok {
+		rec.AddAttrs(slog.String(KeyTraceID, traceID))
+	}
+	rec.Add(args...)
+	if suppressed > 0 {
+		rec.AddAttrs(slog.Uint64(KeySuppressed, suppressed))
+	}
+	h.Handle(ctx, rec)
+}
+
+// system 输出日志模块自身的消息（如配置加载），不受标签、级别及采样限制
+func (l *Logger) system(level LogLevel, msg string, args ...any) {
+	l.mu.RLock()
+	h := l.handler
+	l.mu.RUnlock()
+	l.emit(context.Background(), h, nil, level, "LOGGER", msg, msg, args)
+}
+
+// Debug 输出调试日志
+func (l *Logger) Debug(tag LogTag, format string, v ...interface{}) {
+	l.logf(DEBUG, tag, format, v)
+}
+
+// Info 输出信息日志
+func (l *Logger) Info(tag LogTag, format string, v ...interface{}) {
+	l.logf(INFO, tag, format, v)
+}
+
+// Warn 输出警告日志
+func (l *Logger) Warn(tag LogTag, format string, v ...interface{}) {
+	l.logf(WARN, tag, format, v)
+}
+
+// Error 输出错误日志
+func (l *Logger) Error(tag LogTag, format string, v ...interface{}) {
+	l.logf(ERROR, tag, format, v)
+}
+
+// Fatal 输出错误日志后退出进程（退出码 1），不受标签、级别及采样限制
+func (l *Logger) Fatal(tag LogTag, format string, v ...interface{}) {
+	l.mu.RLock()
+	h := l.handler
+	l.mu.RUnlock()
+	msg := fmt.Sprintf(format, v...)
+	l.emit(context.Background(), h, nil, ERROR, tag, msg, msg, nil)
+	os.Exit(1)
+}
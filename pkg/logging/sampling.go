@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"sync"
+	"time"
+)
+
+// SamplingConfig 高频日志采样：同一条日志（级别 + 标签 + 消息模板）在每个 Interval 内前 First 条全部输出，
+// 之后每 Thereafter 条输出 1 条，并以 sampled_out 字段附带期间被丢弃的条数
+type SamplingConfig struct {
+	Interval   time.Duration `mapstructure:"interval"`   // 采样周期，默认 1s
+	First      int           `mapstructure:"first"`      // <= 0 不采样
+	Thereafter int           `mapstructure:"thereafter"` // 0 表示超出 First 后全部丢弃
+}
+
+type sampleKey struct {
+	level LogLevel
+	tag   LogTag
+	msg   string
+}
+
+type sampleCounter struct {
+	mu          sync.Mutex
+	windowStart time.Time
+	n           int
+	suppressed  uint64
+}
+
+type sampler struct {
+	cfg      SamplingConfig
+	counters sync.Map // sampleKey -> *sampleCounter，key 数量受限于代码中的消息模板数
+}
+
+// newSampler First <= 0 时返回 nil（不采样）
+func newSampler(cfg SamplingConfig) *sampler {
+	if cfg.First <= 0 {
+		return nil
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	return &sampler{cfg: cfg}
+}
+
+// allow 返回是否输出，以及输出时附带的被丢弃条数
+func (s *sampler) allow(key sampleKey) (bool, uint64) {
+	v, ok := s.counters.Load(key)
+	if !ok {
+		v, _ = s.counters.LoadOrStore(key, &sampleCounter{})
+	}
+	c := v.(*sampleCounter)
+
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.windowStart) >= s.cfg.Interval {
+		c.windowStart = now
+		c.n = 0
+	}
+	c.n++
+	if c.n <= s.cfg.First || (s.cfg.Thereafter > 0 && (c.n-s.cfg.First)%s.cfg.Thereafter == 0) {
+		suppressed := c.suppressed
+		c.suppressed = 0
+		return true, suppressed
+	}
+	c.suppressed++
+	return false, 0
+}
@@ -0,0 +1,262 @@
+package mq
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OverflowPolicy 订阅缓冲区满时的处理策略
+type OverflowPolicy int
+
+const (
+	OverflowBlock      OverflowPolicy = iota // 阻塞等待，超过 BlockTimeout 后丢弃
+	OverflowDropNewest                       // 丢弃新到达的消息
+	OverflowDropOldest                       // 丢弃缓冲区中最旧的消息
+	OverflowSpill                            // 溢写到磁盘，消费者追上后按序回放
+)
+
+const (
+	DefaultBufferSize   = 100
+	DefaultBlockTimeout = time.Second
+)
+
+// ParseOverflowPolicy 解析配置中的策略名称（block / drop_newest / drop_oldest / spill）
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	switch s {
+	case "", "block":
+		return OverflowBlock, nil
+	case "drop_newest":
+		return OverflowDropNewest, nil
+	case "drop_oldest":
+		return OverflowDropOldest, nil
+	case "spill":
+		return OverflowSpill, nil
+	default:
+		return OverflowBlock, fmt.Errorf("unknown overflow policy: %q", s)
+	}
+}
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "block"
+	case OverflowDropNewest:
+		return "drop_newest"
+	case OverflowDropOldest:
+		return "drop_oldest"
+	case OverflowSpill:
+		return "spill"
+	default:
+		return fmt.Sprintf("OverflowPolicy(%d)", int(p))
+	}
+}
+
+// Stats 订阅侧投递计数器快照
+type Stats struct {
+	Delivered uint64 // 成功放入订阅缓冲区的消息数
+	Delayed   uint64 // 缓冲区满、等待后才投递的消息数
+	Dropped   uint64 // 因缓冲区满（或溢写失败）被丢弃的消息数
+	Spilled   uint64 // 溢写到磁盘的消息数
+
+	Reconnects uint64 // 断线后成功重连（重新订阅）的次数
+}
+
+// StatsReporter 由支持背压统计的 MQ 实现
+type StatsReporter interface {
+	Stats() Stats
+}
+
+type stats struct {
+	delivered atomic.Uint64
+	delayed   atomic.Uint64
+	dropped   atomic.Uint64
+	spilled   atomic.Uint64
+}
+
+func (s *stats) snapshot() Stats {
+	return Stats{
+		Delivered: s.delivered.Load(),
+		Delayed:   s.delayed.Load(),
+		Dropped:   s.dropped.Load(),
+		Spilled:   s.spilled.Load(),
+	}
+}
+
+// inbox 订阅缓冲区：按 OverflowPolicy 将消息非阻塞（或有限阻塞）地放入 ch，
+// 保证 MQ 客户端的回调 goroutine 不会被慢消费者无限期卡住
+type inbox struct {
+	topic string
+	ch    chan *Message
+	opts  SubscribeOptions
+	stats *stats
+	spill *spillQueue
+
+	// mu 保护 ch 的关闭：push 持读锁，close 持写锁，避免取消订阅后迟到的回调向已关闭的 ch 发送
+	mu        sync.RWMutex
+	closed    bool
+	closeOnce sync.Once
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newInbox(topic string, o SubscribeOptions, st *stats) (*inbox, error) {
+	size := o.BufferSize
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	if o.BlockTimeout <= 0 {
+		o.BlockTimeout = DefaultBlockTimeout
+	}
+
+	in := &inbox{
+		topic: topic,
+		ch:    make(chan *Message, size),
+		opts:  o,
+		stats: st,
+		done:  make(chan struct{}),
+	}
+
+	if o.Overflow == OverflowSpill {
+		q, err := newSpillQueue(o.SpillDir)
+		if err != nil {
+			return nil, err
+		}
+		in.spill = q
+		in.wg.Add(1)
+		go in.drainSpill()
+	}
+	return in, nil
+}
+
+// push 投递一条消息，由订阅的回调 goroutine 调用
+func (in *inbox) push(msg *Message) {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	if in.closed {
+		return
+	}
+
+	// 溢写队列非空时，新消息也必须排在队尾以保证顺序
+	if in.spill != nil && in.spill.pending() > 0 {
+		in.spillMessage(msg)
+		return
+	}
+
+	select {
+	case in.ch <- msg:
+		in.stats.delivered.Add(1)
+		return
+	default:
+	}
+
+	switch in.opts.Overflow {
+	case OverflowDropNewest:
+		in.drop(msg)
+
+	case OverflowDropOldest:
+		for {
+			select {
+			case <-in.ch:
+				in.stats.dropped.Add(1)
+			default:
+			}
+			select {
+			case in.ch <- msg:
+				in.stats.delivered.Add(1)
+				return
+			default:
+			}
+		}
+
+	case OverflowSpill:
+		in.spillMessage(msg)
+
+	default:
+		timer := time.NewTimer(in.opts.BlockTimeout)
+		defer timer.Stop()
+		select {
+		case in.ch <- msg:
+			in.stats.delivered.Add(1)
+			in.stats.delayed.Add(1)
+		case <-timer.C:
+			in.drop(msg)
+		case <-in.done:
+		}
+	}
+}
+
+func (in *inbox) drop(msg *Message) {
+	n := in.stats.dropped.Add(1)
+	// 避免在持续拥塞时刷屏，只记录前几条和之后每 1000 条
+	if n <= 10 || n%1000 == 0 {
+		log.Printf("[MQ] Subscriber buffer full, message dropped | Topic: %s, Policy: %s, TotalDropped: %d",
+			msg.Topic, in.opts.Overflow, n)
+	}
+}
+
+func (in *inbox) spillMessage(msg *Message) {
+	if err := in.spill.append(msg); err != nil {
+		log.Printf("[MQ] Spill failed | Topic: %s, Error: %v", msg.Topic, err)
+		in.drop(msg)
+		return
+	}
+	in.stats.spilled.Add(1)
+}
+
+// drainSpill 将溢写的消息按序回放到 ch
+func (in *inbox) drainSpill() {
+	defer in.wg.Done()
+	for {
+		select {
+		case <-in.spill.notify:
+		case <-in.done:
+			return
+		}
+
+		for {
+			msg, err := in.spill.peek()
+			if err != nil {
+				log.Printf("[MQ] Spill read failed, discarding spill file | Topic: %s, Error: %v", in.topic, err)
+				in.stats.dropped.Add(uint64(in.spill.reset()))
+				break
+			}
+			if msg == nil {
+				break
+			}
+			select {
+			case in.ch <- msg:
+				in.spill.ack()
+				in.stats.delivered.Add(1)
+			case <-in.done:
+				return
+			}
+		}
+	}
+}
+
+// close 停止回放并关闭消息通道；之后的 push 会被忽略，重复调用无副作用
+func (in *inbox) close() {
+	in.closeOnce.Do(in.doClose)
+}
+
+func (in *inbox) doClose() {
+	// 先关闭 done 让阻塞中的 push 退出，再获取写锁
+	close(in.done)
+	in.wg.Wait()
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.closed = true
+	if in.spill != nil {
+		if n := in.spill.pending(); n > 0 {
+			log.Printf("[MQ] Discarding %d spilled messages on close | Topic: %s", n, in.topic)
+			in.stats.dropped.Add(uint64(n))
+		}
+		in.spill.close()
+	}
+	close(in.ch)
+}
@@ -0,0 +1,108 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// permanentError 标记不可重试的错误（如消息无法解析）
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 将错误标记为不可重试，RPCServer 会直接将消息转入死信
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent 判断错误是否被标记为不可重试
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+// RetryPolicy Handler 返回可重试错误时的重试策略
+type RetryPolicy struct {
+	MaxAttempts    int           // 总尝试次数（含首次），<= 1 表示不重试
+	InitialBackoff time.Duration // 首次重试前的等待时间
+	MaxBackoff     time.Duration // 退避上限
+	Multiplier     float64       // 每次重试的退避倍数，<= 1 时按 2 处理
+}
+
+// Backoff 返回第 attempt 次尝试失败后（attempt 从 1 开始）的等待时间
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	mult := p.Multiplier
+	if mult <= 1 {
+		mult = 2
+	}
+	d := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		d *= mult
+		if p.MaxBackoff > 0 && d >= float64(p.MaxBackoff) {
+			return p.MaxBackoff
+		}
+	}
+	return time.Duration(d)
+}
+
+// DeadLetter 死信消息（JSON 编码后发布到死信 Topic）
+type DeadLetter struct {
+	Topic         string    `json:"topic"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	ReplyTo       string    `json:"reply_to,omitempty"`
+	Error         string    `json:"error"`
+	Permanent     bool      `json:"permanent"`
+	Attempts      int       `json:"attempts"`
+	FailedAt      time.Time `json:"failed_at"`
+	Payload       []byte    `json:"payload"` // 原始消息（含 RPC 信封）
+}
+
+// Encode 编码死信
+func (d *DeadLetter) Encode() ([]byte, error) {
+	return json.Marshal(d)
+}
+
+// DecodeDeadLetter 解码死信
+func DecodeDeadLetter(data []byte) (*DeadLetter, error) {
+	var d DeadLetter
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// FailureHandler 消息最终处理失败（重试耗尽或不可重试）后的回调，
+// 用于向请求发起方发送失败通知
+type FailureHandler func(ctx context.Context, req *Request, err error)
+
+// ServerOption 配置 RPCServer
+type ServerOption func(*RPCServer)
+
+// WithDeadLetterTopic 处理失败的消息发布到指定死信 Topic
+func WithDeadLetterTopic(topic string) ServerOption {
+	return func(s *RPCServer) {
+		s.deadLetterTopic = topic
+	}
+}
+
+// WithRetryPolicy 设置可重试错误的重试策略
+func WithRetryPolicy(p RetryPolicy) ServerOption {
+	return func(s *RPCServer) {
+		s.retry.Store(&p)
+	}
+}
+
+// WithFailureHandler 设置最终失败回调
+func WithFailureHandler(fn FailureHandler) ServerOption {
+	return func(s *RPCServer) {
+		s.onFailure = fn
+	}
+}
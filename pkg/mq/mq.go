@@ -1,5 +1,7 @@
 package mq
 
+import "time"
+
 // Message represents a message in the queue
 type Message struct {
 	Topic   string
@@ -13,6 +15,95 @@ type Producer interface {
 
 // Consumer defines interface for subscribing to topics
 type Consumer interface {
-	Subscribe(topic string) (<-chan *Message, error)
+	Subscribe(topic string, opts ...SubscribeOption) (<-chan *Message, error)
 	Close() error
 }
+
+// Unsubscriber 由支持单独取消订阅的 Consumer 实现；
+// Unsubscribe 停止接收 topic 的消息并关闭 Subscribe 返回的 channel（已缓冲的消息仍可读出）
+type Unsubscriber interface {
+	Unsubscribe(topic string) error
+}
+
+// SubscribeOptions holds per-subscription settings
+type SubscribeOptions struct {
+	// QueueGroup 非空时，同组的订阅者共享消息（每条消息只投递给组内一个成员）
+	QueueGroup string
+
+	// BufferSize 订阅通道容量，<= 0 时使用 DefaultBufferSize
+	BufferSize int
+	// Overflow 缓冲区满时的处理策略
+	Overflow OverflowPolicy
+	// BlockTimeout OverflowBlock 策略下的最长等待时间，<= 0 时使用 DefaultBlockTimeout
+	BlockTimeout time.Duration
+	// SpillDir OverflowSpill 策略下溢写文件所在目录，空则使用系统临时目录
+	SpillDir string
+}
+
+// SubscribeOption configures a subscription
+type SubscribeOption func(*SubscribeOptions)
+
+// WithQueueGroup 以共享订阅方式加入指定消费组（MQTT: $share/{group}/{topic}）
+func WithQueueGroup(group string) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.QueueGroup = group
+	}
+}
+
+// WithBufferSize 设置订阅通道容量
+func WithBufferSize(size int) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.BufferSize = size
+	}
+}
+
+// WithOverflowPolicy 设置缓冲区满时的处理策略
+func WithOverflowPolicy(policy OverflowPolicy) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.Overflow = policy
+	}
+}
+
+// WithBlockTimeout 设置 OverflowBlock 策略的最长等待时间
+func WithBlockTimeout(d time.Duration) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.BlockTimeout = d
+	}
+}
+
+// WithSpillDir 设置 OverflowSpill 策略的溢写目录
+func WithSpillDir(dir string) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.SpillDir = dir
+	}
+}
+
+// SubscribeConfig MQ 订阅缓冲配置，供各服务嵌入自身配置（mq.subscribe）
+type SubscribeConfig struct {
+	BufferSize   int           `mapstructure:"buffer_size"`   // 订阅通道容量
+	Overflow     string        `mapstructure:"overflow"`      // block / drop_newest / drop_oldest / spill
+	BlockTimeout time.Duration `mapstructure:"block_timeout"` // block 策略最长等待时间
+	SpillDir     string        `mapstructure:"spill_dir"`     // spill 策略溢写目录
+}
+
+// Options 根据配置构造订阅选项，Overflow 非法时返回错误
+func (c SubscribeConfig) Options() ([]SubscribeOption, error) {
+	policy, err := ParseOverflowPolicy(c.Overflow)
+	if err != nil {
+		return nil, err
+	}
+	return []SubscribeOption{
+		WithBufferSize(c.BufferSize),
+		WithOverflowPolicy(policy),
+		WithBlockTimeout(c.BlockTimeout),
+		WithSpillDir(c.SpillDir),
+	}, nil
+}
+
+func applySubscribeOptions(opts []SubscribeOption) SubscribeOptions {
+	var o SubscribeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -2,27 +2,53 @@ package mq
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
+	"sync"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
+// redisHealthCheckInterval 订阅连接空闲超过该时间后发送 PING 探测
+const redisHealthCheckInterval = 15 * time.Second
+
 type RedisMQ struct {
-	client *redis.Client
-	ctx    context.Context
+	client    *redis.Client
+	ctx       context.Context
+	cancel    context.CancelFunc
+	stats     stats
+	tracker   stateTracker
+	reconnect RetryPolicy
+
+	mu   sync.Mutex
+	subs map[string]*redisSub // Topic -> 订阅，用于取消订阅
+}
+
+// redisSub 单个订阅的监管 goroutine 控制
+type redisSub struct {
 	cancel context.CancelFunc
+	done   chan struct{} // supervise 退出（消息通道已关闭）后关闭
 }
 
 func NewRedisMQ(client *redis.Client) *RedisMQ {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &RedisMQ{
-		client: client,
-		ctx:    ctx,
-		cancel: cancel,
+		client:    client,
+		ctx:       ctx,
+		cancel:    cancel,
+		reconnect: DefaultReconnectPolicy,
+		subs:      make(map[string]*redisSub),
 	}
 }
 
+// SetReconnectPolicy 设置订阅断开后的重连退避策略
+func (r *RedisMQ) SetReconnectPolicy(p RetryPolicy) {
+	r.reconnect = p
+}
+
 // Publish sends data to a Redis channel
 func (r *RedisMQ) Publish(topic string, payload []byte) error {
 	err := r.client.Publish(r.ctx, topic, payload).Err()
@@ -33,45 +59,199 @@ func (r *RedisMQ) Publish(topic string, payload []byte) error {
 }
 
 // Subscribe listens to a Redis channel and returns a read-only channel of messages
-func (r *RedisMQ) Subscribe(topic string) (<-chan *Message, error) {
-	pubsub := r.client.Subscribe(r.ctx, topic)
+// 带通配符的 Topic 使用 PSUBSCRIBE；Redis Pub/Sub 不支持消费组，QueueGroup 会被忽略（每个实例都会收到消息）
+// 订阅建立后由后台 goroutine 监管：连接断开时按退避策略重连并重新订阅，返回的 channel 只在 Close 后关闭
+func (r *RedisMQ) Subscribe(topic string, opts ...SubscribeOption) (<-chan *Message, error) {
+	o := applySubscribeOptions(opts)
+	if o.QueueGroup != "" {
+		log.Printf("[RedisMQ] Queue group %q ignored for topic %s: redis pub/sub has no shared subscriptions", o.QueueGroup, topic)
+	}
+
+	wildcard := IsWildcard(topic)
+	ctx, cancel := context.WithCancel(r.ctx)
 
 	// Check connection
-	_, err := pubsub.Receive(r.ctx)
+	pubsub, err := r.subscribe(ctx, topic, wildcard)
 	if err != nil {
+		cancel()
 		return nil, fmt.Errorf("redis subscribe error: %w", err)
 	}
 
-	msgChan := make(chan *Message, 100) // Buffer for safety
+	in, err := newInbox(topic, o, &r.stats)
+	if err != nil {
+		cancel()
+		pubsub.Close()
+		return nil, fmt.Errorf("redis subscribe error: %w", err)
+	}
+
+	if r.tracker.State() == StateConnecting {
+		r.tracker.set(StateConnected, topic, nil)
+	}
+
+	sub := &redisSub{cancel: cancel, done: make(chan struct{})}
+	r.mu.Lock()
+	r.subs[topic] = sub
+	r.mu.Unlock()
 
-	// Start a goroutine to bridge Redis PubSub to our channel
 	go func() {
-		defer close(msgChan)
-		defer pubsub.Close()
-
-		ch := pubsub.Channel()
-		for {
-			select {
-			case redisMsg, ok := <-ch:
-				if !ok {
-					// Use standard log here as we don't want to depend on internal/logger from pkg
-					log.Printf("[RedisMQ] Channel closed for topic: %s", topic)
-					return
-				}
-				msgChan <- &Message{
-					Topic:   topic,
-					Payload: []byte(redisMsg.Payload),
+		defer close(sub.done)
+		r.supervise(ctx, topic, wildcard, pubsub, in)
+	}()
+
+	return in.ch, nil
+}
+
+func (r *RedisMQ) subscribe(ctx context.Context, topic string, wildcard bool) (*redis.PubSub, error) {
+	var pubsub *redis.PubSub
+	if wildcard {
+		pubsub = r.client.PSubscribe(ctx, toRedisPattern(topic))
+	} else {
+		pubsub = r.client.Subscribe(ctx, topic)
+	}
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+	return pubsub, nil
+}
+
+// supervise bridges Redis PubSub to our channel and resubscribes on connection loss
+// ctx 在 Close 或 Unsubscribe 时取消
+func (r *RedisMQ) supervise(ctx context.Context, topic string, wildcard bool, pubsub *redis.PubSub, in *inbox) {
+	defer in.close()
+
+	for {
+		err := r.receive(ctx, topic, wildcard, pubsub, in)
+		pubsub.Close()
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Use standard log here as we don't want to depend on internal/logger from pkg
+		log.Printf("[RedisMQ] Subscription lost for topic %s: %v", topic, err)
+		r.tracker.subscriptionLost(topic, err)
+
+		if pubsub = r.resubscribe(ctx, topic, wildcard); pubsub == nil {
+			r.tracker.subscriptionRemoved(topic)
+			return
+		}
+		r.tracker.subscriptionRestored(topic)
+		log.Printf("[RedisMQ] Resubscribed to topic %s", topic)
+	}
+}
+
+// receive 读取订阅消息直到连接出错或 MQ 关闭
+func (r *RedisMQ) receive(ctx context.Context, topic string, wildcard bool, pubsub *redis.PubSub, in *inbox) error {
+	for {
+		msg, err := pubsub.ReceiveTimeout(ctx, redisHealthCheckInterval)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				// 空闲超时，PING 探测连接是否仍然可用
+				if err := pubsub.Ping(ctx); err != nil {
+					return err
 				}
-			case <-r.ctx.Done():
-				return
+				continue
 			}
+			return err
 		}
-	}()
 
-	return msgChan, nil
+		redisMsg, ok := msg.(*redis.Message)
+		if !ok {
+			// *redis.Subscription / *redis.Pong
+			continue
+		}
+		if wildcard && !MatchTopic(topic, redisMsg.Channel) {
+			continue
+		}
+		in.push(&Message{
+			Topic:   redisMsg.Channel,
+			Payload: []byte(redisMsg.Payload),
+		})
+	}
+}
+
+// resubscribe 按退避策略重试订阅，MQ 关闭时返回 nil
+func (r *RedisMQ) resubscribe(ctx context.Context, topic string, wildcard bool) *redis.PubSub {
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-time.After(r.reconnect.Backoff(attempt)):
+		case <-ctx.Done():
+			return nil
+		}
+
+		pubsub, err := r.subscribe(ctx, topic, wildcard)
+		if err == nil {
+			return pubsub
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		log.Printf("[RedisMQ] Resubscribe attempt %d failed for topic %s: %v", attempt, topic, err)
+	}
+}
+
+// Unsubscribe 取消 topic 的订阅，等待监管 goroutine 退出并关闭消息通道
+func (r *RedisMQ) Unsubscribe(topic string) error {
+	r.mu.Lock()
+	sub, ok := r.subs[topic]
+	delete(r.subs, topic)
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("redis unsubscribe error: not subscribed to %s", topic)
+	}
+
+	sub.cancel()
+	<-sub.done
+	log.Printf("[RedisMQ] Unsubscribed from %s", topic)
+	return nil
+}
+
+// Stats 返回订阅侧投递计数
+func (r *RedisMQ) Stats() Stats {
+	s := r.stats.snapshot()
+	s.Reconnects = r.tracker.reconnects.Load()
+	return s
+}
+
+// State 返回订阅连接状态
+func (r *RedisMQ) State() ConnState {
+	return r.tracker.State()
+}
+
+// Subscriptions 返回所有订阅及其状态（断开中的订阅正在按退避策略重连）
+func (r *RedisMQ) Subscriptions() []SubscriptionState {
+	r.mu.Lock()
+	topics := make([]string, 0, len(r.subs))
+	for topic := range r.subs {
+		topics = append(topics, topic)
+	}
+	r.mu.Unlock()
+
+	closed := r.tracker.State() == StateClosed
+	states := make([]SubscriptionState, 0, len(topics))
+	for _, topic := range topics {
+		st := SubscriptionState{Topic: topic, Healthy: !closed}
+		if err := r.tracker.lostError(topic); err != nil {
+			st.Healthy = false
+			st.Error = err.Error()
+		}
+		states = append(states, st)
+	}
+	return states
+}
+
+// OnStateChange 注册连接状态变化回调
+func (r *RedisMQ) OnStateChange(fn func(StateEvent)) {
+	r.tracker.OnStateChange(fn)
 }
 
 func (r *RedisMQ) Close() error {
+	r.tracker.set(StateClosed, "", nil)
 	r.cancel()
 	return nil
 }
@@ -3,13 +3,27 @@ package mq
 import (
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
 type RobustMQ struct {
-	client mqtt.Client
+	client  mqtt.Client
+	stats   stats
+	tracker stateTracker
+
+	// 已建立的订阅（filter -> handler），重连后需要重新订阅（CleanSession 下 Broker 不保留订阅）
+	mu        sync.Mutex
+	subs      map[string]mqtt.MessageHandler
+	topics    map[string]robustSub // 逻辑 Topic -> 订阅，用于取消订阅
+	connected bool                 // 是否已完成过首次连接
+}
+
+type robustSub struct {
+	filter string
+	in     *inbox
 }
 
 type RobustMQConfig struct {
@@ -34,26 +48,67 @@ func NewRobustMQ(cfg *RobustMQConfig) *RobustMQ {
 		// log.Printf("[RobustMQ] Received unexpected message: %s from topic: %s", msg.Payload(), msg.Topic())
 	})
 
+	r := &RobustMQ{
+		subs:   make(map[string]mqtt.MessageHandler),
+		topics: make(map[string]robustSub),
+	}
+
 	opts.SetOnConnectHandler(func(client mqtt.Client) {
 		log.Println("✅ Connected to RobustMQ Broker")
+		r.onConnect(client)
 	})
 
 	opts.SetConnectionLostHandler(func(client mqtt.Client, err error) {
 		log.Printf("⚠️ RobustMQ Connection Lost: %v", err)
+		r.tracker.set(StateReconnecting, "", err)
 	})
 
-	client := mqtt.NewClient(opts)
-	if token := client.Connect(); token.Wait() && token.Error() != nil {
+	r.client = mqtt.NewClient(opts)
+	if token := r.client.Connect(); token.Wait() && token.Error() != nil {
 		log.Fatalf("Failed to connect to RobustMQ: %v", token.Error())
 	}
 
-	return &RobustMQ{client: client}
+	return r
+}
+
+// onConnect 首次连接直接标记为已连接；自动重连成功后重新订阅所有 Topic
+func (r *RobustMQ) onConnect(client mqtt.Client) {
+	r.mu.Lock()
+	reconnect := r.connected
+	r.connected = true
+	subs := make(map[string]mqtt.MessageHandler, len(r.subs))
+	for filter, handler := range r.subs {
+		subs[filter] = handler
+	}
+	r.mu.Unlock()
+
+	if !reconnect {
+		r.tracker.set(StateConnected, "", nil)
+		return
+	}
+
+	// 不能在连接回调中同步等待 token（会阻塞 paho 内部流程），放到独立 goroutine
+	go func() {
+		for filter, handler := range subs {
+			token := client.Subscribe(filter, 1, handler)
+			token.Wait()
+			if token.Error() != nil {
+				log.Printf("[RobustMQ] Resubscribe to %s failed: %v", filter, token.Error())
+				r.tracker.set(StateReconnecting, filter, token.Error())
+				return
+			}
+			log.Printf("[RobustMQ] Resubscribed to %s", filter)
+		}
+		r.tracker.reconnects.Add(1)
+		r.tracker.set(StateConnected, "", nil)
+	}()
 }
 
 // Publish sends data to a MQTT topic
+// 逻辑 Topic 中的 ':' 会被转换为 MQTT 层级分隔符 '/'
 func (r *RobustMQ) Publish(topic string, payload []byte) error {
 	// QoS 1: At least once
-	token := r.client.Publish(topic, 1, false, payload)
+	token := r.client.Publish(ToMQTTTopic(topic), 1, false, payload)
 	token.Wait()
 	if token.Error() != nil {
 		return fmt.Errorf("robustmq publish error: %w", token.Error())
@@ -62,29 +117,98 @@ func (r *RobustMQ) Publish(topic string, payload []byte) error {
 }
 
 // Subscribe listens to a MQTT topic and returns a read-only channel of messages
-func (r *RobustMQ) Subscribe(topic string) (<-chan *Message, error) {
-	msgChan := make(chan *Message, 100)
+// 支持 '+' / '#' 通配符；指定 QueueGroup 时使用共享订阅 $share/{group}/{topic} 实现组内负载均衡
+func (r *RobustMQ) Subscribe(topic string, opts ...SubscribeOption) (<-chan *Message, error) {
+	o := applySubscribeOptions(opts)
+	in, err := newInbox(topic, o, &r.stats)
+	if err != nil {
+		return nil, fmt.Errorf("robustmq subscribe error: %w", err)
+	}
 
-	// NOTE: In robustmq/mqtt, if you want shared subscription (load balancing),
-	// you typically use specific syntax like $share/group/topic.
-	// We will pass the topic as is, assuming the caller handles the group prefix if needed.
+	filter := ToMQTTTopic(topic)
+	if o.QueueGroup != "" {
+		filter = SharedTopic(o.QueueGroup, filter)
+	}
 
-	token := r.client.Subscribe(topic, 1, func(client mqtt.Client, msg mqtt.Message) {
-		msgChan <- &Message{
-			Topic:   msg.Topic(),
+	// 回调运行在 paho 内部 goroutine 中，push 按策略限时/非阻塞投递，避免卡住整个 MQTT 连接
+	handler := func(client mqtt.Client, msg mqtt.Message) {
+		in.push(&Message{
+			Topic:   FromMQTTTopic(msg.Topic()),
 			Payload: msg.Payload(),
-		}
-	})
+		})
+	}
+	token := r.client.Subscribe(filter, 1, handler)
 
 	token.Wait()
 	if token.Error() != nil {
+		in.close()
 		return nil, fmt.Errorf("robustmq subscribe error: %w", token.Error())
 	}
+	log.Printf("[RobustMQ] Subscribed to %s", filter)
+
+	r.mu.Lock()
+	r.subs[filter] = handler
+	r.topics[topic] = robustSub{filter: filter, in: in}
+	r.mu.Unlock()
+
+	return in.ch, nil
+}
+
+// Unsubscribe 取消 topic 的订阅并关闭对应的消息通道
+func (r *RobustMQ) Unsubscribe(topic string) error {
+	r.mu.Lock()
+	sub, ok := r.topics[topic]
+	if ok {
+		delete(r.topics, topic)
+		delete(r.subs, sub.filter)
+	}
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("robustmq unsubscribe error: not subscribed to %s", topic)
+	}
+
+	token := r.client.Unsubscribe(sub.filter)
+	token.Wait()
+	sub.in.close()
+	if token.Error() != nil {
+		return fmt.Errorf("robustmq unsubscribe error: %w", token.Error())
+	}
+	log.Printf("[RobustMQ] Unsubscribed from %s", sub.filter)
+	return nil
+}
+
+// Stats 返回订阅侧投递计数
+func (r *RobustMQ) Stats() Stats {
+	s := r.stats.snapshot()
+	s.Reconnects = r.tracker.reconnects.Load()
+	return s
+}
+
+// State 返回 MQTT 连接状态
+func (r *RobustMQ) State() ConnState {
+	return r.tracker.State()
+}
+
+// Subscriptions 返回所有订阅及其状态
+// MQTT 订阅依附于连接，连接断开或重新订阅未完成时所有订阅均视为不可用
+func (r *RobustMQ) Subscriptions() []SubscriptionState {
+	healthy := r.tracker.State() == StateConnected
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	states := make([]SubscriptionState, 0, len(r.topics))
+	for topic := range r.topics {
+		states = append(states, SubscriptionState{Topic: topic, Healthy: healthy})
+	}
+	return states
+}
 
-	return msgChan, nil
+// OnStateChange 注册连接状态变化回调
+func (r *RobustMQ) OnStateChange(fn func(StateEvent)) {
+	r.tracker.OnStateChange(fn)
 }
 
 func (r *RobustMQ) Close() error {
+	r.tracker.set(StateClosed, "", nil)
 	r.client.Disconnect(250)
 	return nil
 }
@@ -0,0 +1,462 @@
+package mq
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 请求/响应 (RPC) 信封格式:
+// +-------+---------+----------+-------+-----------+---------+--------+-------+---------+
+// | Magic | Version | CorrLen  | Corr  | ReplyLen  | ReplyTo | ErrLen |  Err  | Payload |
+// |(1byte)| (1byte) | (1 byte) | (变长) | (2 byte)  |  (变长)  |(2 byte)| (变长) |  (变长)  |
+// +-------+---------+----------+-------+-----------+---------+--------+-------+---------+
+//
+// Magic 0xFE 在 Protobuf 中对应非法的 wire type，因此不会与裸 Protobuf 消息混淆，
+// 未加信封的消息（fire-and-forget）仍可被 RPCServer 处理，只是不会回复。
+const (
+	envelopeMagic   byte = 0xFE
+	envelopeVersion byte = 1
+
+	// DefaultRPCTimeout ctx 未设置 deadline 时 Call 的默认超时
+	DefaultRPCTimeout = 5 * time.Second
+
+	maxRemoteErrorLen = 1024
+)
+
+var (
+	// ErrRPCTimeout Call 在超时前未收到响应
+	ErrRPCTimeout = errors.New("mq rpc: timeout waiting for reply")
+	// ErrRPCClosed RPCClient 已关闭
+	ErrRPCClosed = errors.New("mq rpc: client closed")
+)
+
+// RemoteError 服务端 Handler 返回的错误
+type RemoteError struct {
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return "mq rpc: remote error: " + e.Message
+}
+
+// Envelope RPC 请求/响应信封
+type Envelope struct {
+	CorrelationID string
+	ReplyTo       string
+	Error         string
+	Payload       []byte
+}
+
+// EncodeEnvelope 编码信封
+func EncodeEnvelope(env *Envelope) ([]byte, error) {
+	if len(env.CorrelationID) > 0xFF {
+		return nil, fmt.Errorf("correlation id too long: %d", len(env.CorrelationID))
+	}
+	if len(env.ReplyTo) > 0xFFFF || len(env.Error) > 0xFFFF {
+		return nil, fmt.Errorf("envelope field too long")
+	}
+
+	size := 2 + 1 + len(env.CorrelationID) + 2 + len(env.ReplyTo) + 2 + len(env.Error) + len(env.Payload)
+	buf := make([]byte, 0, size)
+	buf = append(buf, envelopeMagic, envelopeVersion)
+	buf = append(buf, byte(len(env.CorrelationID)))
+	buf = append(buf, env.CorrelationID...)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(env.ReplyTo)))
+	buf = append(buf, env.ReplyTo...)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(env.Error)))
+	buf = append(buf, env.Error...)
+	buf = append(buf, env.Payload...)
+	return buf, nil
+}
+
+// IsEnvelope 判断数据是否为 RPC 信封
+func IsEnvelope(data []byte) bool {
+	return len(data) >= 2 && data[0] == envelopeMagic && data[1] == envelopeVersion
+}
+
+// DecodeEnvelope 解码信封
+func DecodeEnvelope(data []byte) (*Envelope, error) {
+	if !IsEnvelope(data) {
+		return nil, fmt.Errorf("not an rpc envelope")
+	}
+	p := data[2:]
+
+	readString := func(n int) (string, error) {
+		if len(p) < n {
+			return "", fmt.Errorf("truncated envelope")
+		}
+		s := string(p[:n])
+		p = p[n:]
+		return s, nil
+	}
+	readLen16 := func() (int, error) {
+		if len(p) < 2 {
+			return 0, fmt.Errorf("truncated envelope")
+		}
+		n := int(binary.BigEndian.Uint16(p))
+		p = p[2:]
+		return n, nil
+	}
+
+	if len(p) < 1 {
+		return nil, fmt.Errorf("truncated envelope")
+	}
+	corrLen := int(p[0])
+	p = p[1:]
+
+	env := &Envelope{}
+	var err error
+	if env.CorrelationID, err = readString(corrLen); err != nil {
+		return nil, err
+	}
+	n, err := readLen16()
+	if err != nil {
+		return nil, err
+	}
+	if env.ReplyTo, err = readString(n); err != nil {
+		return nil, err
+	}
+	if n, err = readLen16(); err != nil {
+		return nil, err
+	}
+	if env.Error, err = readString(n); err != nil {
+		return nil, err
+	}
+	env.Payload = p
+	return env, nil
+}
+
+// RPCClient 基于 MQ 的请求/响应客户端
+// 每个实例订阅独立的回复 Topic，通过 CorrelationID 匹配响应
+type RPCClient struct {
+	producer   Producer
+	replyTopic string
+	prefix     string
+	seq        atomic.Uint64
+
+	mu      sync.Mutex
+	pending map[string]chan *Envelope
+	closed  bool
+}
+
+// NewRPCClient 创建 RPC 客户端并订阅回复 Topic（replyTopic 需在所有实例间唯一）
+func NewRPCClient(producer Producer, consumer Consumer, replyTopic string, opts ...SubscribeOption) (*RPCClient, error) {
+	replies, err := consumer.Subscribe(replyTopic, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("subscribe rpc reply topic: %w", err)
+	}
+
+	c := &RPCClient{
+		producer:   producer,
+		replyTopic: replyTopic,
+		prefix:     strconv.FormatInt(time.Now().UnixNano(), 36) + "-",
+		pending:    make(map[string]chan *Envelope),
+	}
+	go c.receiveLoop(replies)
+	return c, nil
+}
+
+// ReplyTopic 返回客户端使用的回复 Topic
+func (c *RPCClient) ReplyTopic() string {
+	return c.replyTopic
+}
+
+// Call 发送请求并等待响应；ctx 未设置 deadline 时使用 DefaultRPCTimeout
+func (c *RPCClient) Call(ctx context.Context, topic string, payload []byte) ([]byte, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultRPCTimeout)
+		defer cancel()
+	}
+
+	corrID := c.prefix + strconv.FormatUint(c.seq.Add(1), 36)
+	replyCh := make(chan *Envelope, 1)
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrRPCClosed
+	}
+	c.pending[corrID] = replyCh
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, corrID)
+		c.mu.Unlock()
+	}()
+
+	data, err := EncodeEnvelope(&Envelope{
+		CorrelationID: corrID,
+		ReplyTo:       c.replyTopic,
+		Payload:       payload,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := c.producer.Publish(topic, data); err != nil {
+		return nil, err
+	}
+
+	select {
+	case env, ok := <-replyCh:
+		if !ok {
+			return nil, ErrRPCClosed
+		}
+		if env.Error != "" {
+			return nil, &RemoteError{Message: env.Error}
+		}
+		return env.Payload, nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, ErrRPCTimeout
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (c *RPCClient) receiveLoop(replies <-chan *Message) {
+	for msg := range replies {
+		env, err := DecodeEnvelope(msg.Payload)
+		if err != nil {
+			log.Printf("[MQ-RPC] Invalid reply on %s: %v", msg.Topic, err)
+			continue
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[env.CorrelationID]
+		c.mu.Unlock()
+		if !ok {
+			// 调用方已超时返回
+			continue
+		}
+		select {
+		case ch <- env:
+		default:
+		}
+	}
+	c.Close()
+}
+
+// Close 使所有等待中的 Call 返回 ErrRPCClosed
+func (c *RPCClient) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+}
+
+// Request 服务端收到的请求
+type Request struct {
+	Topic         string
+	CorrelationID string
+	ReplyTo       string // 为空表示 fire-and-forget，Handler 的返回值会被丢弃
+	Payload       []byte
+}
+
+// NeedsReply 请求方是否在等待响应
+func (r *Request) NeedsReply() bool {
+	return r.ReplyTo != ""
+}
+
+// HandlerFunc 处理一个请求，返回的数据作为响应发回请求方
+type HandlerFunc func(ctx context.Context, req *Request) ([]byte, error)
+
+// RPCServer 基于 MQ 的请求处理服务端
+type RPCServer struct {
+	producer Producer
+	consumer Consumer
+
+	retry           atomic.Pointer[RetryPolicy] // 为空表示不重试
+	deadLetterTopic string
+	onFailure       FailureHandler
+
+	mu     sync.Mutex
+	topics []string
+	wg     sync.WaitGroup // 订阅循环 + 处理中的消息
+}
+
+// NewRPCServer 创建 RPC 服务端
+func NewRPCServer(producer Producer, consumer Consumer, opts ...ServerOption) *RPCServer {
+	s := &RPCServer{
+		producer: producer,
+		consumer: consumer,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// SetRetryPolicy 热更新重试策略，处理中的请求在下一次重试时生效
+func (s *RPCServer) SetRetryPolicy(p RetryPolicy) {
+	s.retry.Store(&p)
+}
+
+// Handle 订阅 topic 并将每条消息并发交给 handler 处理
+func (s *RPCServer) Handle(topic string, handler HandlerFunc, opts ...SubscribeOption) error {
+	msgs, err := s.consumer.Subscribe(topic, opts...)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.topics = append(s.topics, topic)
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for msg := range msgs {
+			s.wg.Add(1)
+			go func(msg *Message) {
+				defer s.wg.Done()
+				s.dispatch(msg, handler)
+			}(msg)
+		}
+		log.Printf("[MQ-RPC] Subscription closed for topic: %s", topic)
+	}()
+	return nil
+}
+
+// Unhandle 取消 topic 的订阅，已收到的消息仍会处理完；Consumer 须实现 Unsubscriber
+func (s *RPCServer) Unhandle(topic string) error {
+	u, ok := s.consumer.(Unsubscriber)
+	if !ok {
+		return fmt.Errorf("consumer does not support unsubscribe")
+	}
+	s.mu.Lock()
+	s.topics = slices.DeleteFunc(s.topics, func(t string) bool { return t == topic })
+	s.mu.Unlock()
+	return u.Unsubscribe(topic)
+}
+
+// Shutdown 取消所有订阅，处理完已收到的消息，并等待处理中的 handler 返回或 ctx 截止
+// Consumer 未实现 Unsubscriber 时只能等待 Consumer 被关闭
+func (s *RPCServer) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	topics := s.topics
+	s.topics = nil
+	s.mu.Unlock()
+
+	if u, ok := s.consumer.(Unsubscriber); ok {
+		for _, topic := range topics {
+			if err := u.Unsubscribe(topic); err != nil {
+				log.Printf("[MQ-RPC] Unsubscribe %s failed: %v", topic, err)
+			}
+		}
+	} else {
+		log.Printf("[MQ-RPC] Consumer does not support unsubscribe, waiting for it to be closed")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *RPCServer) dispatch(msg *Message, handler HandlerFunc) {
+	ctx := context.Background()
+	req := &Request{Topic: msg.Topic, Payload: msg.Payload}
+	if IsEnvelope(msg.Payload) {
+		env, err := DecodeEnvelope(msg.Payload)
+		if err != nil {
+			log.Printf("[MQ-RPC] Invalid request on %s: %v", msg.Topic, err)
+			s.deadLetter(msg, req, Permanent(err), 0)
+			return
+		}
+		req.CorrelationID = env.CorrelationID
+		req.ReplyTo = env.ReplyTo
+		req.Payload = env.Payload
+	}
+
+	result, attempts, err := s.invoke(ctx, handler, req)
+	if err != nil {
+		log.Printf("[MQ-RPC] Handler error on %s after %d attempt(s): %v", msg.Topic, attempts, err)
+		s.deadLetter(msg, req, err, attempts)
+		if s.onFailure != nil {
+			s.onFailure(ctx, req, err)
+		}
+	}
+	if !req.NeedsReply() {
+		return
+	}
+
+	reply := &Envelope{CorrelationID: req.CorrelationID, Payload: result}
+	if err != nil {
+		reply.Error = err.Error()
+		if len(reply.Error) > maxRemoteErrorLen {
+			reply.Error = reply.Error[:maxRemoteErrorLen]
+		}
+		reply.Payload = nil
+	}
+	data, err := EncodeEnvelope(reply)
+	if err != nil {
+		log.Printf("[MQ-RPC] Encode reply failed on %s: %v", msg.Topic, err)
+		return
+	}
+	if err := s.producer.Publish(req.ReplyTo, data); err != nil {
+		log.Printf("[MQ-RPC] Publish reply to %s failed: %v", req.ReplyTo, err)
+	}
+}
+
+// invoke 调用 handler，对可重试错误按 RetryPolicy 退避重试
+func (s *RPCServer) invoke(ctx context.Context, handler HandlerFunc, req *Request) ([]byte, int, error) {
+	attempt := 0
+	for {
+		attempt++
+		result, err := handler(ctx, req)
+		retry := s.retry.Load()
+		if err == nil || IsPermanent(err) || retry == nil || attempt >= retry.MaxAttempts {
+			return result, attempt, err
+		}
+		time.Sleep(retry.Backoff(attempt))
+	}
+}
+
+// deadLetter 将处理失败的原始消息及错误信息发布到死信 Topic
+func (s *RPCServer) deadLetter(msg *Message, req *Request, cause error, attempts int) {
+	if s.deadLetterTopic == "" {
+		return
+	}
+
+	dl := &DeadLetter{
+		Topic:         msg.Topic,
+		CorrelationID: req.CorrelationID,
+		ReplyTo:       req.ReplyTo,
+		Error:         cause.Error(),
+		Permanent:     IsPermanent(cause),
+		Attempts:      attempts,
+		FailedAt:      time.Now(),
+		Payload:       msg.Payload,
+	}
+	data, err := dl.Encode()
+	if err != nil {
+		log.Printf("[MQ-RPC] Encode dead letter failed on %s: %v", msg.Topic, err)
+		return
+	}
+	if err := s.producer.Publish(s.deadLetterTopic, data); err != nil {
+		log.Printf("[MQ-RPC] Publish dead letter to %s failed: %v", s.deadLetterTopic, err)
+	}
+}
@@ -0,0 +1,140 @@
+package mq
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// spillQueue 基于临时文件的 FIFO 队列，用于 OverflowSpill 策略
+// 记录格式: [TopicLen(2)][PayloadLen(4)][Topic][Payload]
+type spillQueue struct {
+	mu       sync.Mutex
+	file     *os.File
+	readOff  int64
+	writeOff int64
+	count    int
+
+	// peek 读取但尚未 ack 的记录
+	head     *Message
+	headSize int64
+
+	notify chan struct{}
+}
+
+const spillRecordHeader = 6
+
+func newSpillQueue(dir string) (*spillQueue, error) {
+	f, err := os.CreateTemp(dir, "mq-spill-*.dat")
+	if err != nil {
+		return nil, fmt.Errorf("create spill file: %w", err)
+	}
+	return &spillQueue{
+		file:   f,
+		notify: make(chan struct{}, 1),
+	}, nil
+}
+
+func (q *spillQueue) append(msg *Message) error {
+	if len(msg.Topic) > 0xFFFF {
+		return fmt.Errorf("topic too long: %d", len(msg.Topic))
+	}
+
+	buf := make([]byte, spillRecordHeader+len(msg.Topic)+len(msg.Payload))
+	binary.BigEndian.PutUint16(buf[0:2], uint16(len(msg.Topic)))
+	binary.BigEndian.PutUint32(buf[2:6], uint32(len(msg.Payload)))
+	copy(buf[spillRecordHeader:], msg.Topic)
+	copy(buf[spillRecordHeader+len(msg.Topic):], msg.Payload)
+
+	q.mu.Lock()
+	if _, err := q.file.WriteAt(buf, q.writeOff); err != nil {
+		q.mu.Unlock()
+		return err
+	}
+	q.writeOff += int64(len(buf))
+	q.count++
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// pending 返回尚未成功回放的记录数（包含已 peek 未 ack 的记录）
+func (q *spillQueue) pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.count
+}
+
+// peek 读取队首记录，队列为空时返回 nil
+func (q *spillQueue) peek() (*Message, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.head != nil {
+		return q.head, nil
+	}
+	if q.readOff >= q.writeOff {
+		return nil, nil
+	}
+
+	header := make([]byte, spillRecordHeader)
+	if _, err := q.file.ReadAt(header, q.readOff); err != nil {
+		return nil, err
+	}
+	topicLen := int(binary.BigEndian.Uint16(header[0:2]))
+	payloadLen := int(binary.BigEndian.Uint32(header[2:6]))
+
+	body := make([]byte, topicLen+payloadLen)
+	if _, err := q.file.ReadAt(body, q.readOff+spillRecordHeader); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	q.head = &Message{
+		Topic:   string(body[:topicLen]),
+		Payload: body[topicLen:],
+	}
+	q.headSize = int64(spillRecordHeader + topicLen + payloadLen)
+	return q.head, nil
+}
+
+// ack 确认队首记录已回放；队列清空后截断文件以回收磁盘空间
+func (q *spillQueue) ack() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.readOff += q.headSize
+	q.head = nil
+	q.headSize = 0
+	q.count--
+
+	if q.count == 0 {
+		q.readOff, q.writeOff = 0, 0
+		q.file.Truncate(0)
+	}
+}
+
+// reset 丢弃全部记录，返回丢弃数量
+func (q *spillQueue) reset() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := q.count
+	q.readOff, q.writeOff, q.count = 0, 0, 0
+	q.head = nil
+	q.headSize = 0
+	q.file.Truncate(0)
+	return n
+}
+
+func (q *spillQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.file.Close()
+	os.Remove(q.file.Name())
+}
@@ -0,0 +1,151 @@
+package mq
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConnState MQ 连接状态
+type ConnState int32
+
+const (
+	StateConnecting   ConnState = iota // 首次连接中
+	StateConnected                     // 连接正常，所有订阅有效
+	StateReconnecting                  // 连接断开，正在重连/重新订阅
+	StateClosed                        // 已主动关闭
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateClosed:
+		return "closed"
+	default:
+		return fmt.Sprintf("ConnState(%d)", int32(s))
+	}
+}
+
+// StateEvent 连接状态变化事件
+type StateEvent struct {
+	State ConnState
+	Topic string // 触发变化的订阅 Topic（连接级事件为空）
+	Err   error
+	Time  time.Time
+}
+
+// StateWatcher 由支持连接状态上报的 MQ 实现
+type StateWatcher interface {
+	State() ConnState
+	OnStateChange(fn func(StateEvent))
+}
+
+// SubscriptionState 单个订阅的状态
+type SubscriptionState struct {
+	Topic   string `json:"topic"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"` // 最近一次断开原因
+}
+
+// SubscriptionReporter 由支持按订阅上报状态的 MQ 实现
+type SubscriptionReporter interface {
+	Subscriptions() []SubscriptionState
+}
+
+// DefaultReconnectPolicy 订阅断开后的重连退避策略（MaxAttempts 不生效，会一直重试）
+var DefaultReconnectPolicy = RetryPolicy{
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+	Multiplier:     2,
+}
+
+// stateTracker 维护连接状态并通知监听者
+// 多个订阅各自重连时，只要有一个处于断开状态，整体即为 StateReconnecting
+type stateTracker struct {
+	state      atomic.Int32
+	broken     atomic.Int32 // 当前断开的订阅数
+	reconnects atomic.Uint64
+
+	mu        sync.RWMutex
+	listeners []func(StateEvent)
+	lost      map[string]error // 当前断开的订阅 Topic -> 断开原因
+}
+
+// State 返回当前连接状态
+func (t *stateTracker) State() ConnState {
+	return ConnState(t.state.Load())
+}
+
+// OnStateChange 注册状态变化回调（回调在 MQ 内部 goroutine 中执行，不应阻塞）
+func (t *stateTracker) OnStateChange(fn func(StateEvent)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.listeners = append(t.listeners, fn)
+}
+
+func (t *stateTracker) set(state ConnState, topic string, err error) {
+	if ConnState(t.state.Swap(int32(state))) == state {
+		return
+	}
+
+	ev := StateEvent{State: state, Topic: topic, Err: err, Time: time.Now()}
+	t.mu.RLock()
+	listeners := t.listeners
+	t.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(ev)
+	}
+}
+
+// subscriptionLost 某个订阅断开
+func (t *stateTracker) subscriptionLost(topic string, err error) {
+	t.markLost(topic, err)
+	t.broken.Add(1)
+	if t.State() != StateClosed {
+		t.set(StateReconnecting, topic, err)
+	}
+}
+
+// subscriptionRestored 某个订阅重连成功
+func (t *stateTracker) subscriptionRestored(topic string) {
+	t.markLost(topic, nil)
+	t.reconnects.Add(1)
+	if t.broken.Add(-1) == 0 && t.State() != StateClosed {
+		t.set(StateConnected, topic, nil)
+	}
+}
+
+// subscriptionRemoved 断开中的订阅被取消，不再计入断开数
+func (t *stateTracker) subscriptionRemoved(topic string) {
+	t.markLost(topic, nil)
+	if t.broken.Add(-1) == 0 && t.State() != StateClosed {
+		t.set(StateConnected, topic, nil)
+	}
+}
+
+// markLost 记录（err 非 nil）或清除订阅的断开状态
+func (t *stateTracker) markLost(topic string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err == nil {
+		delete(t.lost, topic)
+		return
+	}
+	if t.lost == nil {
+		t.lost = make(map[string]error)
+	}
+	t.lost[topic] = err
+}
+
+// lostError 返回订阅的断开原因，正常时为 nil
+func (t *stateTracker) lostError(topic string) error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.lost[topic]
+}
@@ -0,0 +1,110 @@
+package mq
+
+import "strings"
+
+// 逻辑 Topic 命名约定:
+//   - 层级之间使用 ':' 分隔，例如 game:request:mmo
+//   - '+' 匹配单个层级，例如 game:request:+
+//   - '#' 匹配剩余所有层级，只能出现在末尾，例如 game:#
+//
+// 各 MQ 实现负责把逻辑 Topic 翻译为自身的命名方式。
+const (
+	TopicSeparator = ":"
+	WildcardOne    = "+"
+	WildcardMulti  = "#"
+
+	mqttSeparator   = "/"
+	mqttSharePrefix = "$share"
+)
+
+// IsWildcard 判断 Topic 是否包含通配符
+func IsWildcard(topic string) bool {
+	for _, level := range strings.Split(topic, TopicSeparator) {
+		if level == WildcardOne || level == WildcardMulti {
+			return true
+		}
+	}
+	return false
+}
+
+// ToMQTTTopic 将逻辑 Topic 转换为 MQTT 层级 Topic (game:request:mmo -> game/request/mmo)
+func ToMQTTTopic(topic string) string {
+	return strings.ReplaceAll(topic, TopicSeparator, mqttSeparator)
+}
+
+// FromMQTTTopic 将 MQTT Topic 转换回逻辑 Topic
+func FromMQTTTopic(topic string) string {
+	return strings.ReplaceAll(topic, mqttSeparator, TopicSeparator)
+}
+
+// SharedTopic 构造 MQTT 共享订阅过滤器 ($share/{group}/{topic})
+func SharedTopic(group, mqttTopic string) string {
+	return mqttSharePrefix + mqttSeparator + group + mqttSeparator + mqttTopic
+}
+
+// MatchTopic 判断逻辑 Topic 是否匹配订阅过滤器（支持 '+' 和 '#'）
+func MatchTopic(filter, topic string) bool {
+	fl := strings.Split(filter, TopicSeparator)
+	tl := strings.Split(topic, TopicSeparator)
+	for i, level := range fl {
+		if level == WildcardMulti {
+			return true
+		}
+		if i >= len(tl) {
+			return false
+		}
+		if level != WildcardOne && level != tl[i] {
+			return false
+		}
+	}
+	return len(fl) == len(tl)
+}
+
+// toRedisPattern 将带通配符的逻辑 Topic 转换为 Redis PSUBSCRIBE 模式
+// Redis glob 无法表达"单个层级"，'+' 会放宽为 '*'，由调用方再用 MatchTopic 精确过滤
+func toRedisPattern(topic string) string {
+	levels := strings.Split(topic, TopicSeparator)
+	for i, level := range levels {
+		switch level {
+		case WildcardOne, WildcardMulti:
+			levels[i] = "*"
+		default:
+			levels[i] = escapeRedisGlob(level)
+		}
+	}
+	return strings.Join(levels, TopicSeparator)
+}
+
+func escapeRedisGlob(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// 服务间约定的 Topic
+const (
+	TopicBroadcast    = "broadcast"     // Chat Service -> Gateway 下行消息
+	TopicAuthRPC      = "chat:rpc:auth" // Token 校验 (RPC)
+	TopicGameRegistry = "game:registry" // 游戏注册表变更（所有实例都会收到，见 game-pkg/registry）
+)
+
+// RequestTopic Gateway -> Chat Service 的请求 Topic
+func RequestTopic(gameID string) string {
+	return "game:request:" + gameID
+}
+
+// GameLogicTopic Gateway -> 游戏逻辑服的请求 Topic（RouteGame）
+func GameLogicTopic(gameID string) string {
+	return "game:logic:" + gameID
+}
+
+// GamePushTopic 游戏逻辑服 -> Gateway 的推送 Topic，所有 Gateway 实例都会收到
+func GamePushTopic(gameID string) string {
+	return "game:push:" + gameID
+}
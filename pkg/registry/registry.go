@@ -0,0 +1,218 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"sync"
+
+	"game-pkg/mq"
+)
+
+// Op 注册表变更类型
+type Op string
+
+const (
+	OpAdd    Op = "add"
+	OpRemove Op = "remove"
+	OpSync   Op = "sync" // 启动时请求其他实例公布各自的游戏（见 Sync 的 announce）
+)
+
+// Event 注册表变更事件，经 mq.TopicGameRegistry 以 JSON 在实例间同步
+type Event struct {
+	Op       Op     `json:"op"`
+	GameID   string `json:"game_id,omitempty"`
+	Origin   string `json:"origin"`             // 发布者实例 ID，实例忽略自己发布的事件
+	Announce bool   `json:"announce,omitempty"` // 响应 sync 公布的 add（非显式注册），已注销的游戏忽略
+}
+
+// idPattern GameID 会拼接进 MQ Topic（game:request:{id}）及 URL 参数
+var idPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// ValidID 校验 GameID 格式
+func ValidID(id string) error {
+	if !idPattern.MatchString(id) {
+		return fmt.Errorf("invalid game id %q (must match %s)", id, idPattern)
+	}
+	return nil
+}
+
+// Registry 已注册的游戏：Gateway 拒绝未注册 GameID 的连接及请求，Chat Service 按游戏订阅请求 Topic
+// 变更来源：配置文件（启动及热更新）、Gateway 管理 API，以及其他实例经 MQ 同步的事件
+type Registry struct {
+	mu    sync.RWMutex
+	games map[string]struct{}
+
+	// removed 已注销游戏的墓碑：其他实例响应 sync 公布的同名游戏（如配置文件中仍保留）不会被重新注册，
+	// 显式 Add 时清除；只在内存中，实例重启后丢失
+	removed map[string]struct{}
+
+	// update 串行化变更与回调，保证回调按变更顺序执行
+	update    sync.Mutex
+	listeners []func(Event)
+
+	// Sync 之后本地变更发布到 mq.TopicGameRegistry
+	producer mq.Producer
+	origin   string
+
+	// protected 其他实例的 remove 事件对其不生效的游戏（本实例配置文件中的游戏），为空不限制
+	protected func(id string) bool
+}
+
+// New 创建注册表，ids 为初始游戏（通常来自配置文件，不触发回调）
+func New(ids ...string) *Registry {
+	r := &Registry{games: make(map[string]struct{}, len(ids)), removed: make(map[string]struct{})}
+	for _, id := range ids {
+		r.games[id] = struct{}{}
+	}
+	return r
+}
+
+// Has GameID 是否已注册
+func (r *Registry) Has(id string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.games[id]
+	return ok
+}
+
+// List 返回所有已注册的 GameID（排序）
+func (r *Registry) List() []string {
+	r.mu.RLock()
+	ids := make([]string, 0, len(r.games))
+	for id := range r.games {
+		ids = append(ids, id)
+	}
+	r.mu.RUnlock()
+	slices.Sort(ids)
+	return ids
+}
+
+// OnChange 注册变更回调（Add / Remove 实际改变注册表时调用，初始游戏不会触发），需在启动前调用
+func (r *Registry) OnChange(fn func(Event)) {
+	r.update.Lock()
+	defer r.update.Unlock()
+	r.listeners = append(r.listeners, fn)
+}
+
+// Add 注册游戏并同步到其他实例，返回是否新增
+func (r *Registry) Add(id string) (bool, error) {
+	if err := ValidID(id); err != nil {
+		return false, err
+	}
+	return r.apply(Event{Op: OpAdd, GameID: id}, true), nil
+}
+
+// Remove 注销游戏并同步到其他实例，返回是否存在
+func (r *Registry) Remove(id string) bool {
+	return r.apply(Event{Op: OpRemove, GameID: id}, true)
+}
+
+// apply 应用一次变更，注册表改变时依次调用回调；publish 为 true 时发布到其他实例
+func (r *Registry) apply(ev Event, publish bool) bool {
+	r.update.Lock()
+	defer r.update.Unlock()
+
+	r.mu.Lock()
+	_, exists := r.games[ev.GameID]
+	if _, removed := r.removed[ev.GameID]; removed && ev.Announce {
+		r.mu.Unlock()
+		return false
+	}
+	switch ev.Op {
+	case OpAdd:
+		r.games[ev.GameID] = struct{}{}
+		delete(r.removed, ev.GameID)
+	case OpRemove:
+		delete(r.games, ev.GameID)
+		r.removed[ev.GameID] = struct{}{}
+	}
+	r.mu.Unlock()
+
+	if changed := (ev.Op == OpAdd) != exists; !changed {
+		return false
+	}
+	log.Printf("[Registry] Game %s: %s", ev.Op, ev.GameID)
+	for _, fn := range r.listeners {
+		fn(ev)
+	}
+	if publish {
+		r.publish(ev)
+	}
+	return true
+}
+
+func (r *Registry) publish(ev Event) {
+	r.mu.RLock()
+	producer, origin := r.producer, r.origin
+	r.mu.RUnlock()
+	if producer == nil {
+		return
+	}
+	ev.Origin = origin
+	data, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("[Registry] Encode event failed: %v", err)
+		return
+	}
+	if err := producer.Publish(mq.TopicGameRegistry, data); err != nil {
+		log.Printf("[Registry] Publish %s %s failed: %v", ev.Op, ev.GameID, err)
+	}
+}
+
+// SetProtected 设置不接受其他实例注销的游戏（通常为本实例配置文件中的游戏，支持热更新）：
+// 某个实例的配置文件删除游戏时，配置中仍保留该游戏的实例不随之注销；需在 Sync 之前调用
+func (r *Registry) SetProtected(fn func(id string) bool) {
+	r.protected = fn
+}
+
+// Sync 经 MQ 在实例间同步注册表：本地 Add / Remove 发布到 mq.TopicGameRegistry，收到其他实例的事件后应用（不再转发）
+// 启动时发送一次 sync 请求；announce 为 true 的实例（Gateway）收到 sync 请求时公布本实例的全部游戏，
+// 因此重启的实例会补回其他实例经管理 API 新增的游戏；公布的游戏在已注销（有墓碑）的实例上被忽略
+func (r *Registry) Sync(producer mq.Producer, consumer mq.Consumer, origin string, announce bool) error {
+	msgs, err := consumer.Subscribe(mq.TopicGameRegistry)
+	if err != nil {
+		return fmt.Errorf("subscribe %s: %w", mq.TopicGameRegistry, err)
+	}
+
+	r.mu.Lock()
+	r.producer, r.origin = producer, origin
+	r.mu.Unlock()
+
+	go func() {
+		for msg := range msgs {
+			var ev Event
+			if err := json.Unmarshal(msg.Payload, &ev); err != nil {
+				log.Printf("[Registry] Invalid event: %v", err)
+				continue
+			}
+			if ev.Origin == origin {
+				continue
+			}
+			switch ev.Op {
+			case OpAdd, OpRemove:
+				if err := ValidID(ev.GameID); err != nil {
+					log.Printf("[Registry] Ignored %s event from %s: %v", ev.Op, ev.Origin, err)
+					continue
+				}
+				if ev.Op == OpRemove && r.protected != nil && r.protected(ev.GameID) {
+					log.Printf("[Registry] Ignored remove of configured game %s from %s", ev.GameID, ev.Origin)
+					continue
+				}
+				r.apply(ev, false)
+			case OpSync:
+				if announce {
+					for _, id := range r.List() {
+						r.publish(Event{Op: OpAdd, GameID: id, Announce: true})
+					}
+				}
+			}
+		}
+		log.Printf("[Registry] Subscription closed for topic: %s", mq.TopicGameRegistry)
+	}()
+
+	r.publish(Event{Op: OpSync})
+	return nil
+}
@@ -0,0 +1,141 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Config 链路追踪配置
+type Config struct {
+	Exporter    string  `mapstructure:"exporter"`     // none（默认，不采集）/ file / stdout / otlp
+	File        string  `mapstructure:"file"`         // exporter=file 时的输出文件（每行一个 JSON Span）
+	Endpoint    string  `mapstructure:"endpoint"`     // exporter=otlp 时的 OTLP/HTTP 地址，如 localhost:4318
+	Insecure    bool    `mapstructure:"insecure"`     // exporter=otlp 时使用 HTTP 而非 HTTPS
+	SampleRatio float64 `mapstructure:"sample_ratio"` // 采样率 (0, 1]，0 视为 1；父 Span 已采样时跟随，根 Span 及未采样的远端父 Span 按 trace_id 判定
+}
+
+// Init 按配置安装全局 TracerProvider 与 W3C TraceContext 传播器
+// 返回的 shutdown 在退出时调用，导出缓冲中剩余的 Span；exporter=none 时 Tracer 为空实现
+func Init(service string, cfg Config) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var exporter sdktrace.SpanExporter
+	switch cfg.Exporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		exporter, err = stdouttrace.New()
+	case "file":
+		if cfg.File == "" {
+			return nil, fmt.Errorf("tracing.file is required for the file exporter")
+		}
+		f, ferr := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if ferr != nil {
+			return nil, fmt.Errorf("open trace file: %w", ferr)
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+	case "otlp":
+		opts := []otlptracehttp.Option{}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(context.Background(), opts...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q (none / file / stdout / otlp)", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create %s exporter: %w", cfg.Exporter, err)
+	}
+
+	ratio := cfg.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+	// 来自 MessageMeta 的远端父 Span 不带采样标志（见 ContextWithIDs），与根 Span 一样按 trace_id 判定：
+	// TraceIDRatioBased 对同一 trace_id 结论确定，各服务采样率相同时同一链路要么都采样要么都不采样
+	root := sdktrace.TraceIDRatioBased(ratio)
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(root, sdktrace.WithRemoteParentNotSampled(root))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", service))),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// Tracer 返回全局 TracerProvider 的 Tracer
+func Tracer(name string) trace.Tracer {
+	return otel.Tracer(name)
+}
+
+// ContextWithIDs 以 MessageMeta.trace_id / span_id（十六进制）作为远端父 Span，无效 ID 时原样返回 ctx
+// MessageMeta 不携带采样标志，父 Span 视为未采样，是否采样由本服务决定（见 Init），对端无法强制采样
+func ContextWithIDs(ctx context.Context, traceID, spanID string) context.Context {
+	tid, err := trace.TraceIDFromHex(traceID)
+	if err != nil {
+		return ctx
+	}
+	sid, err := trace.SpanIDFromHex(spanID)
+	if err != nil {
+		return ctx
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: tid,
+		SpanID:  sid,
+		Remote:  true,
+	}))
+}
+
+// IDs 返回 ctx 中被采样 Span 的 trace_id / span_id，用于写入 MessageMeta
+func IDs(ctx context.Context) (traceID, spanID string, ok bool) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() || !sc.IsSampled() {
+		return "", "", false
+	}
+	return sc.TraceID().String(), sc.SpanID().String(), true
+}
+
+// TraceParent 返回 ctx 中被采样 Span 的 W3C traceparent，用于写入 Envelope；无则返回空
+func TraceParent(ctx context.Context) string {
+	if _, _, ok := IDs(ctx); !ok {
+		return ""
+	}
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// TraceParentFromIDs 返回本服务被采样 Span 的 W3C traceparent，traceID / spanID 须取自 IDs（不能是对端传入的 ID）；
+// 用于 ID 已暂存在 MessageMeta 中、ctx 不在手边的场景，无效 ID 时返回空
+func TraceParentFromIDs(traceID, spanID string) string {
+	tid, err := trace.TraceIDFromHex(traceID)
+	if err != nil {
+		return ""
+	}
+	sid, err := trace.SpanIDFromHex(spanID)
+	if err != nil {
+		return ""
+	}
+	return "00-" + tid.String() + "-" + sid.String() + "-" + trace.FlagsSampled.String()
+}
+
+// ContextWithTraceParent 以 W3C traceparent 作为远端父 Span，为空或无效时原样返回 ctx
+func ContextWithTraceParent(ctx context.Context, traceParent string) context.Context {
+	if traceParent == "" {
+		return ctx
+	}
+	return propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{"traceparent": traceParent})
+}
@@ -19,17 +19,22 @@ import (
 // GameChatClient 游戏聊天客户端
 type GameChatClient struct {
 	userID int32
+	gameID string
 	conn   *protocol.WSConn
 	seq    uint32
 	debug  bool
 }
 
 // NewGameChatClient 创建新的游戏聊天客户端
-func NewGameChatClient(userID int32, gatewayURL string, debug bool) (*GameChatClient, error) {
+// 以 ?game={gameID} 连接，Gateway 拒绝未注册的游戏
+func NewGameChatClient(userID int32, gatewayURL, gameID string, debug bool) (*GameChatClient, error) {
 	u, err := url.Parse(gatewayURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid URL: %w", err)
 	}
+	q := u.Query()
+	q.Set("game", gameID)
+	u.RawQuery = q.Encode()
 
 	c, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
 	if err != nil {
@@ -38,6 +43,7 @@ func NewGameChatClient(userID int32, gatewayURL string, debug bool) (*GameChatCl
 
 	return &GameChatClient{
 		userID: userID,
+		gameID: gameID,
 		conn:   protocol.NewWSConn(c),
 		seq:    0,
 		debug:  debug,
@@ -53,7 +59,7 @@ func (c *GameChatClient) Close() error {
 func (c *GameChatClient) Bind() error {
 	bindReq := &chat.ChatRequest{
 		Base: &common.MessageBase{
-			GameId:    "mmo",
+			GameId:    c.gameID,
 			UserId:    c.userID,
 			Timestamp: time.Now().Unix(),
 		},
@@ -108,7 +114,7 @@ func (c *GameChatClient) Bind() error {
 func (c *GameChatClient) SendMessage(targetID int32, content string) error {
 	sendReq := &chat.ChatRequest{
 		Base: &common.MessageBase{
-			GameId:    "mmo",
+			GameId:    c.gameID,
 			UserId:    c.userID,
 			Timestamp: time.Now().Unix(),
 		},
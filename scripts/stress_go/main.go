@@ -24,6 +24,7 @@ var (
 	concurrency  uint64 = 1    // 并发用户数
 	totalReqs    uint64 = 1    // 每个用户的请求数
 	gatewayURL   string        // Gateway URL
+	gameID       string        // 游戏 ID
 	startUserID  int64  = 2000 // 起始用户ID
 	debugMode    bool   = false
 	connInterval int    = 2 // 连接间隔（毫秒）
@@ -33,6 +34,7 @@ func init() {
 	flag.Uint64Var(&concurrency, "c", 1, "并发用户数")
 	flag.Uint64Var(&totalReqs, "n", 1, "每个用户的请求数")
 	flag.StringVar(&gatewayURL, "u", "", "Gateway WebSocket URL (留空则自动从配置读取)")
+	flag.StringVar(&gameID, "g", "mmo", "游戏ID（需已在 Gateway 注册）")
 	flag.Int64Var((*int64)(&startUserID), "s", 2000, "起始用户ID")
 	flag.BoolVar(&debugMode, "d", false, "调试模式")
 	flag.IntVar(&connInterval, "i", 2, "连接间隔（毫秒），0=无间隔（最猛）")
@@ -93,6 +95,7 @@ func main() {
 		Concurrency:        concurrency,
 		TotalNumber:        totalReqs,
 		URL:                gatewayURL,
+		GameID:             gameID,
 		StartUserID:        int32(startUserID),
 		Debug:              debugMode,
 		ConnectionInterval: connInterval,
@@ -104,6 +107,7 @@ func main() {
 	log.Printf("   每用户请求数: %d", totalReqs)
 	log.Printf("   总请求数: %d", concurrency*totalReqs)
 	log.Printf("   Gateway: %s", gatewayURL)
+	log.Printf("   游戏: %s", gameID)
 	log.Printf("   用户ID范围: %d - %d", startUserID, startUserID+int64(concurrency)-1)
 	log.Printf("   连接间隔: %dms%s", connInterval, func() string {
 		if connInterval == 0 {
@@ -5,6 +5,7 @@ type Request struct {
 	Concurrency        uint64 // 并发用户数
 	TotalNumber        uint64 // 每个用户的请求数
 	URL                string // Gateway WebSocket URL
+	GameID             string // 游戏 ID
 	StartUserID        int32  // 起始用户ID
 	Debug              bool   // 调试模式
 	ConnectionInterval int    // 连接间隔（毫秒），0 表示无间隔
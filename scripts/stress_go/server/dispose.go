@@ -72,7 +72,7 @@ func runUserStressTest(userID int32, request *model.Request, stats *statistics.S
 	var err error
 
 	for attempt := 0; attempt < 3; attempt++ {
-		chatClient, err = client.NewGameChatClient(userID, request.URL, request.Debug)
+		chatClient, err = client.NewGameChatClient(userID, request.URL, request.GameID, request.Debug)
 		if err == nil {
 			break
 		}